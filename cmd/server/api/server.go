@@ -3,8 +3,12 @@ package api
 import (
 	"context"
 	"fmt"
+	adminApi "go-faster-gateway/internal/pkg/api"
 	"go-faster-gateway/internal/pkg/balancer"
 	db_init "go-faster-gateway/internal/pkg/componentSetup/database"
+	dataProvider "go-faster-gateway/internal/pkg/data/provider"
+	"go-faster-gateway/internal/pkg/metrics"
+	"go-faster-gateway/internal/pkg/middleware"
 	"go-faster-gateway/internal/pkg/protocols"
 	servers "go-faster-gateway/internal/pkg/server"
 	"go-faster-gateway/pkg/log/logger"
@@ -17,11 +21,14 @@ import (
 
 	logger_init "go-faster-gateway/internal/pkg/componentSetup/logger"
 	"go-faster-gateway/internal/pkg/router"
+	"go-faster-gateway/internal/pkg/tracing"
 	"go-faster-gateway/internal/server"
 	configLoader "go-faster-gateway/pkg/config"
 	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/config/static"
 	"go-faster-gateway/pkg/log"
 	"go-faster-gateway/pkg/provider/aggregator"
+	apiProvider "go-faster-gateway/pkg/provider/api"
 	"go-faster-gateway/pkg/safe"
 )
 
@@ -68,7 +75,22 @@ func run() error {
 	providerAggregator := aggregator.NewProviderAggregator(*configManager.GetStaticConfig().Providers)
 	ctx := logger.NewContext(context.Background(), log.Log)
 	routinesPool := safe.NewPool(ctx)
-	// TODO 这边可以加入其他文件提供者
+	//数据库路由依赖动态配置中的databases, 数据库在服务启动前初始化
+	if dbConf := configManager.GetStaticConfig().Providers.Database; dbConf != nil {
+		if err := providerAggregator.AddProvider(dataProvider.NewRouteResourceDbProvider(dbConf)); err != nil {
+			log.Log.WithError(err).Error("init database provider fail")
+			return err
+		}
+	}
+	//管理接口的写操作通过api provider发布配置, 和其他provider一样走正常的重载流程
+	var routeProvider *apiProvider.Provider
+	if apiConf := configManager.GetStaticConfig().API; apiConf != nil {
+		routeProvider = apiProvider.New(newApiProviderStore(apiConf.Persistence))
+		if err := providerAggregator.AddProvider(routeProvider); err != nil {
+			log.Log.WithError(err).Error("init api provider fail")
+			return err
+		}
+	}
 
 	//构建blance和协议
 	upstreamManager := balancer.NewUpstreamManager()
@@ -82,7 +104,7 @@ func run() error {
 	watcher := configLoader.NewConfigurationWatcher(
 		routinesPool,
 		providerAggregator,
-		"file",
+		configManager.GetStaticConfig().Providers.RequiredProviderName(),
 	)
 	configManager.SetWatch(watcher)
 
@@ -94,12 +116,71 @@ func run() error {
 	}
 
 	routerManager := router.NewRouterManager(upstreamManager, protocolManager)
+	if tracingConf := configManager.GetStaticConfig().Tracing; tracingConf != nil {
+		shutdownTracing, err := tracing.Setup(ctx, tracingConf)
+		if err != nil {
+			log.Log.WithError(err).Error("init tracing fail")
+			return err
+		}
+		defer func() {
+			if err := shutdownTracing(context.Background()); err != nil {
+				log.Log.WithError(err).Error("shutdown tracing fail")
+			}
+		}()
+		routerManager.Use(tracing.Handler)
+	}
+	if accessLogConf := configManager.GetStaticConfig().AccessLog; accessLogConf != nil {
+		accessLogger, err := middleware.NewAccessLogger(accessLogConf)
+		if err != nil {
+			log.Log.WithError(err).Error("init access log fail")
+			return err
+		}
+		defer accessLogger.Close()
+		routerManager.Use(accessLogger.Handler)
+	}
+	var metricsRegistry *metrics.Registry
+	if metricsConf := configManager.GetStaticConfig().Metrics; metricsConf != nil && metricsConf.Prometheus != nil {
+		metricsRegistry, err = metrics.NewRegistry(metricsConf.Prometheus)
+		if err != nil {
+			log.Log.WithError(err).Error("init metrics fail")
+			return err
+		}
+		metrics.SetDefault(metricsRegistry)
+		routerManager.Use(metricsRegistry.Handler)
+	}
 	serviceManager := servers.NewServiceManager(ctx, configManager, routerManager)
 	serviceManager.InitBuildServer()
+	if metricsRegistry != nil {
+		metricsRegistry.RegisterOpenConnections(func() float64 {
+			if fs := serviceManager.GetFastServer(); fs != nil {
+				return float64(fs.OpenConnections())
+			}
+			return 0
+		})
+		metricsRegistry.RegisterUpstreams(upstreamManager.GetUpstream().Nodes)
+		routinesPool.GoCtx(func(ctx context.Context) {
+			if err := metricsRegistry.ListenAndServe(ctx); err != nil {
+				log.Log.WithError(err).Error("metrics server fail")
+			}
+		})
+	}
 
 	//add listener
 	watcher.AddListener(switchRouter(serviceManager))
 
+	if apiConf := configManager.GetStaticConfig().API; apiConf != nil {
+		apiHandler, err := adminApi.NewHandler(apiConf, configManager.GetDynamicConfig, routerManager, upstreamManager.GetUpstream(), routeProvider)
+		if err != nil {
+			log.Log.WithError(err).Error("init admin api fail")
+			return err
+		}
+		routinesPool.GoCtx(func(ctx context.Context) {
+			if err := apiHandler.ListenAndServe(ctx); err != nil {
+				log.Log.WithError(err).Error("admin api server fail")
+			}
+		})
+	}
+
 	db_init.SetupDb(dyConfig.Databases)
 
 	svr := server.NewServer(server.WithServiceManager(serviceManager),
@@ -135,3 +216,17 @@ func switchRouter(serviceManager *servers.ServiceManager) func(conf dynamic.Conf
 		serviceManager.SwitchFastHttpRouter(conf)
 	}
 }
+
+// newApiProviderStore 根据配置选择api provider的持久化方式, 未配置时只保存在内存中
+func newApiProviderStore(conf *static.APIPersistence) apiProvider.Store {
+	switch {
+	case conf == nil:
+		return nil
+	case conf.Database:
+		return dataProvider.NewApiProviderDbStore()
+	case conf.File != "":
+		return apiProvider.NewFileStore(conf.File)
+	default:
+		return nil
+	}
+}
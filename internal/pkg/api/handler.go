@@ -0,0 +1,261 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	upstreams "go-faster-gateway/internal/pkg/balancer"
+	"go-faster-gateway/internal/pkg/ecode"
+	"go-faster-gateway/internal/pkg/router"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/config/static"
+	"go-faster-gateway/pkg/log"
+	"go-faster-gateway/pkg/poxyResource/balancer"
+	apiProvider "go-faster-gateway/pkg/provider/api"
+	"go-faster-gateway/pkg/redactor"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/buaazp/fasthttprouter"
+	"github.com/valyala/fasthttp"
+)
+
+const bearerPrefix = "Bearer "
+
+// Handler 管理接口, 在单独的入口上提供网关运行时信息查询
+type Handler struct {
+	conf          *static.API
+	getConfig     func() (*dynamic.Configuration, error)
+	routerManager *router.RouterManager
+	upstream      *balancer.Upstream
+	provider      *apiProvider.Provider
+	router        *fasthttprouter.Router
+}
+
+// NewHandler 创建管理接口, 必须配置token. provider为空时只提供查询接口
+func NewHandler(conf *static.API, getConfig func() (*dynamic.Configuration, error),
+	routerManager *router.RouterManager, upstream *balancer.Upstream, provider *apiProvider.Provider) (*Handler, error) {
+	if conf.Token == "" {
+		return nil, errors.New("api: token is required")
+	}
+	conf.SetDefaults()
+	h := &Handler{
+		conf:          conf,
+		getConfig:     getConfig,
+		routerManager: routerManager,
+		upstream:      upstream,
+		provider:      provider,
+		router:        fasthttprouter.New(),
+	}
+	h.router.GET("/api/rawdata", h.getRawData)
+	h.router.GET("/api/routers", h.getRouters)
+	h.router.GET("/api/upstreams", h.getUpstreams)
+	h.router.PUT("/api/upstreams/:service", h.setUpstreamNodes)
+	h.router.GET("/api/middlewares", h.getMiddlewares)
+	if provider != nil {
+		h.registerProviderRoutes()
+	}
+	return h, nil
+}
+
+// Handle 管理接口入口, 先校验token
+func (h *Handler) Handle(ctx *fasthttp.RequestCtx) {
+	auth := string(ctx.Request.Header.Peek(fasthttp.HeaderAuthorization))
+	token := strings.TrimPrefix(auth, bearerPrefix)
+	if len(token) == len(auth) || subtle.ConstantTimeCompare([]byte(token), []byte(h.conf.Token)) != 1 {
+		ctx.Response.Header.Set(fasthttp.HeaderWWWAuthenticate, `Bearer realm="gateway-api"`)
+		writeError(ctx, ecode.UnauthorizedErr)
+		return
+	}
+	h.router.Handler(ctx)
+}
+
+// ListenAndServe 启动管理接口, ctx结束时关闭
+func (h *Handler) ListenAndServe(ctx context.Context) error {
+	srv := &fasthttp.Server{
+		Handler:      h.Handle,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown()
+	}()
+	log.Log.Infof("admin api listening on %s", h.conf.EntryPoint)
+	return srv.ListenAndServe(h.conf.EntryPoint)
+}
+
+// getRawData 当前生效的动态配置, 敏感字段已脱敏
+func (h *Handler) getRawData(ctx *fasthttp.RequestCtx) {
+	conf, err := h.getConfig()
+	if err != nil {
+		log.Log.WithError(err).Error("api: get dynamic config fail")
+		writeError(ctx, ecode.InternalServerErrorErr)
+		return
+	}
+	data, err := redactor.Anonymize(conf)
+	if err != nil {
+		log.Log.WithError(err).Error("api: anonymize dynamic config fail")
+		writeError(ctx, ecode.InternalServerErrorErr)
+		return
+	}
+	ctx.SetContentType("application/json")
+	ctx.SetBodyString(data)
+}
+
+type routersRepresentation struct {
+	Md5    string                  `json:"md5"`
+	Routes []*dynamic.ServiceRoute `json:"routes"`
+}
+
+func (h *Handler) getRouters(ctx *fasthttp.RequestCtx) {
+	result := routersRepresentation{Routes: []*dynamic.ServiceRoute{}}
+	if r := h.routerManager.GetRouter(); r != nil {
+		result.Md5 = r.GetMd5()
+		result.Routes = r.GetRoutes()
+	}
+	writeJSON(ctx, result)
+}
+
+type upstreamRepresentation struct {
+	Service string               `json:"service"`
+	Nodes   []nodeRepresentation `json:"nodes"`
+}
+
+type nodeRepresentation struct {
+	Scheme   string `json:"scheme"`
+	Host     string `json:"host"`
+	Port     uint32 `json:"port"`
+	Weight   int32  `json:"weight"`
+	Healthy  bool   `json:"healthy"`
+	InFlight int64  `json:"inFlight"`
+}
+
+func (h *Handler) getUpstreams(ctx *fasthttp.RequestCtx) {
+	result := make([]upstreamRepresentation, 0)
+	for service, nodes := range h.upstream.Nodes() {
+		u := upstreamRepresentation{Service: service, Nodes: make([]nodeRepresentation, 0, len(nodes))}
+		for _, n := range nodes {
+			scheme := balancer.SchemeHTTP
+			if n.IsTLS() {
+				scheme = balancer.SchemeHTTPS
+			}
+			u.Nodes = append(u.Nodes, nodeRepresentation{
+				Scheme:   scheme,
+				Host:     n.Service,
+				Port:     n.Port,
+				Weight:   n.Weight,
+				Healthy:  n.Healthy,
+				InFlight: n.InFlight(),
+			})
+		}
+		result = append(result, u)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Service < result[j].Service })
+	writeJSON(ctx, result)
+}
+
+type upstreamNodesRequest struct {
+	BalanceMode string           `json:"balanceMode"`
+	Servers     []dynamic.Server `json:"servers"`
+}
+
+// setUpstreamNodes 直接替换服务在负载均衡器中的全部结点, 不修改配置. 在服务的配置变化之前有效, 之后以配置为准
+func (h *Handler) setUpstreamNodes(ctx *fasthttp.RequestCtx) {
+	service, _ := ctx.UserValue("service").(string)
+	var req upstreamNodesRequest
+	if err := json.Unmarshal(ctx.PostBody(), &req); err != nil || service == "" {
+		writeError(ctx, ecode.InvalidParamErr)
+		return
+	}
+	for _, s := range req.Servers {
+		if !validServer(s) {
+			writeError(ctx, ecode.InvalidParamErr)
+			return
+		}
+	}
+	// 没有单独配置scheme的结点沿用服务路由的协议, 和配置中的结点使用同一套连接池
+	nodes := upstreams.ToNodes(req.Servers, h.serviceScheme(service))
+	if err := h.upstream.SetNodes(balancer.NodeServer{ServiceName: service, Nodes: nodes, Algorithm: req.BalanceMode}); err != nil {
+		log.Log.WithError(err).Errorf("api: set nodes of service %s fail", service)
+		writeError(ctx, ecode.InvalidParamErr)
+		return
+	}
+	ctx.SetStatusCode(fasthttp.StatusNoContent)
+}
+
+// serviceScheme 当前路由中服务连接上游使用的协议
+func (h *Handler) serviceScheme(service string) string {
+	if h.routerManager == nil {
+		return ""
+	}
+	r := h.routerManager.GetRouter()
+	if r == nil {
+		return ""
+	}
+	for _, route := range r.GetRoutes() {
+		if route.ServiceName == service {
+			return route.Scheme
+		}
+	}
+	return ""
+}
+
+type middlewareRepresentation struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func (h *Handler) getMiddlewares(ctx *fasthttp.RequestCtx) {
+	var configured map[string]*dynamic.Middleware
+	if conf, err := h.getConfig(); err == nil && conf != nil {
+		configured = make(map[string]*dynamic.Middleware, len(conf.Middlewares))
+		for k, v := range conf.Middlewares {
+			configured[strings.ToLower(k)] = v
+		}
+	}
+	result := make([]middlewareRepresentation, 0)
+	for _, name := range h.routerManager.GetMiddlewareNames() {
+		mwType := "builtin"
+		if m, ok := configured[name]; ok {
+			mwType = middlewareType(m)
+		}
+		result = append(result, middlewareRepresentation{Name: name, Type: mwType})
+	}
+	writeJSON(ctx, result)
+}
+
+// middlewareType 中间件配置中第一个非空配置项的名称
+func middlewareType(m *dynamic.Middleware) string {
+	if m == nil {
+		return ""
+	}
+	v := reflect.ValueOf(m).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.Kind() == reflect.Ptr && !f.IsNil() {
+			name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+			return name
+		}
+	}
+	return ""
+}
+
+func writeJSON(ctx *fasthttp.RequestCtx, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Log.WithError(err).Error("api: marshal response fail")
+		writeError(ctx, ecode.InternalServerErrorErr)
+		return
+	}
+	ctx.SetContentType("application/json")
+	ctx.SetBody(data)
+}
+
+func writeError(ctx *fasthttp.RequestCtx, e *ecode.Response) {
+	ctx.SetStatusCode(e.HttpCode)
+	ctx.SetContentType("application/json")
+	ctx.SetBodyString(e.Data())
+}
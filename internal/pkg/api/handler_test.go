@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"go-faster-gateway/internal/pkg/router"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/config/static"
+	"go-faster-gateway/pkg/poxyResource/balancer"
+	apiProvider "go-faster-gateway/pkg/provider/api"
+)
+
+func TestHandler(t *testing.T) {
+	conf := &dynamic.Configuration{
+		Middlewares: map[string]*dynamic.Middleware{
+			"userJwt": {JwtAuth: &dynamic.JwtAuth{Secret: "top-secret", Issuer: "gateway"}},
+		},
+	}
+	upstream := &balancer.Upstream{}
+	if err := upstream.SetNodes(balancer.NodeServer{ServiceName: "order_http", Nodes: []*balancer.Node{{Service: "10.0.0.1", Port: 80, Weight: 1, Healthy: true}}, Algorithm: balancer.R2Balancer}); err != nil {
+		t.Fatal(err)
+	}
+	upstream.Inc("order_http", "http://10.0.0.1:80")
+
+	h, err := NewHandler(&static.API{Token: "admin"}, func() (*dynamic.Configuration, error) { return conf, nil },
+		router.NewRouterManager(nil, nil), upstream, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := func(path, token string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(path)
+		if token != "" {
+			ctx.Request.Header.Set(fasthttp.HeaderAuthorization, "Bearer "+token)
+		}
+		h.Handle(ctx)
+		return ctx
+	}
+
+	if ctx := call("/api/rawdata", ""); ctx.Response.StatusCode() != fasthttp.StatusUnauthorized {
+		t.Errorf("missing token status = %d", ctx.Response.StatusCode())
+	}
+	if ctx := call("/api/rawdata", "wrong"); ctx.Response.StatusCode() != fasthttp.StatusUnauthorized {
+		t.Errorf("wrong token status = %d", ctx.Response.StatusCode())
+	}
+
+	ctx := call("/api/rawdata", "admin")
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || strings.Contains(string(ctx.Response.Body()), "top-secret") {
+		t.Errorf("rawdata status %d body %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+
+	var upstreams []upstreamRepresentation
+	if err = json.Unmarshal(call("/api/upstreams", "admin").Response.Body(), &upstreams); err != nil {
+		t.Fatal(err)
+	}
+	if len(upstreams) != 1 || len(upstreams[0].Nodes) != 1 || upstreams[0].Nodes[0].InFlight != 1 || !upstreams[0].Nodes[0].Healthy {
+		t.Errorf("unexpected upstreams %+v", upstreams)
+	}
+
+	put := func(path, body string) int {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(fasthttp.MethodPut)
+		ctx.Request.SetRequestURI(path)
+		ctx.Request.SetBodyString(body)
+		ctx.Request.Header.Set(fasthttp.HeaderAuthorization, "Bearer admin")
+		h.Handle(ctx)
+		return ctx.Response.StatusCode()
+	}
+	if status := put("/api/upstreams/order_http", `{"servers":[{"host":"10.0.0.2","port":80,"weight":1,"healthy":true}]}`); status != fasthttp.StatusNoContent {
+		t.Errorf("set upstream nodes status = %d", status)
+	}
+	if status := put("/api/upstreams/order_http", `{"servers":[{"host":"10.0.0.2"}]}`); status != fasthttp.StatusBadRequest {
+		t.Errorf("invalid upstream nodes status = %d", status)
+	}
+	if nodes := upstream.Nodes()["order_http"]; len(nodes) != 1 || nodes[0].Service != "10.0.0.2" {
+		t.Errorf("nodes should be replaced, got %+v", nodes)
+	}
+
+	var routers routersRepresentation
+	if err = json.Unmarshal(call("/api/routers", "admin").Response.Body(), &routers); err != nil || len(routers.Routes) != 0 {
+		t.Errorf("routers %+v, err %v", routers, err)
+	}
+
+	if middlewareType(conf.Middlewares["userJwt"]) != "jwtAuth" {
+		t.Errorf("middleware type = %q", middlewareType(conf.Middlewares["userJwt"]))
+	}
+
+	if _, err = NewHandler(&static.API{}, nil, nil, nil, nil); err == nil {
+		t.Error("expected error without token")
+	}
+}
+
+func TestSetUpstreamNodesScheme(t *testing.T) {
+	conf := dynamic.Configuration{
+		EasyServiceRoute: &dynamic.ServiceRouteConfiguration{
+			Services: map[string]map[string]*dynamic.ServiceRoute{"order": {"http": {
+				Handler: "http",
+				Scheme:  balancer.SchemeHTTPS,
+				Routers: []dynamic.Router{{Path: "/orders", Methods: []string{"GET"}}},
+			}}},
+		},
+	}
+	routerManager := router.NewRouterManager(nil, nil)
+	if err := routerManager.CreateRouters(context.Background(), conf); err != nil {
+		t.Fatal(err)
+	}
+	upstream := &balancer.Upstream{}
+	h, err := NewHandler(&static.API{Token: "admin"}, nil, routerManager, upstream, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPut)
+	ctx.Request.SetRequestURI("/api/upstreams/order_http")
+	ctx.Request.SetBodyString(`{"servers":[{"host":"10.0.0.1","port":443,"weight":1,"healthy":true},{"host":"10.0.0.2","port":80,"weight":1,"healthy":true,"scheme":"http"}]}`)
+	ctx.Request.Header.Set(fasthttp.HeaderAuthorization, "Bearer admin")
+	h.Handle(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusNoContent {
+		t.Fatalf("set upstream nodes status = %d", ctx.Response.StatusCode())
+	}
+	// 没有配置scheme的结点沿用路由的https
+	nodes := upstream.Nodes()["order_http"]
+	if len(nodes) != 2 || nodes[0].Scheme != balancer.SchemeHTTPS || nodes[1].Scheme != balancer.SchemeHTTP {
+		t.Errorf("unexpected nodes %+v", nodes)
+	}
+}
+
+func TestProviderRoutes(t *testing.T) {
+	conf := &dynamic.Configuration{
+		EasyServiceRoute: &dynamic.ServiceRouteConfiguration{
+			Services: map[string]map[string]*dynamic.ServiceRoute{"user": {"user_http": {}}},
+		},
+	}
+	provider := apiProvider.New(nil)
+	h, err := NewHandler(&static.API{Token: "admin"}, func() (*dynamic.Configuration, error) { return conf, nil },
+		router.NewRouterManager(nil, nil), &balancer.Upstream{}, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := func(method, path, body string) int {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(method)
+		ctx.Request.SetRequestURI(path)
+		ctx.Request.SetBodyString(body)
+		ctx.Request.Header.Set(fasthttp.HeaderAuthorization, "Bearer admin")
+		h.Handle(ctx)
+		return ctx.Response.StatusCode()
+	}
+
+	route := `{"handler":"http","routers":[{"path":"/order"}],"servers":[{"host":"10.0.0.1","port":80,"healthy":true}]}`
+	steps := []struct {
+		method, path, body string
+		status             int
+	}{
+		{fasthttp.MethodPost, "/api/provider/routes/order/order_http", route, fasthttp.StatusCreated},
+		{fasthttp.MethodPost, "/api/provider/routes/order/order_http", route, fasthttp.StatusConflict},
+		// 其他provider定义的路由
+		{fasthttp.MethodPost, "/api/provider/routes/user/user_http", route, fasthttp.StatusConflict},
+		{fasthttp.MethodPost, "/api/provider/routes/order/order_ws", `{"handler":"tcp"}`, fasthttp.StatusBadRequest},
+		{fasthttp.MethodPut, "/api/provider/routes/order/missing", route, fasthttp.StatusNotFound},
+		{fasthttp.MethodPost, "/api/provider/routes/order/order_http/servers", `{"host":"10.0.0.2","port":80,"healthy":true}`, fasthttp.StatusCreated},
+		{fasthttp.MethodPut, "/api/provider/routes/order/order_http/servers/10.0.0.1/80/health", `{"healthy":false}`, fasthttp.StatusNoContent},
+		{fasthttp.MethodPut, "/api/provider/routes/order/order_http/servers/10.0.0.1/80/health", `{}`, fasthttp.StatusBadRequest},
+		{fasthttp.MethodDelete, "/api/provider/routes/order/order_http/servers/10.0.0.2/80", "", fasthttp.StatusNoContent},
+		{fasthttp.MethodDelete, "/api/provider/routes/order/order_http/servers/10.0.0.2/80", "", fasthttp.StatusNotFound},
+	}
+	for _, s := range steps {
+		if status := call(s.method, s.path, s.body); status != s.status {
+			t.Errorf("%s %s status = %d, want %d", s.method, s.path, status, s.status)
+		}
+	}
+
+	r, ok := provider.Route("order", "order_http")
+	if !ok || len(r.Servers) != 1 || r.Servers[0].Healthy {
+		t.Errorf("unexpected route %+v", r)
+	}
+	if status := call(fasthttp.MethodDelete, "/api/provider/routes/order/order_http", ""); status != fasthttp.StatusNoContent {
+		t.Errorf("delete route status = %d", status)
+	}
+	if len(provider.Routes()) != 0 {
+		t.Errorf("expected no routes, got %+v", provider.Routes())
+	}
+}
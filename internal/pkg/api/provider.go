@@ -0,0 +1,197 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	upstreams "go-faster-gateway/internal/pkg/balancer"
+	"go-faster-gateway/internal/pkg/constants"
+	"go-faster-gateway/internal/pkg/ecode"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/log"
+	apiProvider "go-faster-gateway/pkg/provider/api"
+	"strconv"
+
+	"github.com/valyala/fasthttp"
+)
+
+// registerProviderRoutes 写接口, 所有变更都交给api provider, 由配置监听统一重载
+func (h *Handler) registerProviderRoutes() {
+	h.router.GET("/api/provider/routes", h.listProviderRoutes)
+	h.router.GET("/api/provider/routes/:service/:route", h.getProviderRoute)
+	h.router.POST("/api/provider/routes/:service/:route", h.createProviderRoute)
+	h.router.PUT("/api/provider/routes/:service/:route", h.updateProviderRoute)
+	h.router.DELETE("/api/provider/routes/:service/:route", h.deleteProviderRoute)
+	h.router.POST("/api/provider/routes/:service/:route/servers", h.addProviderServer)
+	h.router.DELETE("/api/provider/routes/:service/:route/servers/:host/:port", h.drainProviderServer)
+	h.router.PUT("/api/provider/routes/:service/:route/servers/:host/:port/health", h.setProviderServerHealth)
+}
+
+func (h *Handler) listProviderRoutes(ctx *fasthttp.RequestCtx) {
+	writeJSON(ctx, h.provider.Routes())
+}
+
+func (h *Handler) getProviderRoute(ctx *fasthttp.RequestCtx) {
+	service, name := routeParams(ctx)
+	route, ok := h.provider.Route(service, name)
+	if !ok {
+		writeError(ctx, ecode.RouteNotFoundErr)
+		return
+	}
+	writeJSON(ctx, route)
+}
+
+func (h *Handler) createProviderRoute(ctx *fasthttp.RequestCtx) {
+	service, name := routeParams(ctx)
+	route, ok := decodeRoute(ctx)
+	if !ok {
+		return
+	}
+	// 其他provider已经定义的路由不能被覆盖, 合并配置时结果不确定
+	if conf, err := h.getConfig(); err == nil && conf != nil && conf.EasyServiceRoute != nil {
+		if _, exists := conf.EasyServiceRoute.Services[service][name]; exists {
+			writeError(ctx, ecode.RouteConflictErr)
+			return
+		}
+	}
+	if h.providerResult(ctx, h.provider.CreateRoute(service, name, route)) {
+		ctx.SetStatusCode(fasthttp.StatusCreated)
+		writeJSON(ctx, route)
+	}
+}
+
+func (h *Handler) updateProviderRoute(ctx *fasthttp.RequestCtx) {
+	service, name := routeParams(ctx)
+	route, ok := decodeRoute(ctx)
+	if !ok {
+		return
+	}
+	if h.providerResult(ctx, h.provider.UpdateRoute(service, name, route)) {
+		writeJSON(ctx, route)
+	}
+}
+
+func (h *Handler) deleteProviderRoute(ctx *fasthttp.RequestCtx) {
+	service, name := routeParams(ctx)
+	if h.providerResult(ctx, h.provider.DeleteRoute(service, name)) {
+		ctx.SetStatusCode(fasthttp.StatusNoContent)
+	}
+}
+
+func (h *Handler) addProviderServer(ctx *fasthttp.RequestCtx) {
+	service, name := routeParams(ctx)
+	var server dynamic.Server
+	if err := json.Unmarshal(ctx.PostBody(), &server); err != nil || !validServer(server) {
+		writeError(ctx, ecode.InvalidParamErr)
+		return
+	}
+	if h.providerResult(ctx, h.provider.AddServer(service, name, server)) {
+		ctx.SetStatusCode(fasthttp.StatusCreated)
+		writeJSON(ctx, server)
+	}
+}
+
+func (h *Handler) drainProviderServer(ctx *fasthttp.RequestCtx) {
+	service, name := routeParams(ctx)
+	host, port, ok := serverParams(ctx)
+	if !ok {
+		return
+	}
+	if h.providerResult(ctx, h.provider.DrainServer(service, name, host, port)) {
+		ctx.SetStatusCode(fasthttp.StatusNoContent)
+	}
+}
+
+type healthRepresentation struct {
+	Healthy *bool `json:"healthy"`
+}
+
+func (h *Handler) setProviderServerHealth(ctx *fasthttp.RequestCtx) {
+	service, name := routeParams(ctx)
+	host, port, ok := serverParams(ctx)
+	if !ok {
+		return
+	}
+	var health healthRepresentation
+	if err := json.Unmarshal(ctx.PostBody(), &health); err != nil || health.Healthy == nil {
+		writeError(ctx, ecode.InvalidParamErr)
+		return
+	}
+	if h.providerResult(ctx, h.provider.SetServerHealth(service, name, host, port, *health.Healthy)) {
+		ctx.SetStatusCode(fasthttp.StatusNoContent)
+	}
+}
+
+// providerResult 把provider的错误转换成管理接口的错误响应, 成功时返回true
+func (h *Handler) providerResult(ctx *fasthttp.RequestCtx, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, apiProvider.ErrRouteNotFound):
+		writeError(ctx, ecode.RouteNotFoundErr)
+	case errors.Is(err, apiProvider.ErrRouteExists):
+		writeError(ctx, ecode.RouteConflictErr)
+	case errors.Is(err, apiProvider.ErrServerNotFound):
+		writeError(ctx, ecode.ServerNotFoundErr)
+	case errors.Is(err, apiProvider.ErrServerExists):
+		writeError(ctx, ecode.ServerConflictErr)
+	default:
+		log.Log.WithError(err).Error("api: persist provider routes fail")
+		writeError(ctx, ecode.InternalServerErrorErr)
+	}
+	return false
+}
+
+func routeParams(ctx *fasthttp.RequestCtx) (string, string) {
+	return userValue(ctx, "service"), userValue(ctx, "route")
+}
+
+func serverParams(ctx *fasthttp.RequestCtx) (string, uint64, bool) {
+	host := userValue(ctx, "host")
+	port, err := strconv.ParseUint(userValue(ctx, "port"), 10, 16)
+	if err != nil || host == "" || port == 0 {
+		writeError(ctx, ecode.InvalidParamErr)
+		return "", 0, false
+	}
+	return host, port, true
+}
+
+func userValue(ctx *fasthttp.RequestCtx, key string) string {
+	v, _ := ctx.UserValue(key).(string)
+	return v
+}
+
+// decodeRoute 解析并校验请求中的路由, 校验失败时已经写入错误响应
+func decodeRoute(ctx *fasthttp.RequestCtx) (*dynamic.ServiceRoute, bool) {
+	route := new(dynamic.ServiceRoute)
+	if err := json.Unmarshal(ctx.PostBody(), route); err != nil || !validRoute(route) {
+		writeError(ctx, ecode.InvalidParamErr)
+		return nil, false
+	}
+	return route, true
+}
+
+func validRoute(route *dynamic.ServiceRoute) bool {
+	switch route.Handler {
+	case constants.Http, constants.Https, constants.WebSocket:
+	default:
+		return false
+	}
+	if len(route.Routers) == 0 {
+		return false
+	}
+	for _, r := range route.Routers {
+		if r.Path == "" && r.Prefix == "" {
+			return false
+		}
+	}
+	for _, s := range route.Servers {
+		if !validServer(s) {
+			return false
+		}
+	}
+	return true
+}
+
+func validServer(server dynamic.Server) bool {
+	return server.Host != "" && server.Port > 0 && server.Port <= 65535 && upstreams.ValidScheme(server.Scheme)
+}
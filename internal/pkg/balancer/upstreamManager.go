@@ -1,61 +1,185 @@
 package balancer
 
 import (
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"go-faster-gateway/internal/pkg/ecode"
 	"go-faster-gateway/pkg/config/dynamic"
-	"go-faster-gateway/pkg/log"
 	"go-faster-gateway/pkg/poxyResource/balancer"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
 )
 
 // UpstreamManager
 type UpstreamManager struct {
 	Upstreams *balancer.Upstream // 上游服务，一般路由会保存上游服务的名称，转发到对应的上游服务上去，可以使用负载均衡算法
+
+	mu         sync.Mutex
+	transports map[string]*serversTransport // 按名称缓存的tls配置, 配置没有变化时复用同一个对象, 结点的连接池不需要重建
+}
+
+type serversTransport struct {
+	conf      *dynamic.ServersTransport
+	tlsConfig *tls.Config
 }
 
 func NewUpstreamManager() *UpstreamManager {
-	return &UpstreamManager{
-		Upstreams: &balancer.Upstream{
-			LB:        make(map[string]balancer.Balancer),
-			SyncNodes: make(chan balancer.NodeServer, 1),
-		},
-	}
-}
-
-// GetLBUpstream 获取负载均衡后的上游服务
-func (f *UpstreamManager) GetLBUpstream(serviceName string, routerInfo *dynamic.ServiceRoute) (string, error) {
-	var (
-		us  string
-		err error
-	)
-	us, err = f.Upstreams.GetNextUpstream(serviceName)
-	if err != nil && !errors.Is(err, ecode.UpstreamNotInit) {
-		return "", err
-	}
-	var modelNode = func(modelMap []dynamic.Server) []*balancer.Node {
-		nodes := make([]*balancer.Node, 0)
-		for _, v := range modelMap {
-			node := &balancer.Node{
-				Service: v.Host,
-				Port:    uint32(v.Port),
-				Weight:  int32(v.Weight),
-				Healthy: v.Healthy,
+	// 服务发现和管理接口通过Upstreams.SetNodes推送结点变化, 直接更新负载均衡器, 不需要重建路由
+	return &UpstreamManager{Upstreams: &balancer.Upstream{}}
+}
+
+// GetLBUpstream 获取负载均衡后的上游结点, 结点上带有连接池. 注册表按配置版本构建, 请求路径上只读取.
+// 不在注册表中的服务(加载失败或者配置切换的间隙已经移除)不按路由配置临时转发, 返回服务不可用
+func (f *UpstreamManager) GetLBUpstream(serviceName string) (*balancer.Node, error) {
+	node, err := f.Upstreams.NextNode(serviceName)
+	if errors.Is(err, ecode.UpstreamNotInit) {
+		return nil, ecode.UpstreamUnavailableErr
+	}
+	return node, err
+}
+
+// Reload 配置变化后按新的路由重建注册表, 同一个服务的多个路由的结点合并, 策略, 协议和连接池取第一个路由的配置.
+// 配置有误的服务被跳过, 错误合并返回
+func (f *UpstreamManager) Reload(routes []*dynamic.ServiceRoute, transports map[string]*dynamic.ServersTransport) error {
+	tlsConfigs, errs := f.loadTransports(transports)
+	var services []balancer.NodeServer
+	index := make(map[string]int)
+	skipped := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, route := range routes {
+		if skipped[route.ServiceName] {
+			continue
+		}
+		i, ok := index[route.ServiceName]
+		if !ok {
+			ns, err := nodeServer(route, tlsConfigs)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("service %s: %w", route.ServiceName, err))
+				skipped[route.ServiceName] = true
+				continue
 			}
-			nodes = append(nodes, node)
+			i = len(services)
+			index[route.ServiceName] = i
+			services = append(services, ns)
+		}
+		for _, node := range ToNodes(route.Servers, route.Scheme) {
+			key := route.ServiceName + "/" + node.Scheme + "://" + node.Addr()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			services[i].Nodes = append(services[i].Nodes, node)
+		}
+	}
+	if err := f.Upstreams.Reload(services); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func nodeServer(route *dynamic.ServiceRoute, tlsConfigs map[string]*tls.Config) (balancer.NodeServer, error) {
+	ns := balancer.NodeServer{
+		ServiceName: route.ServiceName,
+		Algorithm:   route.BalanceMode,
+		Client:      clientOptions(route.Client),
+	}
+	if !ValidScheme(route.Scheme) {
+		return ns, fmt.Errorf("unsupported scheme %q", route.Scheme)
+	}
+	for _, s := range route.Servers {
+		if !ValidScheme(s.Scheme) {
+			return ns, fmt.Errorf("server %s:%d: unsupported scheme %q", s.Host, s.Port, s.Scheme)
+		}
+	}
+	if route.ServersTransport != "" {
+		tlsConfig, ok := tlsConfigs[route.ServersTransport]
+		if !ok {
+			return ns, fmt.Errorf("serversTransport %q not found", route.ServersTransport)
+		}
+		if ns.Client == nil {
+			ns.Client = &balancer.ClientOptions{}
 		}
-		return nodes
+		ns.Client.TLSConfig = tlsConfig
 	}
-	nodes := modelNode(routerInfo.Servers)
-	err = f.Upstreams.AddToLB(serviceName, nodes, routerInfo.BalanceMode)
-	if err != nil {
-		log.Log.WithError(err).Error("AddToLB fail")
-		return us, err
+	return ns, nil
+}
+
+// loadTransports 创建serversTransport的tls配置, 和上一个版本相同的配置复用原来的对象
+func (f *UpstreamManager) loadTransports(transports map[string]*dynamic.ServersTransport) (map[string]*tls.Config, []error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var errs []error
+	loaded := make(map[string]*serversTransport, len(transports))
+	tlsConfigs := make(map[string]*tls.Config, len(transports))
+	for name, conf := range transports {
+		if conf == nil {
+			continue
+		}
+		t, ok := f.transports[name]
+		if !ok || !reflect.DeepEqual(t.conf, conf) {
+			tlsConfig, err := conf.TLS.CreateTLSConfig()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("serversTransport %s: %w", name, err))
+				continue
+			}
+			t = &serversTransport{conf: conf, tlsConfig: tlsConfig}
+		}
+		loaded[name] = t
+		tlsConfigs[name] = t.tlsConfig
 	}
-	us, err = f.Upstreams.GetNextUpstream(serviceName)
-	return us, err
+	f.transports = loaded
+	return tlsConfigs, errs
 }
 
 // 获取上游信息
 func (f *UpstreamManager) GetUpstream() *balancer.Upstream {
 	return f.Upstreams
 }
+
+// ToNodes 把配置中的结点转换成负载均衡器的结点, 结点没有配置协议时使用scheme
+func ToNodes(servers []dynamic.Server, scheme string) []*balancer.Node {
+	nodes := make([]*balancer.Node, 0, len(servers))
+	for _, v := range servers {
+		s := v.Scheme
+		if s == "" {
+			s = scheme
+		}
+		if s == "" {
+			s = balancer.SchemeHTTP
+		}
+		nodes = append(nodes, &balancer.Node{
+			Service: v.Host,
+			Port:    uint32(v.Port),
+			Weight:  int32(v.Weight),
+			Healthy: v.Healthy,
+			Scheme:  strings.ToLower(s),
+		})
+	}
+	return nodes
+}
+
+// ValidScheme 连接上游结点支持的协议, 为空时使用http
+func ValidScheme(scheme string) bool {
+	switch strings.ToLower(scheme) {
+	case "", balancer.SchemeHTTP, balancer.SchemeHTTPS:
+		return true
+	}
+	return false
+}
+
+func clientOptions(c *dynamic.UpstreamClient) *balancer.ClientOptions {
+	if c == nil {
+		return nil
+	}
+	return &balancer.ClientOptions{
+		MaxConns:            c.MaxConns,
+		MaxIdleConnDuration: time.Duration(c.MaxIdleConnDuration),
+		MaxConnWaitTimeout:  time.Duration(c.MaxConnWaitTimeout),
+		ReadBufferSize:      c.ReadBufferSize,
+		WriteBufferSize:     c.WriteBufferSize,
+		DialDualStack:       c.DialDualStack,
+	}
+}
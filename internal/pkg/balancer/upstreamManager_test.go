@@ -0,0 +1,137 @@
+package balancer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go-faster-gateway/internal/pkg/ecode"
+	"go-faster-gateway/pkg/config/dynamic"
+)
+
+// clientCert 生成自签名的客户端证书, 返回pem格式的证书和私钥
+func clientCert(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestUpstreamTLS(t *testing.T) {
+	certPEM, keyPEM := clientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AppendCertsFromPEM(certPEM)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.ServerName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	serverCA := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	transports := func() map[string]*dynamic.ServersTransport {
+		return map[string]*dynamic.ServersTransport{"mtls": {TLS: &dynamic.ClientTLS{
+			CA:         string(serverCA),
+			Cert:       string(certPEM),
+			Key:        string(keyPEM),
+			ServerName: "example.com",
+		}}}
+	}
+	route := &dynamic.ServiceRoute{
+		ServiceName:      "order",
+		Scheme:           "https",
+		ServersTransport: "mtls",
+		Servers:          []dynamic.Server{{Host: addr.IP.String(), Port: uint64(addr.Port), Weight: 1, Healthy: true}},
+	}
+	m := NewUpstreamManager()
+	if err := m.Reload([]*dynamic.ServiceRoute{route}, transports()); err != nil {
+		t.Fatal(err)
+	}
+	node, err := m.GetLBUpstream("order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+	req.SetRequestURI(node.URL("/"))
+	if err = node.Client().DoTimeout(req, resp, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode() != fasthttp.StatusOK || string(resp.Body()) != "example.com" {
+		t.Errorf("unexpected response %d %s", resp.StatusCode(), resp.Body())
+	}
+
+	// 相同的serversTransport复用tls配置, 连接池不重建
+	if err = m.Reload([]*dynamic.ServiceRoute{route}, transports()); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := m.GetLBUpstream("order"); n.Client() != node.Client() {
+		t.Error("client should be reused when the serversTransport is unchanged")
+	}
+
+	// 没有serversTransport时使用系统的ca证书, 校验失败
+	plain := *route
+	plain.ServersTransport = ""
+	if err = m.Reload([]*dynamic.ServiceRoute{&plain}, nil); err != nil {
+		t.Fatal(err)
+	}
+	n, _ := m.GetLBUpstream("order")
+	if err = n.Client().DoTimeout(req, resp, 5*time.Second); err == nil {
+		t.Error("expected certificate verification error without the serversTransport")
+	}
+
+	missing := *route
+	missing.ServersTransport = "missing"
+	invalid := &dynamic.ServiceRoute{ServiceName: "user", Scheme: "ftp"}
+	if err = m.Reload([]*dynamic.ServiceRoute{&missing, invalid}, transports()); err == nil {
+		t.Error("expected errors for missing serversTransport and invalid scheme")
+	}
+	if nodes := m.GetUpstream().Nodes(); len(nodes) != 0 {
+		t.Errorf("invalid services should be skipped, got %+v", nodes)
+	}
+	// 加载失败的服务不按路由配置临时转发, 否则会绕过serversTransport
+	if _, err = m.GetLBUpstream("order"); !errors.Is(err, ecode.UpstreamUnavailableErr) {
+		t.Errorf("failed service should be unavailable, got %v", err)
+	}
+	// 不在注册表中的服务(路由切换的间隙)同样不可用, 不临时创建结点
+	if _, err = m.GetLBUpstream("removed"); !errors.Is(err, ecode.UpstreamUnavailableErr) {
+		t.Errorf("service missing from the registry should be unavailable, got %v", err)
+	}
+	if u := ToNodes([]dynamic.Server{{Host: "10.0.0.1", Port: 80, Scheme: "HTTP"}}, "https")[0]; u.URL("/a") != "http://10.0.0.1:80/a" {
+		t.Errorf("server scheme should override the route scheme, got %s", u.URL("/a"))
+	}
+}
@@ -1,6 +1,8 @@
 package database
 
 import (
+	"fmt"
+	"go-faster-gateway/internal/pkg/data/tables"
 	"go-faster-gateway/pkg/database"
 	"go-faster-gateway/pkg/log"
 	slogger "go-faster-gateway/pkg/log/logger"
@@ -18,6 +20,27 @@ func SetupDb(dbConfig *database.Database) {
 }
 
 func setupSimpleDatabase(c *database.Database) {
+	db, err := OpenDb(c)
+	if err != nil {
+		log.Log.WithError(err).Fatal(c.Driver + " connect error :")
+	} else {
+		log.Log.Info(c.Driver + " connect success !")
+	}
+	if c.AutoMigrate {
+		if err = db.AutoMigrate(tables.All()...); err != nil {
+			log.Log.WithError(err).Error(c.Driver + " auto migrate error")
+		}
+	}
+	// 初始化
+	gplus.Init(db)
+}
+
+// OpenDb 根据Driver选择驱动打开数据库, 读写分离对所有驱动都生效
+func OpenDb(c *database.Database) (*gorm.DB, error) {
+	open, ok := database.Opens[c.Driver]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database driver %q", c.Driver)
+	}
 	//log.Infof("%s => %s", host, utils.Green(c.Source))
 	registers := make([]database.ResolverConfigure, len(c.Registers))
 	for i := range c.Registers {
@@ -28,7 +51,7 @@ func setupSimpleDatabase(c *database.Database) {
 			c.Registers[i].Tables)
 	}
 	resolverConfig := database.NewConfigure(c.Source, c.MaxIdleCons, c.MaxOpenCons, c.ConnMaxIdleTime, c.ConnMaxLifeTime, registers)
-	db, err := resolverConfig.Init(&gorm.Config{
+	return resolverConfig.Init(&gorm.Config{
 		NamingStrategy: schema.NamingStrategy{
 			TablePrefix:   "", // 表前缀
 			SingularTable: true,
@@ -41,13 +64,5 @@ func setupSimpleDatabase(c *database.Database) {
 					slogger.DefaultLogger.Options().Level.LevelForGorm()),
 			},
 		),
-	}, database.Opens[c.Driver])
-
-	if err != nil {
-		log.Log.WithError(err).Fatal(c.Driver + " connect error :")
-	} else {
-		log.Log.Info(c.Driver + " connect success !")
-	}
-	// 初始化
-	gplus.Init(db)
+	}, open)
 }
@@ -0,0 +1,19 @@
+package constants
+
+// fasthttp.RequestCtx UserValue 中网关使用的key
+const (
+	// BodyConsumedKey 流式路由已经把客户端的请求体完整转发给上游(bool)
+	BodyConsumedKey = "gateway.bodyConsumed"
+	// ConsumerKey 鉴权通过后的调用方信息(*data.Consumer)
+	ConsumerKey = "gateway.consumer"
+	// RouteKey 匹配到的路由路径(string)
+	RouteKey = "gateway.route"
+	// ServiceNameKey 匹配到的路由服务名称(string)
+	ServiceNameKey = "gateway.serviceName"
+	// TraceContextKey 当前请求的链路上下文(context.Context)
+	TraceContextKey = "gateway.traceContext"
+	// UpstreamAddrKey 实际转发的上游地址(string)
+	UpstreamAddrKey = "gateway.upstreamAddr"
+	// UpstreamLatencyKey 上游请求耗时(time.Duration)
+	UpstreamLatencyKey = "gateway.upstreamLatency"
+)
@@ -0,0 +1,29 @@
+package data
+
+import (
+	"context"
+)
+
+// Consumer 调用方身份信息, 鉴权通过后放入请求上下文供后续中间件(限流,日志等)使用
+type Consumer struct {
+	Id   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// IConsumerData 调用方数据抽象接口
+type IConsumerData interface {
+	// GetConsumerByKeyHash 根据api key哈希获取调用方, 不存在或者已失效时返回nil
+	GetConsumerByKeyHash(ctx context.Context, keyHash string) (*Consumer, error)
+}
+
+// HmacCredential 调用方的hmac签名凭证
+type HmacCredential struct {
+	Secret   string
+	Consumer *Consumer
+}
+
+// IHmacCredentialData hmac签名凭证数据抽象接口
+type IHmacCredentialData interface {
+	// GetHmacCredential 根据access key获取签名凭证, 不存在或者已禁用时返回nil
+	GetHmacCredential(ctx context.Context, accessKey string) (*HmacCredential, error)
+}
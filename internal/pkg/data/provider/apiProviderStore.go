@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-faster-gateway/internal/pkg/data/tables"
+	"go-faster-gateway/pkg/config/dynamic"
+	apiProvider "go-faster-gateway/pkg/provider/api"
+
+	"github.com/acmestack/gorm-plus/gplus"
+	"gorm.io/gorm"
+)
+
+const apiProviderName = "api"
+
+var _ apiProvider.Store = (*ApiProviderDbStore)(nil)
+
+// ApiProviderDbStore 把api provider管理的路由以json快照的形式保存到数据库
+type ApiProviderDbStore struct {
+}
+
+func NewApiProviderDbStore() apiProvider.Store {
+	return &ApiProviderDbStore{}
+}
+
+func (s *ApiProviderDbStore) Load() (map[string]map[string]*dynamic.ServiceRoute, error) {
+	snapshot, db := s.selectSnapshot()
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, db.Error
+	}
+	var services map[string]map[string]*dynamic.ServiceRoute
+	if err := json.Unmarshal([]byte(snapshot.Content), &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+func (s *ApiProviderDbStore) Save(services map[string]map[string]*dynamic.ServiceRoute) error {
+	content, err := json.Marshal(services)
+	if err != nil {
+		return err
+	}
+	snapshot, db := s.selectSnapshot()
+	if db.Error != nil {
+		if !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return db.Error
+		}
+		return gplus.Insert(&tables.ProviderSnapshot{Provider: apiProviderName, Content: string(content)}).Error
+	}
+	snapshot.Content = string(content)
+	return gplus.UpdateById(snapshot).Error
+}
+
+func (s *ApiProviderDbStore) selectSnapshot() (*tables.ProviderSnapshot, *gorm.DB) {
+	query, model := gplus.NewQuery[tables.ProviderSnapshot]()
+	query.Eq(&model.Provider, apiProviderName)
+	return gplus.SelectOne(query, gplus.Session(&gorm.Session{Context: context.Background()}))
+}
@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"go-faster-gateway/internal/pkg/data"
+	"go-faster-gateway/internal/pkg/data/tables"
+	"time"
+
+	"github.com/acmestack/gorm-plus/gplus"
+	"gorm.io/gorm"
+)
+
+var _ data.IConsumerData = (*ConsumerDbData)(nil)
+
+// ConsumerDbData 数据库获取调用方数据
+type ConsumerDbData struct {
+}
+
+func NewConsumerDbData() data.IConsumerData {
+	return &ConsumerDbData{}
+}
+
+func (c *ConsumerDbData) GetConsumerByKeyHash(ctx context.Context, keyHash string) (*data.Consumer, error) {
+	session := gplus.Session(&gorm.Session{Context: ctx})
+	keyQuery, keyModel := gplus.NewQuery[tables.ApiKey]()
+	keyQuery.Eq(&keyModel.KeyHash, keyHash).Eq(&keyModel.Status, tables.StatusEnabled)
+	apiKey, db := gplus.SelectOne(keyQuery, session)
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, db.Error
+	}
+	if apiKey.ExpiredAt != nil && apiKey.ExpiredAt.Before(time.Now()) {
+		return nil, nil
+	}
+
+	consumerQuery, consumerModel := gplus.NewQuery[tables.Consumer]()
+	consumerQuery.Eq(&consumerModel.Id, apiKey.ConsumerId).Eq(&consumerModel.Status, tables.StatusEnabled)
+	consumer, db := gplus.SelectOne(consumerQuery, session)
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, db.Error
+	}
+	return &data.Consumer{Id: consumer.Id, Name: consumer.Name}, nil
+}
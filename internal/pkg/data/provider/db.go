@@ -1,62 +1,188 @@
 package provider
 
-//import (
-//	"context"
-//	"fmt"
-//	"github.com/acmestack/gorm-plus/gplus"
-//	"github.com/jinzhu/copier"
-//	"go-faster-gateway/internal/pkg/biz"
-//	"go-faster-gateway/internal/pkg/data/tables"
-//	"go-faster-gateway/pkg/config/dynamic"
-//	"gorm.io/gorm"
-//)
-//
-//type apiRouteResourceRepository struct {
-//}
-//
-//func NewApiRouteResourceRepo() biz.ApiRouteResourceRepository {
-//	return &apiRouteResourceRepository{}
-//}
-//
-//func (api *apiRouteResourceRepository) GetApiList(ctx context.Context) ([]*biz.ApiRouteResourceDto, error) {
-//	query, _ := gplus.NewQuery[tables.ApiRouteResource]()
-//	list, sessionDb := gplus.SelectList[tables.ApiRouteResource](query, gplus.Session(&gorm.Session{Context: ctx}))
-//	if sessionDb.Error != nil {
-//		return nil, sessionDb.Error
-//	}
-//	for _, v := range list {
-//		fmt.Println(v)
-//	}
-//	var ret []*biz.ApiRouteResourceDto
-//	for _, v := range list {
-//		temp := &biz.ApiRouteResourceDto{
-//			ApiPath:     v.ApiPath,
-//			Method:      v.Method,
-//			ServiceName: v.ServiceName,
-//			ProxyPath:   v.ProxyPath,
-//			MiddleWare:  v.Middlewares,
-//		}
-//		ret = append(ret, temp)
-//	}
-//	return ret, nil
-//}
-//
-//// 静态文件获取路由数据
-//type apiRouteResourceFileRepository struct {
-//	routeList []dynamic.ServiceName
-//}
-//
-//func NewApiRouteResourceFileRepo(routes []dynamic.ServiceName) biz.ApiRouteResourceRepository {
-//	return &apiRouteResourceFileRepository{
-//		routeList: routes,
-//	}
-//}
-//
-//func (api *apiRouteResourceFileRepository) GetApiList(ctx context.Context) ([]*biz.ApiRouteResourceDto, error) {
-//	var apiList []*biz.ApiRouteResourceDto
-//	err := copier.Copy(&apiList, &api.routeList)
-//	if err != nil {
-//		return nil, err
-//	}
-//	return apiList, nil
-//}
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-faster-gateway/internal/pkg/data/tables"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/config/static"
+	"go-faster-gateway/pkg/log"
+	pkgProvider "go-faster-gateway/pkg/provider"
+	"go-faster-gateway/pkg/safe"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acmestack/gorm-plus/gplus"
+	"gorm.io/gorm"
+)
+
+const dbProviderName = "database"
+
+// RouteResourceDbData 数据库获取路由数据
+type RouteResourceDbData struct {
+}
+
+func NewRouteResourceDbData() *RouteResourceDbData {
+	return &RouteResourceDbData{}
+}
+
+// GetServices 启用的路由, 按服务名-路由名分组, 和动态配置中easyServiceRoute的结构一致
+func (api *RouteResourceDbData) GetServices(ctx context.Context) (map[string]map[string]*dynamic.ServiceRoute, error) {
+	query, model := gplus.NewQuery[tables.ApiRouteResource]()
+	query.Eq(&model.Status, tables.StatusEnabled).OrderByAsc(&model.Id)
+	list, db := gplus.SelectList(query, gplus.Session(&gorm.Session{Context: ctx}))
+	if db.Error != nil {
+		return nil, db.Error
+	}
+	services := make(map[string]map[string]*dynamic.ServiceRoute)
+	for _, v := range list {
+		routeName := v.RouteName
+		if routeName == "" {
+			routeName = fmt.Sprintf("%s_%s", v.ServiceName, v.Handler)
+		}
+		if services[v.ServiceName] == nil {
+			services[v.ServiceName] = make(map[string]*dynamic.ServiceRoute)
+		}
+		services[v.ServiceName][routeName] = toServiceRoute(v)
+	}
+	return services, nil
+}
+
+// GetRevision 启用路由的行数和最大revision, 任意一个变化都说明路由有变更
+func (api *RouteResourceDbData) GetRevision(ctx context.Context) (int64, int64, error) {
+	session := gplus.Session(&gorm.Session{Context: ctx})
+	countQuery, countModel := gplus.NewQuery[tables.ApiRouteResource]()
+	countQuery.Eq(&countModel.Status, tables.StatusEnabled)
+	count, db := gplus.SelectCount(countQuery, session)
+	if db.Error != nil {
+		return 0, 0, db.Error
+	}
+	if count == 0 {
+		return 0, 0, nil
+	}
+	// 禁用也是一次保存, 所以最大revision不过滤状态
+	revisionQuery, revisionModel := gplus.NewQuery[tables.ApiRouteResource]()
+	revisionQuery.OrderByDesc(&revisionModel.Revision)
+	latest, db := gplus.SelectOne(revisionQuery, session)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		return 0, 0, db.Error
+	}
+	if latest == nil {
+		return count, 0, nil
+	}
+	return count, latest.Revision, nil
+}
+
+func toServiceRoute(v *tables.ApiRouteResource) *dynamic.ServiceRoute {
+	routers := []dynamic.Router(v.Routers)
+	if len(routers) == 0 && v.ApiPath != "" {
+		var methods []string
+		for _, m := range strings.Split(v.Method, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				methods = append(methods, strings.ToUpper(m))
+			}
+		}
+		routers = []dynamic.Router{{Path: v.ApiPath, Methods: methods, ProxyPath: v.ProxyPath}}
+	}
+	return &dynamic.ServiceRoute{
+		ServiceName: v.ServiceName,
+		BalanceMode: v.BalanceMode,
+		Handler:     v.Handler,
+		Routers:     routers,
+		Servers:     v.Servers,
+		Middlewares: v.Middleware,
+	}
+}
+
+var _ pkgProvider.Provider = (*RouteResourceDbProvider)(nil)
+
+// RouteResourceDbProvider 定时检查api表的revision, 有变更时重新加载路由并发布配置
+type RouteResourceDbProvider struct {
+	conf *static.DatabaseProvider
+	data *RouteResourceDbData
+
+	mu            sync.Mutex
+	configuration *dynamic.Configuration
+	count         int64
+	revision      int64
+}
+
+func NewRouteResourceDbProvider(conf *static.DatabaseProvider) *RouteResourceDbProvider {
+	return &RouteResourceDbProvider{
+		conf: conf,
+		data: NewRouteResourceDbData(),
+	}
+}
+
+// Init the provider.
+func (p *RouteResourceDbProvider) Init() error {
+	p.conf.SetDefaults()
+	return nil
+}
+
+// Provide 先加载一次路由, 之后按pollInterval检查revision
+func (p *RouteResourceDbProvider) Provide(configurationChan chan<- dynamic.Message, pool *safe.Pool) error {
+	slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: dbProviderName})
+	if err := p.refresh(context.Background(), configurationChan, true); err != nil {
+		slog.WithError(err).Error("Error while loading routes from database (for the first time)")
+	}
+	pool.GoCtx(func(ctx context.Context) {
+		ticker := time.NewTicker(time.Duration(p.conf.PollInterval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := p.refresh(ctx, configurationChan, false); err != nil {
+					slog.WithError(err).Error("Error while loading routes from database")
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// GetConfig 返回最近一次加载的路由, 数据库在第一次获取动态配置之后才初始化, 所以这里不查库.
+// 第一次加载之前返回nil, 不参与配置合并
+func (p *RouteResourceDbProvider) GetConfig() (dynamic.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return dynamic.Message{
+		ProviderName:  dbProviderName,
+		Configuration: p.configuration,
+	}, nil
+}
+
+// refresh revision没有变化时跳过, force时总是重新加载
+func (p *RouteResourceDbProvider) refresh(ctx context.Context, configurationChan chan<- dynamic.Message, force bool) error {
+	count, revision, err := p.data.GetRevision(ctx)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	unchanged := p.configuration != nil && count == p.count && revision == p.revision
+	p.mu.Unlock()
+	if unchanged && !force {
+		return nil
+	}
+	services, err := p.data.GetServices(ctx)
+	if err != nil {
+		return err
+	}
+	configuration := &dynamic.Configuration{
+		EasyServiceRoute: &dynamic.ServiceRouteConfiguration{Services: services},
+	}
+	p.mu.Lock()
+	p.configuration = configuration
+	p.count = count
+	p.revision = revision
+	p.mu.Unlock()
+	configurationChan <- dynamic.Message{
+		ProviderName:  dbProviderName,
+		Configuration: configuration,
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package provider
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/acmestack/gorm-plus/gplus"
+	dbSetup "go-faster-gateway/internal/pkg/componentSetup/database"
+	"go-faster-gateway/internal/pkg/data/tables"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/config/static"
+	"go-faster-gateway/pkg/database"
+	"go-faster-gateway/pkg/safe"
+)
+
+func TestToServiceRoute(t *testing.T) {
+	legacy := toServiceRoute(&tables.ApiRouteResource{
+		ServiceName: "order",
+		Handler:     "http",
+		ApiPath:     "/order/:id",
+		Method:      "get, post",
+		ProxyPath:   "/v1/order/:id",
+		Middleware:  tables.Middleware{"jwt"},
+	})
+	want := []dynamic.Router{{Path: "/order/:id", Methods: []string{"GET", "POST"}, ProxyPath: "/v1/order/:id"}}
+	if !reflect.DeepEqual(legacy.Routers, want) || !reflect.DeepEqual(legacy.Middlewares, []string{"jwt"}) {
+		t.Errorf("unexpected legacy route %+v", legacy)
+	}
+
+	// json列写入后再读出
+	var routers tables.Routers
+	value, err := tables.Routers{{Path: "/user", Type: "static"}}.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = routers.Scan(string(value.([]byte))); err != nil {
+		t.Fatal(err)
+	}
+	route := toServiceRoute(&tables.ApiRouteResource{ServiceName: "user", ApiPath: "/ignored", Routers: routers})
+	if len(route.Routers) != 1 || route.Routers[0].Path != "/user" {
+		t.Errorf("routers column should win over api_path, got %+v", route.Routers)
+	}
+}
+
+func TestRouteResourceDbProvider(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "gateway.db")
+	db, err := dbSetup.OpenDb(&database.Database{
+		Driver:    "sqlite",
+		Source:    source,
+		Registers: []database.DBResolverConfig{{Sources: []string{source}, Replicas: []string{source}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(tables.All()...); err != nil {
+		t.Fatal(err)
+	}
+	gplus.Init(db)
+
+	row := &tables.ApiRouteResource{
+		ServiceName: "order",
+		RouteName:   "order_http",
+		Handler:     "http",
+		ApiPath:     "/order",
+		Method:      "GET",
+		Servers:     tables.Servers{{Host: "10.0.0.1", Port: 80, Healthy: true}},
+		Status:      tables.StatusEnabled,
+	}
+	if err = gplus.Insert(row).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewRouteResourceDbProvider(&static.DatabaseProvider{})
+	if err = p.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// 第一次加载之前不参与合并, 不会清空其他provider的路由
+	if msg, _ := p.GetConfig(); msg.Configuration != nil {
+		t.Errorf("configuration before the first load should be nil, got %+v", msg.Configuration)
+	}
+	ch := make(chan dynamic.Message, 10)
+	pool := safe.NewPool(context.Background())
+	defer pool.Stop()
+	if err = p.Provide(ch, pool); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-ch
+	route := msg.Configuration.EasyServiceRoute.Services["order"]["order_http"]
+	if msg.ProviderName != dbProviderName || route == nil || len(route.Servers) != 1 || route.Routers[0].Path != "/order" {
+		t.Fatalf("unexpected first configuration %+v", msg.Configuration.EasyServiceRoute.Services)
+	}
+
+	// revision没有变化时不发布
+	if err = p.refresh(context.Background(), ch, false); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch) != 0 {
+		t.Fatal("unchanged revision should not publish")
+	}
+
+	row.Status = tables.StatusDisabled
+	if err = gplus.UpdateZeroById(row).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err = p.refresh(context.Background(), ch, false); err != nil {
+		t.Fatal(err)
+	}
+	if msg = <-ch; len(msg.Configuration.EasyServiceRoute.Services) != 0 {
+		t.Errorf("disabled route should be removed, got %+v", msg.Configuration.EasyServiceRoute.Services)
+	}
+}
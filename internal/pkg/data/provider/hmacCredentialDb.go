@@ -0,0 +1,48 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"go-faster-gateway/internal/pkg/data"
+	"go-faster-gateway/internal/pkg/data/tables"
+
+	"github.com/acmestack/gorm-plus/gplus"
+	"gorm.io/gorm"
+)
+
+var _ data.IHmacCredentialData = (*HmacCredentialDbData)(nil)
+
+// HmacCredentialDbData 数据库获取hmac签名凭证
+type HmacCredentialDbData struct {
+}
+
+func NewHmacCredentialDbData() data.IHmacCredentialData {
+	return &HmacCredentialDbData{}
+}
+
+func (h *HmacCredentialDbData) GetHmacCredential(ctx context.Context, accessKey string) (*data.HmacCredential, error) {
+	session := gplus.Session(&gorm.Session{Context: ctx})
+	credQuery, credModel := gplus.NewQuery[tables.HmacCredential]()
+	credQuery.Eq(&credModel.AccessKey, accessKey).Eq(&credModel.Status, tables.StatusEnabled)
+	cred, db := gplus.SelectOne(credQuery, session)
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, db.Error
+	}
+
+	consumerQuery, consumerModel := gplus.NewQuery[tables.Consumer]()
+	consumerQuery.Eq(&consumerModel.Id, cred.ConsumerId).Eq(&consumerModel.Status, tables.StatusEnabled)
+	consumer, db := gplus.SelectOne(consumerQuery, session)
+	if db.Error != nil {
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, db.Error
+	}
+	return &data.HmacCredential{
+		Secret:   cred.Secret,
+		Consumer: &data.Consumer{Id: consumer.Id, Name: consumer.Name},
+	}, nil
+}
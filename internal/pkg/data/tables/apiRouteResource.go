@@ -3,26 +3,88 @@ package tables
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"fmt"
+	"go-faster-gateway/pkg/config/dynamic"
+	"time"
+
+	"gorm.io/gorm"
 )
 
+// ApiRouteResource 数据库中的路由, 一行对应动态配置easyServiceRoute中的一个ServiceRoute
 type ApiRouteResource struct {
 	Id          uint       `gorm:"AUTO_INCREMENT"`
-	ApiPath     string     `json:"api_path"`     // 网关请求路径
-	Method      string     `json:"method"`       // get/post/put/...
-	ServiceName string     `json:"service_name"` // 上游服务名，用于寻后端的具体服务
-	ProxyPath   string     `json:"proxy_path"`   // 路由上游路径，如果需要转发至该路径
-	Middleware  Middleware `json:"middleware"`   // 一组中间件集合，路由的整体生命周期里，需要经过此批中间件的一层层处理
+	ServiceName string     `gorm:"size:64;uniqueIndex:idx_service_route" json:"service_name"` // 上游服务名，用于寻后端的具体服务
+	RouteName   string     `gorm:"size:64;uniqueIndex:idx_service_route" json:"route_name"`   // 服务下路由的唯一名称, 比如order_http, order_websocket
+	Handler     string     `gorm:"size:16;default:http" json:"handler"`                       // 协议 http/https/websocket
+	BalanceMode string     `gorm:"size:32" json:"balance_mode"`                               // 负载均衡策略, 为空时使用全局策略
+	ApiPath     string     `json:"api_path"`                                                  // 网关请求路径, routers为空时作为单条路由使用
+	Method      string     `json:"method"`                                                    // get/post/put/..., 多个用逗号分隔
+	ProxyPath   string     `json:"proxy_path"`                                                // 路由上游路径，如果需要转发至该路径
+	Routers     Routers    `gorm:"type:text" json:"routers"`                                  // 完整的路由列表, json
+	Servers     Servers    `gorm:"type:text" json:"servers"`                                  // 上游结点列表, json
+	Middleware  Middleware `gorm:"type:text" json:"middleware"`                               // 一组中间件集合，路由的整体生命周期里，需要经过此批中间件的一层层处理
+	Status      int        `gorm:"default:1" json:"status"`                                   // 1启用 0禁用
+	Revision    int64      `gorm:"index" json:"revision"`                                     // 每次保存时更新, db provider根据最大revision和行数判断是否有变更
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (ApiRouteResource) TableName() string {
+	return "api"
+}
+
+// BeforeSave 通过gorm保存时自动更新revision, 直接改库时需要手动更新revision
+func (t *ApiRouteResource) BeforeSave(*gorm.DB) error {
+	t.Revision = time.Now().UnixNano()
+	return nil
 }
+
 type Middleware []string
 
 func (t *Middleware) Scan(value interface{}) error {
-	bytesValue, _ := value.([]byte)
-	return json.Unmarshal(bytesValue, t)
+	return scanJSON(value, t)
 }
 
-func (t *Middleware) Value() (driver.Value, error) {
+func (t Middleware) Value() (driver.Value, error) {
 	return json.Marshal(t)
 }
-func (ApiRouteResource) TableName() string {
-	return "api"
+
+type Routers []dynamic.Router
+
+func (t *Routers) Scan(value interface{}) error {
+	return scanJSON(value, t)
+}
+
+func (t Routers) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+type Servers []dynamic.Server
+
+func (t *Servers) Scan(value interface{}) error {
+	return scanJSON(value, t)
+}
+
+func (t Servers) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+// scanJSON 不同的驱动json列可能返回[]byte或者string
+func scanJSON(value interface{}, dest interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		if len(v) == 0 {
+			return nil
+		}
+		return json.Unmarshal(v, dest)
+	case string:
+		if v == "" {
+			return nil
+		}
+		return json.Unmarshal([]byte(v), dest)
+	default:
+		return fmt.Errorf("tables: unsupported json column type %T", value)
+	}
 }
@@ -0,0 +1,63 @@
+package tables
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+const (
+	StatusDisabled = 0
+	StatusEnabled  = 1
+)
+
+// Consumer 网关调用方, 一个调用方可以持有多个api key
+type Consumer struct {
+	Id        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string    `gorm:"size:64;uniqueIndex" json:"name"` // 调用方唯一名称
+	Status    int       `gorm:"default:1" json:"status"`         // 1启用 0禁用
+	Remark    string    `gorm:"size:255" json:"remark"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Consumer) TableName() string {
+	return "consumer"
+}
+
+// ApiKey 调用方的api key, 只保存key的sha256哈希
+type ApiKey struct {
+	Id         uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	ConsumerId uint       `gorm:"index" json:"consumer_id"`
+	KeyHash    string     `gorm:"size:64;uniqueIndex" json:"-"` // sha256(key)的16进制
+	KeyPrefix  string     `gorm:"size:16" json:"key_prefix"`    // key前几位, 方便识别是哪个key
+	Status     int        `gorm:"default:1" json:"status"`      // 1启用 0禁用
+	ExpiredAt  *time.Time `json:"expired_at"`                   // 为空表示永不过期
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (ApiKey) TableName() string {
+	return "api_key"
+}
+
+// HashApiKey 计算api key入库/查询用的哈希
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// HmacCredential 调用方的hmac签名凭证, 验签需要原始secret所以不能只保存哈希
+type HmacCredential struct {
+	Id         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ConsumerId uint      `gorm:"index" json:"consumer_id"`
+	AccessKey  string    `gorm:"size:64;uniqueIndex" json:"access_key"`
+	Secret     string    `gorm:"size:128" json:"-"`
+	Status     int       `gorm:"default:1" json:"status"` // 1启用 0禁用
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (HmacCredential) TableName() string {
+	return "hmac_credential"
+}
@@ -0,0 +1,16 @@
+package tables
+
+import "time"
+
+// ProviderSnapshot provider持久化的动态配置快照, 每个provider一行
+type ProviderSnapshot struct {
+	Id        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Provider  string    `gorm:"size:64;uniqueIndex" json:"provider"` // provider名称, 比如api
+	Content   string    `gorm:"type:text" json:"content"`            // 配置的json
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ProviderSnapshot) TableName() string {
+	return "provider_snapshot"
+}
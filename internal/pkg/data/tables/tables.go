@@ -0,0 +1,12 @@
+package tables
+
+// All 网关用到的所有表, 用于自动迁移
+func All() []interface{} {
+	return []interface{}{
+		new(ApiRouteResource),
+		new(Consumer),
+		new(ApiKey),
+		new(HmacCredential),
+		new(ProviderSnapshot),
+	}
+}
@@ -4,4 +4,30 @@ var (
 	UpstreamNotInit        = New(1000, 0, "balancer not init", "")
 	InternalServerErrorErr = New(1001, 500, "Internal Server Error", "InternalServerError")
 	BackendTimeoutErr      = New(1002, 504, "Backend timeout", "iot.apigw.BackendTimeout")
+	UpstreamUnavailableErr = New(1003, 503, "Upstream unavailable", "iot.apigw.UpstreamUnavailable")
+
+	// 鉴权相关
+	UnauthorizedErr  = New(1100, 401, "Unauthorized", "iot.apigw.Unauthorized")
+	TokenMissingErr  = New(1101, 401, "Token missing", "iot.apigw.TokenMissing")
+	TokenInvalidErr  = New(1102, 401, "Token invalid", "iot.apigw.TokenInvalid")
+	TokenExpiredErr  = New(1103, 401, "Token expired", "iot.apigw.TokenExpired")
+	AuthServerErr    = New(1104, 500, "Auth server error", "iot.apigw.AuthServerError")
+	BodyTooLargeErr  = New(1105, 413, "Request body too large", "iot.apigw.RequestEntityTooLarge")
+	ApiKeyMissingErr = New(1106, 401, "Api key missing", "iot.apigw.ApiKeyMissing")
+	ApiKeyInvalidErr = New(1107, 401, "Api key invalid", "iot.apigw.ApiKeyInvalid")
+
+	// 签名相关
+	SignatureMissingErr  = New(1200, 401, "Signature headers missing", "iot.apigw.SignatureMissing")
+	AccessKeyInvalidErr  = New(1201, 401, "Access key invalid", "iot.apigw.AccessKeyInvalid")
+	SignatureExpiredErr  = New(1202, 401, "Signature timestamp out of range", "iot.apigw.SignatureExpired")
+	SignatureReplayedErr = New(1203, 401, "Signature nonce already used", "iot.apigw.SignatureReplayed")
+	SignatureMismatchErr = New(1204, 401, "Signature mismatch", "iot.apigw.SignatureMismatch")
+	NonceCacheFullErr    = New(1205, 429, "Too many signed requests", "iot.apigw.NonceCacheFull")
+
+	// 管理接口相关
+	InvalidParamErr   = New(1300, 400, "Invalid parameter", "iot.apigw.InvalidParameter")
+	RouteNotFoundErr  = New(1301, 404, "Route not found", "iot.apigw.RouteNotFound")
+	RouteConflictErr  = New(1302, 409, "Route already exists", "iot.apigw.RouteConflict")
+	ServerNotFoundErr = New(1303, 404, "Server not found", "iot.apigw.ServerNotFound")
+	ServerConflictErr = New(1304, 409, "Server already exists", "iot.apigw.ServerConflict")
 )
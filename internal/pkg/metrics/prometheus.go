@@ -0,0 +1,235 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-faster-gateway/internal/pkg/constants"
+	"go-faster-gateway/pkg/config/static"
+	"go-faster-gateway/pkg/log"
+	"go-faster-gateway/pkg/poxyResource/balancer"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp"
+)
+
+const namespace = "gateway"
+
+// defaultRegistry 全局的指标注册器, 未开启监控时为nil, 所有上报函数都是空操作
+var defaultRegistry atomic.Pointer[Registry]
+
+// Registry prometheus指标
+type Registry struct {
+	conf     *static.Prometheus
+	registry *prometheus.Registry
+	labels   []string
+
+	requestsTotal           *prometheus.CounterVec
+	requestDuration         *prometheus.HistogramVec
+	upstreamDuration        *prometheus.HistogramVec
+	upstreamErrors          *prometheus.CounterVec
+	configReloads           *prometheus.CounterVec
+	configLastReloadSuccess prometheus.Gauge
+}
+
+// NewRegistry 创建prometheus指标注册器, labels控制请求指标的标签以限制基数
+func NewRegistry(conf *static.Prometheus) (*Registry, error) {
+	conf.SetDefaults()
+	for _, l := range conf.Labels {
+		switch l {
+		case static.MetricsLabelRoute, static.MetricsLabelService, static.MetricsLabelMethod, static.MetricsLabelCode:
+		default:
+			return nil, fmt.Errorf("metrics: unsupported label %q", l)
+		}
+	}
+	upstreamLabels := []string{static.MetricsLabelService}
+	if !conf.DisableNodeLabel {
+		upstreamLabels = append(upstreamLabels, "node")
+	}
+
+	r := &Registry{
+		conf:     conf,
+		registry: prometheus.NewRegistry(),
+		labels:   conf.Labels,
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "How many HTTP requests processed, partitioned by the configured labels.",
+		}, conf.Labels),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "How long it took to process the request, partitioned by the configured labels.",
+			Buckets:   conf.Buckets,
+		}, conf.Labels),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "upstream_request_duration_seconds",
+			Help:      "How long the upstream took to respond.",
+			Buckets:   conf.Buckets,
+		}, upstreamLabels),
+		upstreamErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_errors_total",
+			Help:      "How many requests failed to reach the upstream.",
+		}, upstreamLabels),
+		configReloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "config_reloads_total",
+			Help:      "Config reloads, partitioned by result.",
+		}, []string{"status"}),
+		configLastReloadSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "config_last_reload_success_timestamp_seconds",
+			Help:      "Last config reload success.",
+		}),
+	}
+	r.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		r.requestsTotal,
+		r.requestDuration,
+		r.upstreamDuration,
+		r.upstreamErrors,
+		r.configReloads,
+		r.configLastReloadSuccess,
+	)
+	return r, nil
+}
+
+// SetDefault 设置全局指标注册器
+func SetDefault(r *Registry) {
+	defaultRegistry.Store(r)
+}
+
+// RegisterOpenConnections 上报入口当前打开的连接数
+func (r *Registry) RegisterOpenConnections(fn func() float64) {
+	r.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "open_connections",
+		Help:      "How many open connections exist on the entry point.",
+	}, fn))
+}
+
+// RegisterUpstreams 上报负载均衡中各个结点的健康状态和连接池状态
+func (r *Registry) RegisterUpstreams(nodes func() map[string][]*balancer.Node) {
+	labels := []string{static.MetricsLabelService, "node"}
+	r.registry.MustRegister(&upstreamCollector{
+		nodes: nodes,
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "upstream", "node_healthy"),
+			"Current health state of the balancer node, 1 for healthy and 0 for unhealthy.",
+			labels, nil),
+		connsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "upstream", "node_open_connections"),
+			"How many connections are open in the connection pool of the balancer node.",
+			labels, nil),
+		pendingDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "upstream", "node_pending_requests"),
+			"How many requests are waiting for a response from the balancer node.",
+			labels, nil),
+	})
+}
+
+// Handler 请求指标中间件, 需要放在最外层
+func (r *Registry) Handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		next(ctx)
+		values := make([]string, len(r.labels))
+		for i, l := range r.labels {
+			switch l {
+			case static.MetricsLabelRoute:
+				values[i], _ = ctx.UserValue(constants.RouteKey).(string)
+			case static.MetricsLabelService:
+				values[i], _ = ctx.UserValue(constants.ServiceNameKey).(string)
+			case static.MetricsLabelMethod:
+				values[i] = string(ctx.Method())
+			case static.MetricsLabelCode:
+				values[i] = strconv.Itoa(ctx.Response.StatusCode())
+			}
+		}
+		r.requestsTotal.WithLabelValues(values...).Inc()
+		r.requestDuration.WithLabelValues(values...).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ListenAndServe 在单独的入口上暴露指标, ctx结束时关闭
+func (r *Registry) ListenAndServe(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.Handle(r.conf.Path, promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Addr: r.conf.EntryPoint, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	log.Log.Infof("metrics listening on %s%s", r.conf.EntryPoint, r.conf.Path)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// ObserveUpstream 上报一次上游请求
+func ObserveUpstream(service, node string, duration time.Duration, err error) {
+	r := defaultRegistry.Load()
+	if r == nil {
+		return
+	}
+	values := []string{service}
+	if !r.conf.DisableNodeLabel {
+		values = append(values, node)
+	}
+	r.upstreamDuration.WithLabelValues(values...).Observe(duration.Seconds())
+	if err != nil {
+		r.upstreamErrors.WithLabelValues(values...).Inc()
+	}
+}
+
+// ObserveConfigReload 上报一次配置重载
+func ObserveConfigReload(success bool) {
+	r := defaultRegistry.Load()
+	if r == nil {
+		return
+	}
+	if success {
+		r.configReloads.WithLabelValues("success").Inc()
+		r.configLastReloadSuccess.SetToCurrentTime()
+	} else {
+		r.configReloads.WithLabelValues("failure").Inc()
+	}
+}
+
+type upstreamCollector struct {
+	nodes       func() map[string][]*balancer.Node
+	desc        *prometheus.Desc
+	connsDesc   *prometheus.Desc
+	pendingDesc *prometheus.Desc
+}
+
+func (c *upstreamCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+	ch <- c.connsDesc
+	ch <- c.pendingDesc
+}
+
+func (c *upstreamCollector) Collect(ch chan<- prometheus.Metric) {
+	for service, nodes := range c.nodes() {
+		for _, n := range nodes {
+			healthy := 0.0
+			if n.Healthy {
+				healthy = 1
+			}
+			addr := n.Addr()
+			ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, healthy, service, addr)
+			ch <- prometheus.MustNewConstMetric(c.connsDesc, prometheus.GaugeValue, float64(n.ConnsCount()), service, addr)
+			ch <- prometheus.MustNewConstMetric(c.pendingDesc, prometheus.GaugeValue, float64(n.PendingRequests()), service, addr)
+		}
+	}
+}
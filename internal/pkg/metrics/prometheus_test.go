@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/valyala/fasthttp"
+	"go-faster-gateway/internal/pkg/constants"
+	"go-faster-gateway/pkg/config/static"
+	"go-faster-gateway/pkg/poxyResource/balancer"
+)
+
+func TestRegistry(t *testing.T) {
+	r, err := NewRegistry(&static.Prometheus{Labels: []string{static.MetricsLabelService, static.MetricsLabelCode}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetDefault(r)
+	defer SetDefault(nil)
+
+	h := r.Handler(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetUserValue(constants.ServiceNameKey, "order_http")
+		ctx.SetStatusCode(fasthttp.StatusCreated)
+	})
+	h(&fasthttp.RequestCtx{})
+	h(&fasthttp.RequestCtx{})
+	if got := testutil.ToFloat64(r.requestsTotal.WithLabelValues("order_http", "201")); got != 2 {
+		t.Errorf("requests_total = %v, want 2", got)
+	}
+
+	ObserveUpstream("order_http", "10.0.0.1:80", time.Millisecond, fasthttp.ErrTimeout)
+	if got := testutil.ToFloat64(r.upstreamErrors.WithLabelValues("order_http", "10.0.0.1:80")); got != 1 {
+		t.Errorf("upstream_errors_total = %v, want 1", got)
+	}
+
+	ObserveConfigReload(true)
+	ObserveConfigReload(false)
+	if got := testutil.ToFloat64(r.configReloads.WithLabelValues("success")); got != 1 {
+		t.Errorf("config_reloads_total{success} = %v, want 1", got)
+	}
+
+	r.RegisterUpstreams(func() map[string][]*balancer.Node {
+		return map[string][]*balancer.Node{"order_http": {
+			{Service: "10.0.0.1", Port: 80, Healthy: true},
+			{Service: "10.0.0.2", Port: 80},
+		}}
+	})
+	if n, err := testutil.GatherAndCount(r.registry, "gateway_upstream_node_healthy"); err != nil || n != 2 {
+		t.Errorf("node_healthy series = %d, err %v", n, err)
+	}
+	if n, err := testutil.GatherAndCount(r.registry, "gateway_upstream_node_open_connections", "gateway_upstream_node_pending_requests"); err != nil || n != 4 {
+		t.Errorf("connection pool series = %d, err %v", n, err)
+	}
+
+	if _, err = NewRegistry(&static.Prometheus{Labels: []string{"path"}}); err == nil {
+		t.Error("expected error for unsupported label")
+	}
+}
@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"go-faster-gateway/internal/pkg/ecode"
+
+	"github.com/valyala/fasthttp"
+)
+
+// abortWithError 以ecode的json格式中断请求并返回给客户端
+func abortWithError(ctx *fasthttp.RequestCtx, e *ecode.Response) {
+	ctx.Response.Reset()
+	ctx.SetStatusCode(e.HttpCode)
+	ctx.SetContentType("application/json; charset=utf-8")
+	ctx.SetBodyString(e.Data())
+}
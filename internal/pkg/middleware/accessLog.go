@@ -0,0 +1,264 @@
+package middleware
+
+import (
+	"fmt"
+	"go-faster-gateway/internal/pkg/constants"
+	"go-faster-gateway/pkg/config/static"
+	"go-faster-gateway/pkg/log"
+	plugins "go-faster-gateway/pkg/log/logplugins"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	clfTimeFormat  = "02/Jan/2006:15:04:05 -0700"
+	redactedHeader = "REDACTED"
+)
+
+// AccessLogger 访问日志, 记录每个代理请求的客户端,上游,耗时等信息
+type AccessLogger struct {
+	json         bool
+	writer       zapcore.WriteSyncer
+	logger       *zap.Logger
+	statusRanges [][2]int
+	minDuration  time.Duration
+	sampleRate   float64
+	defaultMode  string
+	// 规范化后的header名称 -> keep/drop/redact
+	headerModes map[string]string
+}
+
+// NewAccessLogger 根据静态配置创建访问日志, 未配置文件时输出到控制台
+func NewAccessLogger(conf *static.AccessLog) (*AccessLogger, error) {
+	var ws zapcore.WriteSyncer
+	if conf.File != nil {
+		ws = plugins.NewFileLogger(*conf.File)
+	} else {
+		ws = plugins.NewConsoleLogger()
+	}
+	return newAccessLogger(conf, ws)
+}
+
+func newAccessLogger(conf *static.AccessLog, ws zapcore.WriteSyncer) (*AccessLogger, error) {
+	al := &AccessLogger{
+		writer:      zapcore.Lock(ws),
+		sampleRate:  1,
+		defaultMode: static.AccessLogDrop,
+		headerModes: make(map[string]string),
+	}
+	switch strings.ToLower(conf.Format) {
+	case "", static.CommonFormat:
+	case static.JSONFormat:
+		al.json = true
+		encoderConfig := zapcore.EncoderConfig{
+			TimeKey:        "time",
+			MessageKey:     zapcore.OmitKey,
+			LineEnding:     zapcore.DefaultLineEnding,
+			EncodeTime:     zapcore.ISO8601TimeEncoder,
+			EncodeDuration: zapcore.MillisDurationEncoder,
+		}
+		al.logger = zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), al.writer, zapcore.InfoLevel))
+	default:
+		return nil, fmt.Errorf("accessLog: unsupported format %q", conf.Format)
+	}
+
+	if f := conf.Filters; f != nil {
+		for _, s := range f.StatusCodes {
+			r, err := parseStatusRange(s)
+			if err != nil {
+				return nil, err
+			}
+			al.statusRanges = append(al.statusRanges, r)
+		}
+		al.minDuration = time.Duration(f.MinDuration)
+		if f.SampleRate > 0 && f.SampleRate < 1 {
+			al.sampleRate = f.SampleRate
+		}
+	}
+	if conf.Fields != nil && conf.Fields.Headers != nil {
+		h := conf.Fields.Headers
+		if h.DefaultMode != "" {
+			mode, err := checkHeaderMode(h.DefaultMode)
+			if err != nil {
+				return nil, err
+			}
+			al.defaultMode = mode
+		}
+		for name, m := range h.Names {
+			mode, err := checkHeaderMode(m)
+			if err != nil {
+				return nil, err
+			}
+			al.headerModes[http.CanonicalHeaderKey(name)] = mode
+		}
+	}
+	return al, nil
+}
+
+// Handler 访问日志中间件, 需要放在最外层才能记录完整耗时
+func (al *AccessLogger) Handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		start := time.Now()
+		next(ctx)
+		duration := time.Since(start)
+		if !al.keep(ctx.Response.StatusCode(), duration) {
+			return
+		}
+		if al.json {
+			al.logJSON(ctx, start, duration)
+		} else {
+			al.logCommon(ctx, start, duration)
+		}
+	}
+}
+
+// Close 刷新缓冲的日志
+func (al *AccessLogger) Close() error {
+	return al.writer.Sync()
+}
+
+func (al *AccessLogger) keep(status int, duration time.Duration) bool {
+	if len(al.statusRanges) > 0 {
+		matched := false
+		for _, r := range al.statusRanges {
+			if status >= r[0] && status <= r[1] {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if al.minDuration > 0 && duration < al.minDuration {
+		return false
+	}
+	return al.sampleRate >= 1 || rand.Float64() < al.sampleRate
+}
+
+func (al *AccessLogger) logJSON(ctx *fasthttp.RequestCtx, start time.Time, duration time.Duration) {
+	upstreamLatency, _ := ctx.UserValue(constants.UpstreamLatencyKey).(time.Duration)
+	fields := []zap.Field{
+		zap.String("clientIp", ctx.RemoteIP().String()),
+		zap.String("method", string(ctx.Method())),
+		zap.String("uri", string(ctx.RequestURI())),
+		zap.String("proto", string(ctx.Request.Header.Protocol())),
+		zap.Int("status", ctx.Response.StatusCode()),
+		zap.Int("bytes", responseSize(ctx)),
+		zap.Duration("duration", duration),
+		zap.String("upstreamAddr", userValueString(ctx, constants.UpstreamAddrKey)),
+		zap.Duration("upstreamLatency", upstreamLatency),
+		zap.String(log.RouterName, userValueString(ctx, constants.RouteKey)),
+		zap.String(log.ServiceName, userValueString(ctx, constants.ServiceNameKey)),
+		zap.String(log.RequestID, userValueString(ctx, log.RequestIdKey)),
+		zap.String("startTime", start.Format(time.RFC3339Nano)),
+	}
+	ctx.Request.Header.VisitAll(func(key, value []byte) {
+		name := http.CanonicalHeaderKey(string(key))
+		switch al.headerMode(name) {
+		case static.AccessLogKeep:
+			fields = append(fields, zap.String("request_"+name, string(value)))
+		case static.AccessLogRedact:
+			fields = append(fields, zap.String("request_"+name, redactedHeader))
+		}
+	})
+	al.logger.Info("", fields...)
+}
+
+func (al *AccessLogger) logCommon(ctx *fasthttp.RequestCtx, start time.Time, duration time.Duration) {
+	var sb strings.Builder
+	sb.WriteString(ctx.RemoteIP().String())
+	sb.WriteString(" - - [")
+	sb.WriteString(start.Format(clfTimeFormat))
+	sb.WriteString("] \"")
+	sb.Write(ctx.Method())
+	sb.WriteByte(' ')
+	sb.Write(ctx.RequestURI())
+	sb.WriteByte(' ')
+	sb.Write(ctx.Request.Header.Protocol())
+	sb.WriteString("\" ")
+	sb.WriteString(strconv.Itoa(ctx.Response.StatusCode()))
+	sb.WriteByte(' ')
+	sb.WriteString(strconv.Itoa(responseSize(ctx)))
+	sb.WriteString(" \"")
+	sb.WriteString(clfValue(string(ctx.Referer())))
+	sb.WriteString("\" \"")
+	sb.WriteString(clfValue(string(ctx.UserAgent())))
+	sb.WriteString("\" \"")
+	sb.WriteString(clfValue(userValueString(ctx, log.RequestIdKey)))
+	sb.WriteString("\" \"")
+	sb.WriteString(clfValue(userValueString(ctx, constants.RouteKey)))
+	sb.WriteString("\" \"")
+	sb.WriteString(clfValue(userValueString(ctx, constants.ServiceNameKey)))
+	sb.WriteString("\" \"")
+	sb.WriteString(clfValue(userValueString(ctx, constants.UpstreamAddrKey)))
+	sb.WriteString("\" ")
+	upstreamLatency, _ := ctx.UserValue(constants.UpstreamLatencyKey).(time.Duration)
+	sb.WriteString(strconv.FormatInt(upstreamLatency.Milliseconds(), 10))
+	sb.WriteString("ms ")
+	sb.WriteString(strconv.FormatInt(duration.Milliseconds(), 10))
+	sb.WriteString("ms\n")
+	_, _ = al.writer.Write([]byte(sb.String()))
+}
+
+func (al *AccessLogger) headerMode(name string) string {
+	if mode, ok := al.headerModes[name]; ok {
+		return mode
+	}
+	return al.defaultMode
+}
+
+// responseSize 流式响应不读取body, 只使用Content-Length
+func responseSize(ctx *fasthttp.RequestCtx) int {
+	if ctx.Response.IsBodyStream() {
+		if n := ctx.Response.Header.ContentLength(); n > 0 {
+			return n
+		}
+		return 0
+	}
+	return len(ctx.Response.Body())
+}
+
+func userValueString(ctx *fasthttp.RequestCtx, key string) string {
+	v, _ := ctx.UserValue(key).(string)
+	return v
+}
+
+func clfValue(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(v, "\"", "\\\"")
+}
+
+func checkHeaderMode(mode string) (string, error) {
+	switch m := strings.ToLower(mode); m {
+	case static.AccessLogKeep, static.AccessLogDrop, static.AccessLogRedact:
+		return m, nil
+	default:
+		return "", fmt.Errorf("accessLog: unsupported header mode %q", mode)
+	}
+}
+
+// parseStatusRange 解析 200 或者 400-499 格式的状态码范围
+func parseStatusRange(s string) ([2]int, error) {
+	from, to, found := strings.Cut(strings.TrimSpace(s), "-")
+	start, err := strconv.Atoi(from)
+	if err != nil {
+		return [2]int{}, fmt.Errorf("accessLog: invalid status code range %q", s)
+	}
+	end := start
+	if found {
+		if end, err = strconv.Atoi(to); err != nil || end < start {
+			return [2]int{}, fmt.Errorf("accessLog: invalid status code range %q", s)
+		}
+	}
+	return [2]int{start, end}, nil
+}
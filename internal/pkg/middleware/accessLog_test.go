@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go-faster-gateway/internal/pkg/constants"
+	"go-faster-gateway/pkg/config/static"
+	"go-faster-gateway/pkg/log"
+	"go.uber.org/zap/zapcore"
+)
+
+func serveAccessLog(t *testing.T, conf *static.AccessLog, status int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	al, err := newAccessLogger(conf, zapcore.AddSync(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/orders?id=1")
+	ctx.Request.Header.Set(fasthttp.HeaderAuthorization, "Bearer secret")
+	ctx.Request.Header.Set("X-Tenant", "acme")
+	ctx.SetUserValue(log.RequestIdKey, "rid-1")
+	al.Handler(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetUserValue(constants.RouteKey, "/orders")
+		ctx.SetUserValue(constants.ServiceNameKey, "order_http")
+		ctx.SetUserValue(constants.UpstreamAddrKey, "10.0.0.1:8080")
+		ctx.SetUserValue(constants.UpstreamLatencyKey, 3*time.Millisecond)
+		ctx.SetStatusCode(status)
+		ctx.SetBodyString("hello")
+	})(ctx)
+	return buf.String()
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	out := serveAccessLog(t, &static.AccessLog{
+		Format: static.JSONFormat,
+		Fields: &static.AccessLogFields{Headers: &static.FieldHeaders{
+			DefaultMode: static.AccessLogKeep,
+			Names:       map[string]string{"authorization": static.AccessLogRedact},
+		}},
+	}, fasthttp.StatusOK)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(out), &entry); err != nil {
+		t.Fatalf("decode %q: %v", out, err)
+	}
+	want := map[string]interface{}{
+		"uri":                   "/orders?id=1",
+		"status":                float64(200),
+		"bytes":                 float64(5),
+		"upstreamAddr":          "10.0.0.1:8080",
+		"upstreamLatency":       float64(3),
+		log.RouterName:          "/orders",
+		log.ServiceName:         "order_http",
+		log.RequestID:           "rid-1",
+		"request_Authorization": redactedHeader,
+		"request_X-Tenant":      "acme",
+	}
+	for k, v := range want {
+		if entry[k] != v {
+			t.Errorf("%s = %v, want %v", k, entry[k], v)
+		}
+	}
+}
+
+func TestAccessLogCommonAndFilters(t *testing.T) {
+	out := serveAccessLog(t, &static.AccessLog{}, fasthttp.StatusOK)
+	if !strings.Contains(out, `"GET /orders?id=1 HTTP/1.1" 200 5`) || !strings.Contains(out, `"rid-1" "/orders" "order_http" "10.0.0.1:8080"`) {
+		t.Errorf("unexpected common log line: %q", out)
+	}
+	if strings.Contains(out, "secret") {
+		t.Errorf("common log leaked header: %q", out)
+	}
+
+	filters := &static.AccessLog{Filters: &static.AccessLogFilters{StatusCodes: []string{"500-599"}}}
+	if out = serveAccessLog(t, filters, fasthttp.StatusOK); out != "" {
+		t.Errorf("filtered request logged: %q", out)
+	}
+	if out = serveAccessLog(t, filters, fasthttp.StatusBadGateway); out == "" {
+		t.Error("expected 502 to be logged")
+	}
+
+	if _, err := newAccessLogger(&static.AccessLog{Format: "xml"}, zapcore.AddSync(&bytes.Buffer{})); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
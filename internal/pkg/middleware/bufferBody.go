@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"errors"
+	"go-faster-gateway/internal/pkg/ecode"
+	"go-faster-gateway/pkg/log"
+	"io"
+
+	"github.com/valyala/fasthttp"
+)
+
+// maxBufferedBodySize 非流式路由在网关中缓存的请求体上限, 和fasthttp默认的MaxRequestBodySize一致
+const maxBufferedBodySize = fasthttp.DefaultMaxRequestBodySize
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// BufferBodyMiddleware 入口开启了流式读取请求体, 超过上限的和chunked的请求体在进入路由时还没有读完.
+// 非流式路由在其他中间件之前把请求体读到内存中, 超过上限时返回413, 之后的PostBody只读内存
+func BufferBodyMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		if ctx.RequestBodyStream() == nil {
+			next(ctx)
+			return
+		}
+		if _, err := readBody(ctx, maxBufferedBodySize); err != nil {
+			abortWithBodyError(ctx, err)
+			return
+		}
+		next(ctx)
+	}
+}
+
+// readBody 读取请求体, 超过limit时返回errBodyTooLarge. 中间件读取请求体都通过readBody,
+// 不直接调用PostBody: 流式路由的请求体还没有读完, PostBody会不限大小地读到内存中
+func readBody(ctx *fasthttp.RequestCtx, limit int) ([]byte, error) {
+	stream := ctx.RequestBodyStream()
+	if stream == nil {
+		body := ctx.PostBody()
+		if len(body) > limit {
+			return nil, errBodyTooLarge
+		}
+		return body, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(stream, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > limit {
+		return nil, errBodyTooLarge
+	}
+	// 读完的请求体放回请求中, 之后的中间件和转发都使用内存中的请求体
+	ctx.Request.SetBody(body)
+	return body, nil
+}
+
+// abortWithBodyError readBody失败时结束请求
+func abortWithBodyError(ctx *fasthttp.RequestCtx, err error) {
+	if errors.Is(err, errBodyTooLarge) {
+		abortWithError(ctx, ecode.BodyTooLargeErr)
+	} else {
+		log.WithContext(ctx).WithError(err).Warn("read request body fail")
+		ctx.Error(fasthttp.StatusMessage(fasthttp.StatusBadRequest), fasthttp.StatusBadRequest)
+	}
+	if ctx.RequestBodyStream() != nil {
+		// 连接上还有没读完的请求体, 不能继续处理下一个请求
+		ctx.SetConnectionClose()
+	}
+}
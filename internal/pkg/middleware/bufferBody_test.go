@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestBufferBodyMiddleware(t *testing.T) {
+	run := func(size int) (*fasthttp.RequestCtx, []byte, bool) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		// 模拟入口没有读完的chunked请求体
+		ctx.Request.SetBodyStream(bytes.NewReader(bytes.Repeat([]byte("x"), size)), -1)
+		var body []byte
+		called := false
+		BufferBodyMiddleware(func(ctx *fasthttp.RequestCtx) {
+			called = true
+			if ctx.RequestBodyStream() != nil {
+				t.Error("body stream should be consumed")
+			}
+			body = ctx.PostBody()
+		})(ctx)
+		return ctx, body, called
+	}
+
+	_, body, called := run(1024)
+	if !called || len(body) != 1024 {
+		t.Errorf("body not buffered: called %v, len %d", called, len(body))
+	}
+
+	ctx, _, called := run(maxBufferedBodySize + 1)
+	if called || ctx.Response.StatusCode() != fasthttp.StatusRequestEntityTooLarge || !ctx.Response.ConnectionClose() {
+		t.Errorf("oversized body: called %v, status %d", called, ctx.Response.StatusCode())
+	}
+}
@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"go-faster-gateway/internal/pkg/ecode"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	xForwardedFor    = "X-Forwarded-For"
+	xForwardedMethod = "X-Forwarded-Method"
+	xForwardedProto  = "X-Forwarded-Proto"
+	xForwardedHost   = "X-Forwarded-Host"
+	xForwardedUri    = "X-Forwarded-Uri"
+
+	defaultForwardAuthTimeout = 30 * time.Second
+)
+
+// hopHeaders 逐跳头, 不会转发给鉴权服务, 也不会从鉴权服务的响应中回传
+var hopHeaders = []string{
+	fasthttp.HeaderConnection,
+	"Keep-Alive",
+	fasthttp.HeaderProxyAuthenticate,
+	fasthttp.HeaderProxyAuthorization,
+	fasthttp.HeaderTE,
+	fasthttp.HeaderTrailer,
+	fasthttp.HeaderTransferEncoding,
+	fasthttp.HeaderUpgrade,
+}
+
+type forwardAuth struct {
+	name                string
+	address             string
+	client              *fasthttp.Client
+	timeout             time.Duration
+	trustForwardHeader  bool
+	authResponseHeaders []string
+	authResponseRegex   *regexp.Regexp
+	authRequestHeaders  []string
+	forwardBody         bool
+	maxBodySize         int64
+}
+
+// NewForwardAuthMiddleware 将鉴权委托给外部服务, 鉴权服务返回2xx时放行, 否则原样返回鉴权服务的响应
+func NewForwardAuthMiddleware(name string, conf *dynamic.ForwardAuth) (MiddlewareFunc, error) {
+	if conf.Address == "" {
+		return nil, errors.New("forwardAuth: address is required")
+	}
+	fa := &forwardAuth{
+		name:                name,
+		address:             conf.Address,
+		timeout:             time.Duration(conf.Timeout),
+		trustForwardHeader:  conf.TrustForwardHeader,
+		authResponseHeaders: conf.AuthResponseHeaders,
+		authRequestHeaders:  conf.AuthRequestHeaders,
+		forwardBody:         conf.ForwardBody,
+		maxBodySize:         dynamic.ForwardAuthDefaultMaxBodySize,
+	}
+	if conf.MaxBodySize != nil {
+		fa.maxBodySize = *conf.MaxBodySize
+	}
+	if fa.timeout <= 0 {
+		fa.timeout = defaultForwardAuthTimeout
+	}
+	if conf.AuthResponseHeadersRegex != "" {
+		re, err := regexp.Compile(conf.AuthResponseHeadersRegex)
+		if err != nil {
+			return nil, fmt.Errorf("forwardAuth: error compiling regular expression %s: %w", conf.AuthResponseHeadersRegex, err)
+		}
+		fa.authResponseRegex = re
+	}
+	tlsConfig, err := conf.TLS.CreateTLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("forwardAuth: unable to create client TLS configuration: %w", err)
+	}
+	fa.client = &fasthttp.Client{
+		TLSConfig:                tlsConfig,
+		NoDefaultUserAgentHeader: true,
+		DisablePathNormalizing:   true,
+	}
+	return fa.handle, nil
+}
+
+func (fa *forwardAuth) handle(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		slog := log.WithContext(ctx).WithFields(map[string]interface{}{log.MiddlewareName: fa.name})
+
+		authReq := fasthttp.AcquireRequest()
+		defer fasthttp.ReleaseRequest(authReq)
+		authResp := fasthttp.AcquireResponse()
+		defer fasthttp.ReleaseResponse(authResp)
+
+		authReq.SetRequestURI(fa.address)
+		authReq.Header.SetMethodBytes(ctx.Method())
+		fa.copyRequestHeaders(ctx, authReq)
+		fa.writeForwardedHeaders(ctx, authReq)
+
+		if fa.forwardBody {
+			// 没有配置maxBodySize时最多读取非流式路由的缓存上限
+			limit := maxBufferedBodySize
+			if fa.maxBodySize >= 0 {
+				limit = int(fa.maxBodySize)
+			}
+			body, err := readBody(ctx, limit)
+			if err != nil {
+				slog.WithError(err).Debugf("read request body for forwardAuth, maxBodySize %d", limit)
+				abortWithBodyError(ctx, err)
+				return
+			}
+			authReq.SetBody(body)
+		}
+
+		if err := fa.client.DoTimeout(authReq, authResp, fa.timeout); err != nil {
+			slog.WithError(err).Errorf("error calling %s", fa.address)
+			abortWithError(ctx, ecode.AuthServerErr)
+			return
+		}
+
+		// 鉴权失败, 原样返回鉴权服务的响应
+		if code := authResp.StatusCode(); code < fasthttp.StatusOK || code >= fasthttp.StatusMultipleChoices {
+			slog.Debugf("remote error %s. StatusCode: %d", fa.address, code)
+			authResp.CopyTo(&ctx.Response)
+			for _, h := range hopHeaders {
+				ctx.Response.Header.Del(h)
+			}
+			return
+		}
+
+		for _, h := range fa.authResponseHeaders {
+			ctx.Request.Header.Del(h)
+			if v := authResp.Header.Peek(h); len(v) > 0 {
+				ctx.Request.Header.SetBytesV(h, v)
+			}
+		}
+		if fa.authResponseRegex != nil {
+			var stale []string
+			ctx.Request.Header.VisitAll(func(key, _ []byte) {
+				if fa.authResponseRegex.Match(key) {
+					stale = append(stale, string(key))
+				}
+			})
+			for _, h := range stale {
+				ctx.Request.Header.Del(h)
+			}
+			authResp.Header.VisitAll(func(key, value []byte) {
+				if fa.authResponseRegex.Match(key) {
+					ctx.Request.Header.AddBytesKV(key, value)
+				}
+			})
+		}
+		next(ctx)
+	}
+}
+
+func (fa *forwardAuth) copyRequestHeaders(ctx *fasthttp.RequestCtx, authReq *fasthttp.Request) {
+	if len(fa.authRequestHeaders) > 0 {
+		for _, h := range fa.authRequestHeaders {
+			if v := ctx.Request.Header.Peek(h); len(v) > 0 {
+				authReq.Header.SetBytesV(h, v)
+			}
+		}
+		return
+	}
+	ctx.Request.Header.VisitAll(func(key, value []byte) {
+		k := string(key)
+		if strings.EqualFold(k, fasthttp.HeaderContentLength) || isHopHeader(k) {
+			return
+		}
+		authReq.Header.AddBytesKV(key, value)
+	})
+}
+
+// writeForwardedHeaders 写入X-Forwarded-*头, 只有trustForwardHeader时才沿用客户端传入的值
+func (fa *forwardAuth) writeForwardedHeaders(ctx *fasthttp.RequestCtx, authReq *fasthttp.Request) {
+	set := func(key, value string) {
+		if fa.trustForwardHeader {
+			if v := ctx.Request.Header.Peek(key); len(v) > 0 {
+				authReq.Header.SetBytesV(key, v)
+				return
+			}
+		}
+		authReq.Header.Set(key, value)
+	}
+
+	clientIP := ctx.RemoteIP().String()
+	if prior := ctx.Request.Header.Peek(xForwardedFor); fa.trustForwardHeader && len(prior) > 0 {
+		authReq.Header.Set(xForwardedFor, string(prior)+", "+clientIP)
+	} else {
+		authReq.Header.Set(xForwardedFor, clientIP)
+	}
+
+	proto := "http"
+	if ctx.IsTLS() {
+		proto = "https"
+	}
+	set(xForwardedMethod, string(ctx.Method()))
+	set(xForwardedProto, proto)
+	set(xForwardedHost, string(ctx.Host()))
+	set(xForwardedUri, string(ctx.URI().RequestURI()))
+}
+
+func isHopHeader(key string) bool {
+	for _, h := range hopHeaders {
+		if strings.EqualFold(h, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"go-faster-gateway/pkg/config/dynamic"
+)
+
+func TestForwardAuthMiddleware(t *testing.T) {
+	var gotHeaders http.Header
+	var gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders = r.Header.Clone()
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+		if r.Header.Get("Authorization") != "Bearer good" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("denied"))
+			return
+		}
+		w.Header().Set("X-Auth-User", "alice")
+		w.Header().Set("X-Auth-Role", "admin")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	maxBody := int64(16)
+	mw, err := NewForwardAuthMiddleware("auth", &dynamic.ForwardAuth{
+		Address:                  srv.URL,
+		AuthResponseHeaders:      []string{"X-Auth-User"},
+		AuthResponseHeadersRegex: "^X-Auth-Role$",
+		ForwardBody:              true,
+		MaxBodySize:              &maxBody,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newCtx := func(auth, body string) *fasthttp.RequestCtx {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Init(&fasthttp.Request{}, &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}, nil)
+		ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+		ctx.Request.SetRequestURI("http://gateway.local/orders?id=1")
+		ctx.Request.Header.Set("Authorization", auth)
+		ctx.Request.Header.Set("X-Auth-User", "spoofed")
+		ctx.Request.Header.Set("X-Forwarded-Host", "evil.local")
+		ctx.Request.SetBodyString(body)
+		return ctx
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		ctx := newCtx("Bearer good", "payload")
+		called := false
+		mw(func(*fasthttp.RequestCtx) { called = true })(ctx)
+		if !called {
+			t.Fatalf("expected request to pass, status %d", ctx.Response.StatusCode())
+		}
+		if got := string(ctx.Request.Header.Peek("X-Auth-User")); got != "alice" {
+			t.Errorf("X-Auth-User = %q, want alice", got)
+		}
+		if got := string(ctx.Request.Header.Peek("X-Auth-Role")); got != "admin" {
+			t.Errorf("X-Auth-Role = %q, want admin", got)
+		}
+		if gotBody != "payload" {
+			t.Errorf("auth body = %q, want payload", gotBody)
+		}
+		if got := gotHeaders.Get("X-Forwarded-Host"); got != "gateway.local" {
+			t.Errorf("untrusted X-Forwarded-Host forwarded: %q", got)
+		}
+		if got := gotHeaders.Get("X-Forwarded-Uri"); got != "/orders?id=1" {
+			t.Errorf("X-Forwarded-Uri = %q", got)
+		}
+		if got := gotHeaders.Get("X-Forwarded-For"); got != "10.0.0.1" {
+			t.Errorf("X-Forwarded-For = %q", got)
+		}
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		ctx := newCtx("Bearer bad", "")
+		called := false
+		mw(func(*fasthttp.RequestCtx) { called = true })(ctx)
+		if called {
+			t.Fatal("request should not reach upstream")
+		}
+		if ctx.Response.StatusCode() != http.StatusForbidden || string(ctx.Response.Body()) != "denied" {
+			t.Errorf("got %d %q, want auth server response", ctx.Response.StatusCode(), ctx.Response.Body())
+		}
+		if got := string(ctx.Response.Header.Peek("WWW-Authenticate")); got != "Bearer" {
+			t.Errorf("WWW-Authenticate = %q", got)
+		}
+	})
+
+	t.Run("body too large", func(t *testing.T) {
+		ctx := newCtx("Bearer good", "this body is longer than sixteen bytes")
+		mw(func(*fasthttp.RequestCtx) { t.Fatal("request should not reach upstream") })(ctx)
+		if ctx.Response.StatusCode() != http.StatusRequestEntityTooLarge {
+			t.Errorf("status = %d", ctx.Response.StatusCode())
+		}
+	})
+
+	t.Run("body stream too large", func(t *testing.T) {
+		// 流式路由上没有读完的请求体, 只读取到maxBodySize
+		ctx := newCtx("Bearer good", "")
+		ctx.Request.SetBodyStream(strings.NewReader("this body is longer than sixteen bytes"), -1)
+		mw(func(*fasthttp.RequestCtx) { t.Fatal("request should not reach upstream") })(ctx)
+		if ctx.Response.StatusCode() != http.StatusRequestEntityTooLarge || !ctx.Response.ConnectionClose() {
+			t.Errorf("status = %d, connection close %v", ctx.Response.StatusCode(), ctx.Response.ConnectionClose())
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"errors"
+	"go-faster-gateway/pkg/config/dynamic"
+	"path"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Gateway API中HTTPRoute的filter对应的中间件, 由kubernetes provider生成配置
+
+// NewRequestHeaderModifierMiddleware 修改转发到上游的请求头, 按 set, add, remove 的顺序执行
+func NewRequestHeaderModifierMiddleware(conf *dynamic.HeaderModifier) (MiddlewareFunc, error) {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			modifyHeaders(conf, ctx.Request.Header.Set, ctx.Request.Header.Add, ctx.Request.Header.Del)
+			next(ctx)
+		}
+	}, nil
+}
+
+// NewResponseHeaderModifierMiddleware 修改返回给客户端的响应头
+func NewResponseHeaderModifierMiddleware(conf *dynamic.HeaderModifier) (MiddlewareFunc, error) {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			next(ctx)
+			// 上游响应会覆盖响应头, 所以在后面设置
+			modifyHeaders(conf, ctx.Response.Header.Set, ctx.Response.Header.Add, ctx.Response.Header.Del)
+		}
+	}, nil
+}
+
+func modifyHeaders(conf *dynamic.HeaderModifier, set, add func(key, value string), del func(key string)) {
+	for k, v := range conf.Set {
+		set(k, v)
+	}
+	for k, v := range conf.Add {
+		add(k, v)
+	}
+	for _, k := range conf.Remove {
+		del(k)
+	}
+}
+
+// NewURLRewriteMiddleware 改写转发到上游的host和路径.
+// 只配置path时替换整个路径, 同时配置pathPrefix时把匹配到的前缀pathPrefix替换为path
+func NewURLRewriteMiddleware(conf *dynamic.URLRewrite) (MiddlewareFunc, error) {
+	if conf.PathPrefix != nil && conf.Path == nil {
+		return nil, errors.New("urlRewrite: pathPrefix requires path")
+	}
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			if conf.Hostname != nil {
+				// 转发时默认使用上游地址作为Host, 需要显式使用请求头中的Host
+				ctx.Request.Header.SetHost(*conf.Hostname)
+				ctx.Request.UseHostHeader = true
+			}
+			if conf.Path != nil {
+				newPath := *conf.Path
+				if conf.PathPrefix != nil {
+					rest := strings.TrimPrefix(string(ctx.Path()), strings.TrimSuffix(*conf.PathPrefix, "/"))
+					newPath = path.Join(*conf.Path, rest)
+					if strings.HasSuffix(rest, "/") && !strings.HasSuffix(newPath, "/") {
+						newPath += "/"
+					}
+				}
+				ctx.URI().SetPath(newPath)
+			}
+			next(ctx)
+		}
+	}, nil
+}
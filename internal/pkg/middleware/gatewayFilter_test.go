@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"go-faster-gateway/pkg/config/dynamic"
+)
+
+func TestHeaderModifierMiddleware(t *testing.T) {
+	conf := &dynamic.HeaderModifier{
+		Set:    map[string]string{"X-Env": "prod"},
+		Add:    map[string]string{"X-Tag": "b"},
+		Remove: []string{"X-Debug"},
+	}
+	reqMw, _ := NewRequestHeaderModifierMiddleware(conf)
+	respMw, _ := NewResponseHeaderModifierMiddleware(conf)
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.Set("X-Env", "dev")
+	ctx.Request.Header.Set("X-Tag", "a")
+	ctx.Request.Header.Set("X-Debug", "1")
+	var env, debug string
+	var tags int
+	reqMw(respMw(func(ctx *fasthttp.RequestCtx) {
+		env = string(ctx.Request.Header.Peek("X-Env"))
+		debug = string(ctx.Request.Header.Peek("X-Debug"))
+		tags = len(ctx.Request.Header.PeekAll("X-Tag"))
+		// 模拟上游响应覆盖响应头
+		ctx.Response.Reset()
+		ctx.Response.Header.Set("X-Debug", "1")
+	}))(ctx)
+	if env != "prod" || debug != "" || tags != 2 {
+		t.Errorf("unexpected request headers env=%q debug=%q tags=%d", env, debug, tags)
+	}
+	if string(ctx.Response.Header.Peek("X-Env")) != "prod" || len(ctx.Response.Header.Peek("X-Debug")) != 0 {
+		t.Errorf("unexpected response headers %s", ctx.Response.Header.String())
+	}
+}
+
+func TestURLRewriteMiddleware(t *testing.T) {
+	str := func(s string) *string { return &s }
+	cases := []struct {
+		conf       dynamic.URLRewrite
+		path, want string
+	}{
+		{dynamic.URLRewrite{Path: str("/login")}, "/api/user/login", "/login"},
+		{dynamic.URLRewrite{Path: str("/v2"), PathPrefix: str("/api")}, "/api/user", "/v2/user"},
+		{dynamic.URLRewrite{Path: str("/"), PathPrefix: str("/api/")}, "/api/user/", "/user/"},
+		{dynamic.URLRewrite{Hostname: str("internal.local")}, "/api", "/api"},
+	}
+	for _, c := range cases {
+		mw, err := NewURLRewriteMiddleware(&c.conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("http://gateway.local" + c.path + "?q=1")
+		var got, host string
+		mw(func(ctx *fasthttp.RequestCtx) {
+			got, host = string(ctx.Path()), string(ctx.Request.Header.Host())
+		})(ctx)
+		if got != c.want {
+			t.Errorf("%s: path = %q, want %q", c.path, got, c.want)
+		}
+		if c.conf.Hostname != nil && (host != "internal.local" || !ctx.Request.UseHostHeader) {
+			t.Errorf("host = %q, want internal.local", host)
+		}
+		if string(ctx.QueryArgs().Peek("q")) != "1" {
+			t.Errorf("%s: query lost", c.path)
+		}
+	}
+	if _, err := NewURLRewriteMiddleware(&dynamic.URLRewrite{PathPrefix: str("/api")}); err == nil {
+		t.Error("expected error for pathPrefix without path")
+	}
+}
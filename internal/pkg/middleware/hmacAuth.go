@@ -0,0 +1,267 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"go-faster-gateway/internal/pkg/constants"
+	"go-faster-gateway/internal/pkg/data"
+	"go-faster-gateway/internal/pkg/ecode"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/helper/cache"
+	"go-faster-gateway/pkg/log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	defaultAccessKeyHeader     = "X-Access-Key"
+	defaultSignatureHeader     = "X-Signature"
+	defaultTimestampHeader     = "X-Timestamp"
+	defaultNonceHeader         = "X-Nonce"
+	defaultHmacClockSkew       = 5 * time.Minute
+	defaultHmacNonceCacheSize  = 100000
+	hmacCredentialCacheTTL     = time.Minute
+	hmacCredentialCacheMaxSize = 10000
+)
+
+var (
+	// 中间件名称 -> 已使用过的nonce, 配置重载后重建中间件时沿用, 否则重载会清空防重放记录
+	hmacNonces   = make(map[string]*hmacNonceCache)
+	hmacNoncesMu sync.Mutex
+)
+
+type hmacNonceCache struct {
+	size   int
+	ttl    time.Duration
+	nonces *cache.LRU[string, struct{}]
+}
+
+// getHmacNonces 同名的hmacAuth中间件在多次配置重载之间共用nonce缓存, 容量或时间窗口变化时才重建
+func getHmacNonces(name string, size int, ttl time.Duration) *cache.LRU[string, struct{}] {
+	hmacNoncesMu.Lock()
+	defer hmacNoncesMu.Unlock()
+	if c, ok := hmacNonces[name]; ok && c.size == size && c.ttl == ttl {
+		return c.nonces
+	}
+	c := &hmacNonceCache{size: size, ttl: ttl, nonces: cache.NewLRU[string, struct{}](size, ttl)}
+	hmacNonces[name] = c
+	return c.nonces
+}
+
+type hmacAuth struct {
+	name            string
+	accessKeyHeader string
+	signatureHeader string
+	timestampHeader string
+	nonceHeader     string
+	clockSkew       time.Duration
+	credentials     map[string]*data.HmacCredential
+	store           data.IHmacCredentialData
+	// access key -> 数据库中的凭证, 未找到的缓存为nil
+	storeCache *cache.LRU[string, *data.HmacCredential]
+	// 已使用过的nonce, 保留时间覆盖整个时间窗口
+	nonces *cache.LRU[string, struct{}]
+	now    func() time.Time
+}
+
+// NewHmacAuthMiddleware hmac请求签名校验中间件, 校验签名,时间戳并拒绝重放的nonce.
+// store为nil时只使用配置中的凭证
+func NewHmacAuthMiddleware(name string, conf *dynamic.HmacAuth, store data.IHmacCredentialData) (MiddlewareFunc, error) {
+	h := &hmacAuth{
+		name:            name,
+		accessKeyHeader: conf.AccessKeyHeader,
+		signatureHeader: conf.SignatureHeader,
+		timestampHeader: conf.TimestampHeader,
+		nonceHeader:     conf.NonceHeader,
+		clockSkew:       time.Duration(conf.ClockSkew),
+		credentials:     make(map[string]*data.HmacCredential, len(conf.Credentials)),
+		now:             time.Now,
+	}
+	if h.accessKeyHeader == "" {
+		h.accessKeyHeader = defaultAccessKeyHeader
+	}
+	if h.signatureHeader == "" {
+		h.signatureHeader = defaultSignatureHeader
+	}
+	if h.timestampHeader == "" {
+		h.timestampHeader = defaultTimestampHeader
+	}
+	if h.nonceHeader == "" {
+		h.nonceHeader = defaultNonceHeader
+	}
+	if h.clockSkew <= 0 {
+		h.clockSkew = defaultHmacClockSkew
+	}
+	for _, c := range conf.Credentials {
+		if c.AccessKey == "" || c.Secret == "" {
+			return nil, errors.New("hmacAuth: accessKey and secret are required for every credential")
+		}
+		h.credentials[c.AccessKey] = &data.HmacCredential{
+			Secret:   c.Secret,
+			Consumer: &data.Consumer{Name: c.AccessKey},
+		}
+	}
+	if conf.UseDatabase {
+		h.store = store
+		h.storeCache = cache.NewLRU[string, *data.HmacCredential](hmacCredentialCacheMaxSize, hmacCredentialCacheTTL)
+	}
+	if len(h.credentials) == 0 && h.store == nil {
+		return nil, errors.New("hmacAuth: credentials or useDatabase is required")
+	}
+	size := conf.NonceCacheSize
+	if size <= 0 {
+		size = defaultHmacNonceCacheSize
+	}
+	// 时间戳前后各允许clockSkew, nonce需要保留两倍时间
+	h.nonces = getHmacNonces(name, size, 2*h.clockSkew)
+	return h.handle, nil
+}
+
+func (h *hmacAuth) handle(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		accessKey := string(ctx.Request.Header.Peek(h.accessKeyHeader))
+		signature := string(ctx.Request.Header.Peek(h.signatureHeader))
+		timestamp := string(ctx.Request.Header.Peek(h.timestampHeader))
+		nonce := string(ctx.Request.Header.Peek(h.nonceHeader))
+		if accessKey == "" || signature == "" || timestamp == "" || nonce == "" {
+			abortWithError(ctx, ecode.SignatureMissingErr)
+			return
+		}
+
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			abortWithError(ctx, ecode.SignatureExpiredErr)
+			return
+		}
+		if diff := h.now().Sub(time.Unix(ts, 0)); diff > h.clockSkew || diff < -h.clockSkew {
+			abortWithError(ctx, ecode.SignatureExpiredErr)
+			return
+		}
+
+		cred, err := h.lookup(ctx, accessKey)
+		if err != nil {
+			log.WithContext(ctx).WithError(err).WithFields(map[string]interface{}{log.MiddlewareName: h.name}).Error("lookup hmac credential fail")
+			abortWithError(ctx, ecode.InternalServerErrorErr)
+			return
+		}
+		if cred == nil {
+			abortWithError(ctx, ecode.AccessKeyInvalidErr)
+			return
+		}
+
+		// 签名包含请求体的摘要, 先按上限读完请求体, HmacStringToSign只读内存
+		if _, err = readBody(ctx, maxBufferedBodySize); err != nil {
+			abortWithBodyError(ctx, err)
+			return
+		}
+		if !verifyHmacSignature(cred.Secret, HmacStringToSign(ctx, timestamp, nonce), signature) {
+			abortWithError(ctx, ecode.SignatureMismatchErr)
+			return
+		}
+		// 签名通过后才记录nonce, 避免伪造请求占用合法nonce.
+		// 时间窗口内的nonce不能淘汰, 否则会放过重放的请求, 缓存已满时拒绝请求
+		ok, err := h.nonces.SetIfAbsentNoEvict(accessKey+":"+nonce, struct{}{})
+		if err != nil {
+			log.WithContext(ctx).WithError(err).WithFields(map[string]interface{}{log.MiddlewareName: h.name}).Warn("hmac nonce cache is full")
+			abortWithError(ctx, ecode.NonceCacheFullErr)
+			return
+		}
+		if !ok {
+			abortWithError(ctx, ecode.SignatureReplayedErr)
+			return
+		}
+
+		ctx.SetUserValue(constants.ConsumerKey, cred.Consumer)
+		ctx.Request.Header.Set(defaultConsumerHeader, cred.Consumer.Name)
+		// 客户端传入的消费者id不能透传给后端
+		ctx.Request.Header.Del(consumerIdHeader)
+		if cred.Consumer.Id > 0 {
+			ctx.Request.Header.Set(consumerIdHeader, strconv.FormatUint(uint64(cred.Consumer.Id), 10))
+		}
+		next(ctx)
+	}
+}
+
+func (h *hmacAuth) lookup(ctx *fasthttp.RequestCtx, accessKey string) (*data.HmacCredential, error) {
+	if cred, ok := h.credentials[accessKey]; ok {
+		return cred, nil
+	}
+	if h.store == nil {
+		return nil, nil
+	}
+	if cred, ok := h.storeCache.Get(accessKey); ok {
+		return cred, nil
+	}
+	cred, err := h.store.GetHmacCredential(ctx, accessKey)
+	if err != nil {
+		return nil, err
+	}
+	h.storeCache.Set(accessKey, cred)
+	return cred, nil
+}
+
+// HmacStringToSign 生成待签名字符串:
+// METHOD\nPATH\nSORTED_QUERY\nHEX(SHA256(BODY))\nTIMESTAMP\nNONCE
+func HmacStringToSign(ctx *fasthttp.RequestCtx, timestamp, nonce string) string {
+	bodySum := sha256.Sum256(ctx.PostBody())
+	var sb strings.Builder
+	sb.Write(ctx.Method())
+	sb.WriteByte('\n')
+	sb.Write(ctx.Path())
+	sb.WriteByte('\n')
+	sb.WriteString(sortedQuery(ctx.QueryArgs()))
+	sb.WriteByte('\n')
+	sb.WriteString(hex.EncodeToString(bodySum[:]))
+	sb.WriteByte('\n')
+	sb.WriteString(timestamp)
+	sb.WriteByte('\n')
+	sb.WriteString(nonce)
+	return sb.String()
+}
+
+// sortedQuery 按key,value排序后重新编码query参数
+func sortedQuery(args *fasthttp.Args) string {
+	pairs := make([][2]string, 0, args.Len())
+	args.VisitAll(func(key, value []byte) {
+		pairs = append(pairs, [2]string{string(key), string(value)})
+	})
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	var sb strings.Builder
+	for i, p := range pairs {
+		if i > 0 {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(url.QueryEscape(p[0]))
+		sb.WriteByte('=')
+		sb.WriteString(url.QueryEscape(p[1]))
+	}
+	return sb.String()
+}
+
+// verifyHmacSignature 签名支持16进制或者base64编码
+func verifyHmacSignature(secret, stringToSign, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+	expected := mac.Sum(nil)
+	if got, err := hex.DecodeString(signature); err == nil && hmac.Equal(got, expected) {
+		return true
+	}
+	if got, err := base64.StdEncoding.DecodeString(signature); err == nil && hmac.Equal(got, expected) {
+		return true
+	}
+	return false
+}
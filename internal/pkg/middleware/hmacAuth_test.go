@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go-faster-gateway/internal/pkg/ecode"
+	"go-faster-gateway/pkg/config/dynamic"
+)
+
+// signedHmacCtx 用secret签名的请求, access key为ak
+func signedHmacCtx(secret string, ts int64, nonce string) *fasthttp.RequestCtx {
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(fasthttp.MethodPost)
+	ctx.Request.SetRequestURI("/orders?b=2&a=1&a=0")
+	ctx.Request.SetBodyString(`{"id":1}`)
+	timestamp := strconv.FormatInt(ts, 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(HmacStringToSign(ctx, timestamp, nonce)))
+	ctx.Request.Header.Set("X-Access-Key", "ak")
+	ctx.Request.Header.Set("X-Timestamp", timestamp)
+	ctx.Request.Header.Set("X-Nonce", nonce)
+	ctx.Request.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	return ctx
+}
+
+func TestHmacAuthMiddleware(t *testing.T) {
+	mw, err := NewHmacAuthMiddleware("hmac", &dynamic.HmacAuth{
+		Credentials: []dynamic.HmacCredential{{AccessKey: "ak", Secret: "sk"}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	newCtx := signedHmacCtx
+	run := func(ctx *fasthttp.RequestCtx) int {
+		called := false
+		mw(func(ctx *fasthttp.RequestCtx) { called = true })(ctx)
+		if called {
+			return 0
+		}
+		var resp ecode.Response
+		if err := json.Unmarshal(ctx.Response.Body(), &resp); err != nil {
+			t.Fatalf("decode response %q: %v", ctx.Response.Body(), err)
+		}
+		return resp.Code
+	}
+
+	if code := run(newCtx("sk", now, "n1")); code != 0 {
+		t.Fatalf("valid request rejected with code %d", code)
+	}
+	tests := []struct {
+		name string
+		ctx  *fasthttp.RequestCtx
+		want int
+	}{
+		{name: "replayed nonce", ctx: newCtx("sk", now, "n1"), want: ecode.SignatureReplayedErr.Code},
+		{name: "wrong secret", ctx: newCtx("other", now, "n2"), want: ecode.SignatureMismatchErr.Code},
+		{name: "expired", ctx: newCtx("sk", now-3600, "n3"), want: ecode.SignatureExpiredErr.Code},
+		{name: "missing headers", ctx: &fasthttp.RequestCtx{}, want: ecode.SignatureMissingErr.Code},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := run(tt.ctx); code != tt.want {
+				t.Errorf("code = %d, want %d", code, tt.want)
+			}
+		})
+	}
+
+	unknown := newCtx("sk", now, "n4")
+	unknown.Request.Header.Set("X-Access-Key", "nobody")
+	if code := run(unknown); code != ecode.AccessKeyInvalidErr.Code {
+		t.Errorf("unknown access key code = %d", code)
+	}
+
+	tampered := newCtx("sk", now, "n5")
+	tampered.Request.SetBodyString(`{"id":2}`)
+	if code := run(tampered); code != ecode.SignatureMismatchErr.Code {
+		t.Errorf("tampered body code = %d", code)
+	}
+
+	// 流式路由上请求体还没有读完, 按上限读到内存中再校验签名
+	streamed := newCtx("sk", now, "n6")
+	streamed.Request.SetBodyStream(bytes.NewReader([]byte(`{"id":1}`)), -1)
+	if code := run(streamed); code != 0 {
+		t.Errorf("streamed body rejected with code %d", code)
+	}
+	oversized := newCtx("sk", now, "n7")
+	oversized.Request.SetBodyStream(bytes.NewReader(make([]byte, maxBufferedBodySize+1)), -1)
+	if code := run(oversized); code != ecode.BodyTooLargeErr.Code || !oversized.Response.ConnectionClose() {
+		t.Errorf("oversized body code = %d", code)
+	}
+}
+
+func TestHmacNoncesSurviveReload(t *testing.T) {
+	conf := &dynamic.HmacAuth{Credentials: []dynamic.HmacCredential{{AccessKey: "ak", Secret: "sk"}}}
+	accepted := func(ctx *fasthttp.RequestCtx) bool {
+		// 每个请求都重新创建中间件, 模拟配置重载
+		mw, err := NewHmacAuthMiddleware("hmacReload", conf, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		called := false
+		mw(func(ctx *fasthttp.RequestCtx) { called = true })(ctx)
+		return called
+	}
+	now := time.Now().Unix()
+	if !accepted(signedHmacCtx("sk", now, "n1")) {
+		t.Fatal("valid request rejected")
+	}
+	if accepted(signedHmacCtx("sk", now, "n1")) {
+		t.Error("nonce replayed after the middleware was rebuilt")
+	}
+}
+
+func TestHmacAuthNonceCacheFull(t *testing.T) {
+	mw, err := NewHmacAuthMiddleware("hmacFull", &dynamic.HmacAuth{
+		Credentials:    []dynamic.HmacCredential{{AccessKey: "ak", Secret: "sk"}},
+		NonceCacheSize: 1,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+
+	// 客户端传入的消费者id被删除, 配置中的凭证没有id
+	ctx := signedHmacCtx("sk", now, "n1")
+	ctx.Request.Header.Set("X-Consumer-Id", "1")
+	var consumerId []byte
+	mw(func(ctx *fasthttp.RequestCtx) { consumerId = ctx.Request.Header.Peek("X-Consumer-Id") })(ctx)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || consumerId != nil {
+		t.Fatalf("status %d, consumer id %q", ctx.Response.StatusCode(), consumerId)
+	}
+
+	// 时间窗口内的nonce占满缓存时拒绝请求, 不淘汰已使用的nonce
+	full := signedHmacCtx("sk", now, "n2")
+	mw(func(ctx *fasthttp.RequestCtx) { t.Error("request accepted when the nonce cache is full") })(full)
+	if full.Response.StatusCode() != ecode.NonceCacheFullErr.HttpCode {
+		t.Errorf("status = %d", full.Response.StatusCode())
+	}
+	replayed := signedHmacCtx("sk", now, "n1")
+	mw(func(ctx *fasthttp.RequestCtx) { t.Error("nonce replayed after the cache was full") })(replayed)
+}
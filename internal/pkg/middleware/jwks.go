@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-faster-gateway/pkg/log"
+	"go-faster-gateway/pkg/safe"
+	"math/big"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultJwksRefreshInterval = 5 * time.Minute
+	// 未知kid触发刷新的最小间隔, 避免伪造kid打爆jwks服务
+	jwksMissRefreshInterval = 10 * time.Second
+)
+
+var (
+	jwksCaches   = make(map[string]*jwksCache)
+	jwksCachesMu sync.Mutex
+)
+
+// jwksCache 缓存某个jwks地址对应的公钥, 过期后在后台刷新
+type jwksCache struct {
+	url             string
+	refreshInterval time.Duration
+	client          *http.Client
+
+	fetchMu     sync.Mutex // 串行化同步的刷新, 并发的请求只请求一次
+	mu          sync.RWMutex
+	keys        map[string]interface{}
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	refreshing  atomic.Bool
+}
+
+// getJwksCache 同一个jwks地址在多次配置重载之间共用同一份缓存
+func getJwksCache(url string, refreshInterval time.Duration) *jwksCache {
+	if refreshInterval <= 0 {
+		refreshInterval = defaultJwksRefreshInterval
+	}
+	jwksCachesMu.Lock()
+	defer jwksCachesMu.Unlock()
+	if c, ok := jwksCaches[url]; ok {
+		c.mu.Lock()
+		c.refreshInterval = refreshInterval
+		c.mu.Unlock()
+		return c
+	}
+	c := &jwksCache{
+		url:             url,
+		refreshInterval: refreshInterval,
+		client:          &http.Client{Timeout: 10 * time.Second},
+	}
+	jwksCaches[url] = c
+	return c
+}
+
+// Key 根据kid获取验签公钥, ctx为触发查询的请求, 用于日志
+func (c *jwksCache) Key(ctx context.Context, kid string) (interface{}, error) {
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	loaded := c.keys != nil
+	stale := time.Since(c.fetchedAt) > c.refreshInterval
+	canRetry := time.Since(c.lastAttempt) > jwksMissRefreshInterval
+	c.mu.RUnlock()
+
+	switch {
+	case !loaded || !ok:
+		// 还没有加载到公钥或者kid未知时同步刷新, 两次刷新之间至少间隔jwksMissRefreshInterval
+		if canRetry {
+			if err := c.refreshOnMiss(ctx); err != nil {
+				return nil, err
+			}
+		}
+		c.mu.RLock()
+		key, ok = c.keys[kid]
+		loaded = c.keys != nil
+		c.mu.RUnlock()
+		if !loaded {
+			return nil, fmt.Errorf("jwks %s not loaded", c.url)
+		}
+	case stale:
+		if c.refreshing.CompareAndSwap(false, true) {
+			safe.Go(func() {
+				defer c.refreshing.Store(false)
+				if err := c.refresh(context.Background()); err != nil {
+					log.Log.WithError(err).Errorf("refresh jwks %s fail", c.url)
+				}
+			})
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	return key, nil
+}
+
+// refreshOnMiss 同步刷新, 等待锁期间其他请求已经刷新过时直接返回
+func (c *jwksCache) refreshOnMiss(ctx context.Context) error {
+	c.fetchMu.Lock()
+	defer c.fetchMu.Unlock()
+	c.mu.RLock()
+	recent := time.Since(c.lastAttempt) <= jwksMissRefreshInterval
+	c.mu.RUnlock()
+	if recent {
+		return nil
+	}
+	return c.refresh(ctx)
+}
+
+func (c *jwksCache) refresh(ctx context.Context) error {
+	c.mu.Lock()
+	c.lastAttempt = time.Now()
+	c.mu.Unlock()
+
+	resp, err := c.client.Get(c.url)
+	if err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+	var set jsonWebKeySet
+	if err = json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decode jwks: %w", err)
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			log.WithContext(ctx).WithError(err).Warnf("skip jwk %q from %s", k.Kid, c.url)
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// oct
+	K string `json:"k"`
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	default:
+		return nil, errors.New("unsupported key type " + k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
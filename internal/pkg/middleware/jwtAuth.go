@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-faster-gateway/internal/pkg/ecode"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/valyala/fasthttp"
+)
+
+const bearerPrefix = "bearer "
+
+var defaultJwtAlgorithms = []string{"HS256", "RS256", "ES256"}
+
+type jwtAuth struct {
+	name      string
+	conf      *dynamic.JwtAuth
+	parser    *jwt.Parser
+	secret    []byte
+	publicKey interface{}
+	jwks      *jwksCache
+}
+
+// NewJwtAuthMiddleware jwt鉴权中间件, 校验通过后把配置的claim写入上游请求头
+func NewJwtAuthMiddleware(name string, conf *dynamic.JwtAuth) (MiddlewareFunc, error) {
+	j := &jwtAuth{name: name, conf: conf}
+	if conf.Secret != "" {
+		j.secret = []byte(conf.Secret)
+	}
+	if conf.PublicKey != "" {
+		key, err := parsePublicKey(conf.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		j.publicKey = key
+	}
+	if conf.JwksURL != "" {
+		j.jwks = getJwksCache(conf.JwksURL, time.Duration(conf.JwksRefreshInterval))
+	}
+	if j.secret == nil && j.publicKey == nil && j.jwks == nil {
+		return nil, errors.New("jwtAuth: one of secret, publicKey or jwksUrl is required")
+	}
+
+	algorithms := conf.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = defaultJwtAlgorithms
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(algorithms),
+		jwt.WithLeeway(time.Duration(conf.Leeway)),
+		jwt.WithIssuedAt(),
+	}
+	if !conf.AllowMissingExp {
+		// 没有exp的token永不过期, 除非配置显式允许, 否则拒绝
+		opts = append(opts, jwt.WithExpirationRequired())
+	}
+	if conf.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(conf.Issuer))
+	}
+	if conf.Audience != "" {
+		opts = append(opts, jwt.WithAudience(conf.Audience))
+	}
+	j.parser = jwt.NewParser(opts...)
+
+	return j.handle, nil
+}
+
+func (j *jwtAuth) handle(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	tokenHeader := j.conf.TokenHeader
+	if tokenHeader == "" {
+		tokenHeader = fasthttp.HeaderAuthorization
+	}
+	return func(ctx *fasthttp.RequestCtx) {
+		raw := j.extractToken(ctx, tokenHeader)
+		if raw == "" {
+			abortWithError(ctx, ecode.TokenMissingErr)
+			return
+		}
+		claims := jwt.MapClaims{}
+		_, err := j.parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+			return j.keyFunc(ctx, token)
+		})
+		if err != nil {
+			log.WithContext(ctx).WithError(err).WithFields(map[string]interface{}{log.MiddlewareName: j.name}).Debug("jwt validate fail")
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				abortWithError(ctx, ecode.TokenExpiredErr)
+			} else {
+				abortWithError(ctx, ecode.TokenInvalidErr)
+			}
+			return
+		}
+
+		for header, claim := range j.conf.ClaimsToHeaders {
+			// 先删除客户端传入的同名头, 防止伪造身份
+			ctx.Request.Header.Del(header)
+			if v, ok := claims[claim]; ok {
+				ctx.Request.Header.Set(header, claimToString(v))
+			}
+		}
+		if j.conf.RemoveHeader {
+			ctx.Request.Header.Del(tokenHeader)
+		}
+		next(ctx)
+	}
+}
+
+func (j *jwtAuth) extractToken(ctx *fasthttp.RequestCtx, tokenHeader string) string {
+	raw := strings.TrimSpace(string(ctx.Request.Header.Peek(tokenHeader)))
+	if len(raw) > len(bearerPrefix) && strings.EqualFold(raw[:len(bearerPrefix)], bearerPrefix) {
+		raw = strings.TrimSpace(raw[len(bearerPrefix):])
+	}
+	if raw == "" && j.conf.TokenQuery != "" {
+		raw = string(ctx.QueryArgs().Peek(j.conf.TokenQuery))
+	}
+	return raw
+}
+
+func (j *jwtAuth) keyFunc(ctx *fasthttp.RequestCtx, token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if j.secret == nil {
+			return nil, errors.New("no secret configured for " + token.Method.Alg())
+		}
+		return j.secret, nil
+	}
+	if kid, ok := token.Header["kid"].(string); ok && j.jwks != nil {
+		return j.jwks.Key(ctx, kid)
+	}
+	if j.publicKey != nil {
+		return j.publicKey, nil
+	}
+	return nil, errors.New("no verification key for " + token.Method.Alg())
+}
+
+// parsePublicKey 支持直接配置PEM内容或者PEM文件路径
+func parsePublicKey(value string) (interface{}, error) {
+	data := []byte(value)
+	if !strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		var err error
+		data, err = os.ReadFile(value)
+		if err != nil {
+			return nil, fmt.Errorf("jwtAuth: read public key: %w", err)
+		}
+	}
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	key, err := jwt.ParseECPublicKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("jwtAuth: parse public key: %w", err)
+	}
+	return key, nil
+}
+
+func claimToString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
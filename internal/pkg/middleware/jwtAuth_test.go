@@ -0,0 +1,225 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/valyala/fasthttp"
+	"go-faster-gateway/pkg/config/dynamic"
+)
+
+func runMiddleware(t *testing.T, mw MiddlewareFunc, token string) (*fasthttp.RequestCtx, bool) {
+	t.Helper()
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/api/users")
+	ctx.Request.Header.Set("X-User-Id", "spoofed")
+	if token != "" {
+		ctx.Request.Header.Set(fasthttp.HeaderAuthorization, "Bearer "+token)
+	}
+	called := false
+	mw(func(ctx *fasthttp.RequestCtx) { called = true })(ctx)
+	return ctx, called
+}
+
+func TestJwtAuthMiddlewareHS256(t *testing.T) {
+	mw, err := NewJwtAuthMiddleware("jwt", &dynamic.JwtAuth{
+		Secret:          "secret",
+		Issuer:          "gateway",
+		Audience:        "api",
+		ClaimsToHeaders: map[string]string{"X-User-Id": "sub", "X-Tenant": "tenant"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(claims jwt.MapClaims) string {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	now := time.Now()
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+		wantUserId string
+	}{
+		{
+			name:       "valid",
+			token:      sign(jwt.MapClaims{"sub": "42", "tenant": 7, "iss": "gateway", "aud": "api", "exp": now.Add(time.Hour).Unix()}),
+			wantStatus: fasthttp.StatusOK,
+			wantUserId: "42",
+		},
+		{name: "missing", wantStatus: fasthttp.StatusUnauthorized},
+		{
+			name:       "expired",
+			token:      sign(jwt.MapClaims{"sub": "42", "iss": "gateway", "aud": "api", "exp": now.Add(-time.Hour).Unix()}),
+			wantStatus: fasthttp.StatusUnauthorized,
+		},
+		{
+			name:       "not yet valid",
+			token:      sign(jwt.MapClaims{"sub": "42", "iss": "gateway", "aud": "api", "nbf": now.Add(time.Hour).Unix(), "exp": now.Add(2 * time.Hour).Unix()}),
+			wantStatus: fasthttp.StatusUnauthorized,
+		},
+		{
+			name:       "wrong issuer",
+			token:      sign(jwt.MapClaims{"sub": "42", "iss": "other", "aud": "api", "exp": now.Add(time.Hour).Unix()}),
+			wantStatus: fasthttp.StatusUnauthorized,
+		},
+		{
+			name:       "wrong audience",
+			token:      sign(jwt.MapClaims{"sub": "42", "iss": "gateway", "aud": "other", "exp": now.Add(time.Hour).Unix()}),
+			wantStatus: fasthttp.StatusUnauthorized,
+		},
+		{
+			name:       "missing exp",
+			token:      sign(jwt.MapClaims{"sub": "42", "iss": "gateway", "aud": "api"}),
+			wantStatus: fasthttp.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, called := runMiddleware(t, mw, tt.token)
+			if called != (tt.wantStatus == fasthttp.StatusOK) {
+				t.Fatalf("next called = %v, status %d", called, ctx.Response.StatusCode())
+			}
+			if ctx.Response.StatusCode() != tt.wantStatus {
+				t.Errorf("status = %d, want %d", ctx.Response.StatusCode(), tt.wantStatus)
+			}
+			if tt.wantUserId != "" {
+				if got := string(ctx.Request.Header.Peek("X-User-Id")); got != tt.wantUserId {
+					t.Errorf("X-User-Id = %q, want %q", got, tt.wantUserId)
+				}
+				if got := string(ctx.Request.Header.Peek("X-Tenant")); got != "7" {
+					t.Errorf("X-Tenant = %q, want 7", got)
+				}
+			}
+		})
+	}
+}
+
+func TestJwtAuthMiddlewareAllowMissingExp(t *testing.T) {
+	mw, err := NewJwtAuthMiddleware("jwt", &dynamic.JwtAuth{Secret: "secret", AllowMissingExp: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "42"}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx, called := runMiddleware(t, mw, token); !called {
+		t.Errorf("token without exp rejected, status %d", ctx.Response.StatusCode())
+	}
+}
+
+func TestJwtAuthMiddlewareJwks(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	defer srv.Close()
+
+	mw, err := NewJwtAuthMiddleware("jwt", &dynamic.JwtAuth{
+		JwksURL:         srv.URL,
+		Algorithms:      []string{"RS256"},
+		ClaimsToHeaders: map[string]string{"X-User-Id": "sub"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+		token.Header["kid"] = kid
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	ctx, called := runMiddleware(t, mw, sign("k1"))
+	if !called {
+		t.Fatalf("expected request to pass, status %d body %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	if got := string(ctx.Request.Header.Peek("X-User-Id")); got != "alice" {
+		t.Errorf("X-User-Id = %q, want alice", got)
+	}
+
+	// 第二次请求命中缓存
+	if _, called = runMiddleware(t, mw, sign("k1")); !called {
+		t.Fatal("expected cached key to validate token")
+	}
+	if hits != 1 {
+		t.Errorf("jwks fetched %d times, want 1", hits)
+	}
+
+	ctx, called = runMiddleware(t, mw, sign("unknown"))
+	if called || ctx.Response.StatusCode() != fasthttp.StatusUnauthorized {
+		t.Errorf("unknown kid: called = %v, status = %d", called, ctx.Response.StatusCode())
+	}
+	if got := string(ctx.Request.Header.Peek("X-User-Id")); got != "spoofed" {
+		t.Errorf("rejected request header modified: %q", got)
+	}
+}
+
+func TestJwksFetchRateLimited(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	// jwks服务不可用时, 每个请求都不能同步请求jwks
+	c := getJwksCache(srv.URL, time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Key(context.Background(), "k1"); err == nil {
+				t.Error("expected error when jwks is unavailable")
+			}
+		}()
+	}
+	wg.Wait()
+	if _, err := c.Key(context.Background(), "k1"); err == nil {
+		t.Error("expected error when jwks is unavailable")
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("jwks fetched %d times, want 1", n)
+	}
+}
+
+func TestJwtAuthMiddlewareRequiresKey(t *testing.T) {
+	if _, err := NewJwtAuthMiddleware("jwt", &dynamic.JwtAuth{}); err == nil {
+		t.Fatal("expected error without any key source")
+	}
+}
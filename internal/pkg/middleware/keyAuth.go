@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"go-faster-gateway/internal/pkg/constants"
+	"go-faster-gateway/internal/pkg/data"
+	"go-faster-gateway/internal/pkg/data/tables"
+	"go-faster-gateway/internal/pkg/ecode"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/helper/cache"
+	"go-faster-gateway/pkg/log"
+	"strconv"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	defaultApiKeyHeader     = "X-Api-Key"
+	defaultConsumerHeader   = "X-Consumer-Name"
+	consumerIdHeader        = "X-Consumer-Id"
+	defaultKeyAuthCacheTTL  = time.Minute
+	defaultKeyAuthCacheSize = 10000
+)
+
+type keyAuth struct {
+	name           string
+	headerName     string
+	queryParam     string
+	consumerHeader string
+	removeKey      bool
+	store          data.IConsumerData
+	// key哈希 -> 调用方, 未找到的key缓存为nil, 防止无效key反复查库
+	cache *cache.LRU[string, *data.Consumer]
+}
+
+// NewKeyAuthMiddleware api key鉴权中间件, 通过key查找调用方并放入请求上下文
+func NewKeyAuthMiddleware(name string, conf *dynamic.KeyAuth, store data.IConsumerData) (MiddlewareFunc, error) {
+	ka := &keyAuth{
+		name:           name,
+		headerName:     conf.HeaderName,
+		queryParam:     conf.QueryParam,
+		consumerHeader: conf.ConsumerHeader,
+		removeKey:      conf.RemoveKey,
+		store:          store,
+	}
+	if ka.headerName == "" {
+		ka.headerName = defaultApiKeyHeader
+	}
+	if ka.consumerHeader == "" {
+		ka.consumerHeader = defaultConsumerHeader
+	}
+	ttl := time.Duration(conf.CacheTTL)
+	if ttl <= 0 {
+		ttl = defaultKeyAuthCacheTTL
+	}
+	size := conf.CacheSize
+	if size <= 0 {
+		size = defaultKeyAuthCacheSize
+	}
+	ka.cache = cache.NewLRU[string, *data.Consumer](size, ttl)
+	return ka.handle, nil
+}
+
+func (ka *keyAuth) handle(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		key := string(ctx.Request.Header.Peek(ka.headerName))
+		fromQuery := false
+		if key == "" && ka.queryParam != "" {
+			key = string(ctx.QueryArgs().Peek(ka.queryParam))
+			fromQuery = true
+		}
+		if key == "" {
+			abortWithError(ctx, ecode.ApiKeyMissingErr)
+			return
+		}
+
+		consumer, err := ka.lookup(ctx, tables.HashApiKey(key))
+		if err != nil {
+			log.WithContext(ctx).WithError(err).WithFields(map[string]interface{}{log.MiddlewareName: ka.name}).Error("lookup api key fail")
+			abortWithError(ctx, ecode.InternalServerErrorErr)
+			return
+		}
+		if consumer == nil {
+			abortWithError(ctx, ecode.ApiKeyInvalidErr)
+			return
+		}
+
+		ctx.SetUserValue(constants.ConsumerKey, consumer)
+		ctx.Request.Header.Set(ka.consumerHeader, consumer.Name)
+		ctx.Request.Header.Set(consumerIdHeader, strconv.FormatUint(uint64(consumer.Id), 10))
+		if ka.removeKey {
+			if fromQuery {
+				ctx.QueryArgs().Del(ka.queryParam)
+				ctx.Request.URI().SetQueryStringBytes(ctx.QueryArgs().QueryString())
+			} else {
+				ctx.Request.Header.Del(ka.headerName)
+			}
+		}
+		next(ctx)
+	}
+}
+
+func (ka *keyAuth) lookup(ctx *fasthttp.RequestCtx, keyHash string) (*data.Consumer, error) {
+	if consumer, ok := ka.cache.Get(keyHash); ok {
+		return consumer, nil
+	}
+	consumer, err := ka.store.GetConsumerByKeyHash(ctx, keyHash)
+	if err != nil {
+		return nil, err
+	}
+	ka.cache.Set(keyHash, consumer)
+	return consumer, nil
+}
+
+// ConsumerFromCtx 获取鉴权中间件放入请求上下文的调用方信息
+func ConsumerFromCtx(ctx *fasthttp.RequestCtx) (*data.Consumer, bool) {
+	consumer, ok := ctx.UserValue(constants.ConsumerKey).(*data.Consumer)
+	return consumer, ok
+}
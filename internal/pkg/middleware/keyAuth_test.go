@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"go-faster-gateway/internal/pkg/data"
+	"go-faster-gateway/internal/pkg/data/tables"
+	"go-faster-gateway/pkg/config/dynamic"
+)
+
+type fakeConsumerData struct {
+	consumers map[string]*data.Consumer
+	calls     int
+}
+
+func (f *fakeConsumerData) GetConsumerByKeyHash(_ context.Context, keyHash string) (*data.Consumer, error) {
+	f.calls++
+	return f.consumers[keyHash], nil
+}
+
+func TestKeyAuthMiddleware(t *testing.T) {
+	store := &fakeConsumerData{consumers: map[string]*data.Consumer{
+		tables.HashApiKey("secret-key"): {Id: 3, Name: "partner"},
+	}}
+	mw, err := NewKeyAuthMiddleware("key", &dynamic.KeyAuth{QueryParam: "apikey", RemoveKey: true}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(uri, header string) (*fasthttp.RequestCtx, *data.Consumer) {
+		ctx := &fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI(uri)
+		if header != "" {
+			ctx.Request.Header.Set("X-Api-Key", header)
+		}
+		var consumer *data.Consumer
+		mw(func(ctx *fasthttp.RequestCtx) { consumer, _ = ConsumerFromCtx(ctx) })(ctx)
+		return ctx, consumer
+	}
+
+	ctx, consumer := run("/orders", "secret-key")
+	if consumer == nil || consumer.Name != "partner" {
+		t.Fatalf("consumer = %+v, status %d", consumer, ctx.Response.StatusCode())
+	}
+	if got := string(ctx.Request.Header.Peek("X-Consumer-Name")); got != "partner" {
+		t.Errorf("X-Consumer-Name = %q", got)
+	}
+	if len(ctx.Request.Header.Peek("X-Api-Key")) != 0 {
+		t.Error("api key header should be removed")
+	}
+
+	ctx, consumer = run("/orders?apikey=secret-key&a=1", "")
+	if consumer == nil {
+		t.Fatalf("query key rejected, status %d", ctx.Response.StatusCode())
+	}
+	if got := string(ctx.Request.URI().QueryString()); got != "a=1" {
+		t.Errorf("query string = %q, want a=1", got)
+	}
+	if store.calls != 1 {
+		t.Errorf("store called %d times, want 1 (cached)", store.calls)
+	}
+
+	for i := 0; i < 2; i++ {
+		ctx, consumer = run("/orders", "wrong")
+		if consumer != nil || ctx.Response.StatusCode() != fasthttp.StatusUnauthorized {
+			t.Errorf("wrong key: consumer = %+v, status %d", consumer, ctx.Response.StatusCode())
+		}
+	}
+	if store.calls != 2 {
+		t.Errorf("store called %d times, want 2 (negative lookups cached)", store.calls)
+	}
+
+	if ctx, _ = run("/orders", ""); ctx.Response.StatusCode() != fasthttp.StatusUnauthorized {
+		t.Errorf("missing key status = %d", ctx.Response.StatusCode())
+	}
+}
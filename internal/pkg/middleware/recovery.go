@@ -14,7 +14,7 @@ func RecoveryMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
 		defer func() {
 			if r := recover(); r != nil {
 				// 发生panic时的处理逻辑
-				log.Log.Error("panic", zap.Any("err", r))
+				log.WithContext(ctx).Error("panic", zap.Any("err", r))
 				// 返回500 Internal Server Error给客户端
 				ctx.Error(ecode.InternalServerErrorErr.Data(), ecode.InternalServerErrorErr.HttpCode)
 			}
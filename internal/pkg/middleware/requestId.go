@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"fmt"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/helper/sid"
+	"go-faster-gateway/pkg/helper/uuid"
+	"go-faster-gateway/pkg/log"
+	"strings"
+	"sync"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	defaultRequestIdHeader = "X-Request-Id"
+	// 客户端传入的请求id超过该长度时重新生成, 防止超长头污染日志
+	maxRequestIdLength = 128
+)
+
+var (
+	// sonyflake同一进程只能有一个实例, 否则可能生成重复id
+	sidOnce sync.Once
+	sidGen  *sid.Sid
+	sidErr  error
+)
+
+// RequestIdMiddleware 默认配置的请求id中间件, 用于globalMiddleware
+func RequestIdMiddleware(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return requestIdHandler(defaultRequestIdHeader, false, uuid.GenUUID)(next)
+}
+
+// NewRequestIdMiddleware 请求id中间件, 沿用客户端传入的请求id或者生成一个新的,
+// 写入上游请求头,响应头和请求上下文, 日志通过log.WithContext自动带上请求id
+func NewRequestIdMiddleware(conf *dynamic.RequestId) (MiddlewareFunc, error) {
+	header := conf.HeaderName
+	if header == "" {
+		header = defaultRequestIdHeader
+	}
+	var gen func() string
+	switch strings.ToLower(conf.Generator) {
+	case "", "uuid":
+		gen = uuid.GenUUID
+	case "sid":
+		s, err := getSid()
+		if err != nil {
+			return nil, err
+		}
+		gen = func() string {
+			id, err := s.GenString()
+			if err != nil {
+				return uuid.GenUUID()
+			}
+			return id
+		}
+	default:
+		return nil, fmt.Errorf("requestId: unknown generator %q", conf.Generator)
+	}
+	return requestIdHandler(header, conf.IgnoreIncoming, gen), nil
+}
+
+func requestIdHandler(header string, ignoreIncoming bool, gen func() string) MiddlewareFunc {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			var id string
+			if !ignoreIncoming {
+				if v := ctx.Request.Header.Peek(header); len(v) > 0 && len(v) <= maxRequestIdLength {
+					id = string(v)
+				}
+			}
+			if id == "" {
+				id = gen()
+				ctx.Request.Header.Set(header, id)
+			}
+			ctx.SetUserValue(log.RequestIdKey, id)
+			next(ctx)
+			// 上游响应会覆盖响应头, 所以在后面设置
+			ctx.Response.Header.Set(header, id)
+		}
+	}
+}
+
+// RequestIdFromCtx 获取当前请求的请求id
+func RequestIdFromCtx(ctx *fasthttp.RequestCtx) string {
+	id, _ := ctx.UserValue(log.RequestIdKey).(string)
+	return id
+}
+
+func getSid() (s *sid.Sid, err error) {
+	sidOnce.Do(func() {
+		defer func() {
+			// 没有可用的私有ip时sonyflake创建失败会panic
+			if r := recover(); r != nil {
+				sidErr = fmt.Errorf("requestId: create sonyflake: %v", r)
+			}
+		}()
+		sidGen = sid.NewSid()
+	})
+	return sidGen, sidErr
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"go-faster-gateway/pkg/config/dynamic"
+)
+
+func TestRequestIdMiddleware(t *testing.T) {
+	mw, err := NewRequestIdMiddleware(&dynamic.RequestId{HeaderName: "X-Trace-Id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(incoming string) (*fasthttp.RequestCtx, string) {
+		ctx := &fasthttp.RequestCtx{}
+		if incoming != "" {
+			ctx.Request.Header.Set("X-Trace-Id", incoming)
+		}
+		var seen string
+		mw(func(ctx *fasthttp.RequestCtx) {
+			seen = string(ctx.Request.Header.Peek("X-Trace-Id"))
+			// 模拟上游响应覆盖响应头
+			ctx.Response.Reset()
+		})(ctx)
+		return ctx, seen
+	}
+
+	ctx, seen := run("abc")
+	if seen != "abc" || RequestIdFromCtx(ctx) != "abc" {
+		t.Errorf("incoming id not reused: upstream %q, ctx %q", seen, RequestIdFromCtx(ctx))
+	}
+	if got := string(ctx.Response.Header.Peek("X-Trace-Id")); got != "abc" {
+		t.Errorf("response header = %q, want abc", got)
+	}
+
+	ctx, seen = run("")
+	if seen == "" || seen != RequestIdFromCtx(ctx) || seen != string(ctx.Response.Header.Peek("X-Trace-Id")) {
+		t.Errorf("generated id not propagated: upstream %q, ctx %q", seen, RequestIdFromCtx(ctx))
+	}
+
+	if _, err = NewRequestIdMiddleware(&dynamic.RequestId{Generator: "unknown"}); err == nil {
+		t.Error("expected error for unknown generator")
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"errors"
 	"github.com/valyala/fasthttp"
 	"go-faster-gateway/internal/pkg/balancer"
+	"go-faster-gateway/internal/pkg/constants"
 	"go-faster-gateway/internal/pkg/ecode"
+	"go-faster-gateway/internal/pkg/metrics"
+	"go-faster-gateway/internal/pkg/tracing"
 	"go-faster-gateway/pkg/config/dynamic"
 	"go-faster-gateway/pkg/log"
 	"strings"
@@ -36,20 +39,32 @@ func (h *HTTPHandler) Handle(ctx *fasthttp.RequestCtx, routerInfo *dynamic.Servi
 	req := fasthttp.AcquireRequest()
 	defer fasthttp.ReleaseRequest(req)
 
+	streaming := routerInfo.Streaming != nil
 	// 复制客户端请求的数据
 	ctx.Request.CopyTo(req)
-	req.SetBody(ctx.PostBody())
+	stream := ctx.RequestBodyStream()
+	if streaming && stream != nil {
+		// 请求体边读边发给上游. 较小的请求体入口已经读到内存中, 按普通请求处理
+		req.SetBodyStream(&requestBodyStream{r: stream, conn: ctx.Conn()}, ctx.Request.Header.ContentLength())
+	} else {
+		req.SetBody(ctx.PostBody())
+	}
 
+	ctx.SetUserValue(constants.ServiceNameKey, routerInfo.ServiceName)
 	// 获取负载均衡地址
-	upstreamServer, err := h.upstreamManager.GetLBUpstream(routerInfo.ServiceName, routerInfo)
+	node, err := h.upstreamManager.GetLBUpstream(routerInfo.ServiceName)
 	if err != nil {
-		ctx.Error(err.Error(), ecode.InternalServerErrorErr.Code)
+		var e *ecode.Response
+		if errors.As(err, &e) && e.HttpCode > 0 {
+			ctx.Error(e.Data(), e.HttpCode)
+		} else {
+			ctx.Error(err.Error(), ecode.InternalServerErrorErr.HttpCode)
+		}
 		return
 	}
-	proxy := fasthttp.HostClient{
-		Addr:  upstreamServer,
-		IsTLS: ctx.IsTLS(),
-	}
+	upstreamServer := node.Addr()
+	// 结点的连接池由上游注册表管理, 请求之间复用连接
+	proxy := node.Client()
 	var proxyPath string
 	//TODO match route
 	proxyPath = string(ctx.Path())
@@ -58,26 +73,72 @@ func (h *HTTPHandler) Handle(ctx *fasthttp.RequestCtx, routerInfo *dynamic.Servi
 	//} else {
 	//	proxyPath = routerInfo.Routers.ProxyPath
 	//}
-	req.SetRequestURI("http://" + upstreamServer + proxyPath)
-	// 创建一个新的响应
+	req.SetRequestURI(node.URL(proxyPath))
+	ctx.SetUserValue(constants.UpstreamAddrKey, upstreamServer)
+	// 创建一个新的响应, 流式响应在写给客户端之后释放
 	resp := fasthttp.AcquireResponse()
-	defer fasthttp.ReleaseResponse(resp)
+	resp.StreamBody = streaming
 
 	// 向目标后端服务器发送请求
-	err = proxy.DoTimeout(req, resp, time.Second*5)
+	span := tracing.StartUpstreamSpan(ctx, req, upstreamServer)
+	nodeKey := node.Key()
+	h.upstreamManager.GetUpstream().Inc(routerInfo.ServiceName, nodeKey)
+	done := func() {
+		h.upstreamManager.GetUpstream().Done(routerInfo.ServiceName, nodeKey)
+	}
+	start := time.Now()
+	if streaming {
+		err = doStreaming(proxy, req, resp, time.Duration(routerInfo.Streaming.Timeout))
+	} else {
+		err = proxy.DoTimeout(req, resp, time.Second*5)
+	}
+	tracing.EndUpstreamSpan(span, resp, err)
+	upstreamLatency := time.Since(start)
+	ctx.SetUserValue(constants.UpstreamLatencyKey, upstreamLatency)
+	metrics.ObserveUpstream(routerInfo.ServiceName, upstreamServer, upstreamLatency, err)
 	if err != nil {
-		log.Log.WithError(err).Error("fasthttp.doTimeout()")
+		done()
+		fasthttp.ReleaseResponse(resp)
+		log.WithContext(ctx).WithError(err).Error("fasthttp.doTimeout()")
 		if errors.Is(err, fasthttp.ErrTimeout) {
 			ctx.Error(ecode.BackendTimeoutErr.Data(), ecode.BackendTimeoutErr.HttpCode)
 		} else {
 			ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
 		}
+		if streaming && stream != nil {
+			// 请求体可能没有读完, 连接不能继续使用
+			ctx.SetConnectionClose()
+		}
 		return
 	}
+	if streaming && stream != nil {
+		// 请求体已经完整发给上游, 入口可以继续复用客户端连接
+		ctx.SetUserValue(constants.BodyConsumedKey, true)
+	}
 	// 将目标服务器的响应返回给客户端
 	// 将目标服务器的响应头部和主体复制到当前请求对象中
 	resp.Header.CopyTo(&ctx.Response.Header)
+	if resp.BodyStream() != nil {
+		// 响应头先发给客户端, 响应体收到一块转发一块. 上游连接在响应体写完后放回连接池
+		size := resp.Header.ContentLength()
+		if size < 0 {
+			size = -1
+		}
+		ctx.Response.ImmediateHeaderFlush = true
+		ctx.Response.SetBodyStream(&responseBodyStream{resp: resp, conn: ctx.Conn(), done: done}, size)
+		return
+	}
+	done()
 	ctx.Response.SetBody(resp.Body())
+	fasthttp.ReleaseResponse(resp)
+}
+
+// doStreaming 流式请求的超时包括读取响应体. 为0时不设置超时, SSE等长连接在上游结束响应或者客户端断开后结束
+func doStreaming(proxy *fasthttp.HostClient, req *fasthttp.Request, resp *fasthttp.Response, timeout time.Duration) error {
+	if timeout <= 0 {
+		return proxy.Do(req, resp)
+	}
+	return proxy.DoTimeout(req, resp, timeout)
 }
 
 func (h *HTTPHandler) Supports(ctx *fasthttp.RequestCtx) bool {
@@ -0,0 +1,148 @@
+package protocols
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go-faster-gateway/internal/pkg/balancer"
+	"go-faster-gateway/internal/pkg/middleware"
+	"go-faster-gateway/pkg/config/dynamic"
+)
+
+// startGateway 用和入口相同的配置启动代理, 返回代理的地址
+func startGateway(t *testing.T, handler fasthttp.RequestHandler) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fasthttp.Server{
+		Handler:                      handler,
+		ReadTimeout:                  5 * time.Second,
+		WriteTimeout:                 5 * time.Second,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+	}
+	go server.Serve(ln)
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return "http://" + ln.Addr().String()
+}
+
+// upload 发送请求体的同时读取响应. 网关返回413后不再读取请求体并关闭连接,
+// net/http的客户端可能在写请求体时先收到连接重置, 拿不到已经返回的响应
+func upload(t *testing.T, gateway, path string, body []byte) (int, []byte) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(gateway, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	go func() {
+		fmt.Fprintf(conn, "POST %s HTTP/1.1\r\nHost: gw\r\nContent-Length: %d\r\n\r\n", path, len(body))
+		_, _ = conn.Write(body)
+	}()
+	var resp fasthttp.Response
+	if err = resp.Read(bufio.NewReader(conn)); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode(), resp.Body()
+}
+
+func TestStreaming(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/events":
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, "data: 1\n\n")
+			w.(http.Flusher).Flush()
+			<-release
+			fmt.Fprint(w, "data: 2\n\n")
+		case "/upload":
+			n, _ := io.Copy(io.Discard, r.Body)
+			fmt.Fprint(w, n)
+		}
+	}))
+	defer upstream.Close()
+	defer close(release)
+	addr := upstream.Listener.Addr().(*net.TCPAddr)
+
+	route := &dynamic.ServiceRoute{
+		ServiceName: "stream",
+		Servers:     []dynamic.Server{{Host: "127.0.0.1", Port: uint64(addr.Port), Weight: 1, Healthy: true}},
+		Streaming:   &dynamic.Streaming{},
+	}
+	buffered := *route
+	buffered.Streaming = nil
+	manager := balancer.NewUpstreamManager()
+	if err := manager.Reload([]*dynamic.ServiceRoute{route}, nil); err != nil {
+		t.Fatal(err)
+	}
+	h := NewHTTPHandler(manager)
+	gateway := startGateway(t, func(ctx *fasthttp.RequestCtx) {
+		if string(ctx.QueryArgs().Peek("buffered")) != "" {
+			middleware.BufferBodyMiddleware(func(ctx *fasthttp.RequestCtx) { h.Handle(ctx, &buffered) })(ctx)
+			return
+		}
+		h.Handle(ctx, route)
+	})
+
+	// 上游还没有结束响应, 第一个事件就应该到达客户端
+	resp, err := http.Get(gateway + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	events := make(chan string, 2)
+	go func() {
+		r := bufio.NewReader(resp.Body)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(events)
+				return
+			}
+			if line != "\n" {
+				events <- line
+			}
+		}
+	}()
+	select {
+	case e := <-events:
+		if e != "data: 1\n" {
+			t.Fatalf("unexpected event %q", e)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("first event was buffered by the proxy")
+	}
+
+	// 超过缓存上限的请求体, 流式路由转发给上游, 普通路由返回413
+	body := bytes.Repeat([]byte("x"), fasthttp.DefaultMaxRequestBodySize*2)
+	for _, c := range []struct {
+		query string
+		code  int
+		body  string
+	}{
+		{"", http.StatusOK, strconv.Itoa(len(body))},
+		{"?buffered=1", http.StatusRequestEntityTooLarge, ""},
+	} {
+		code, b := upload(t, gateway, "/upload"+c.query, body)
+		if code != c.code || (c.body != "" && string(b) != c.body) {
+			t.Errorf("upload%s: got %d %q", c.query, code, b)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package protocols
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// streamIOTimeout 流式转发时客户端连接每次读写的超时, 和入口的读写超时一致.
+// 入口的超时只在请求开始时设置一次, 流式转发每传输一块数据后重新计时, 长时间的上传下载和SSE不会被断开
+const streamIOTimeout = 5 * time.Second
+
+// requestBodyStream 客户端的请求体, 上游连接写得慢时不会继续读取客户端
+type requestBodyStream struct {
+	r    io.Reader
+	conn net.Conn
+}
+
+func (s *requestBodyStream) Read(p []byte) (int, error) {
+	if s.conn != nil {
+		_ = s.conn.SetReadDeadline(time.Now().Add(streamIOTimeout))
+	}
+	return s.r.Read(p)
+}
+
+// responseBodyStream 上游的响应体, fasthttp每读到一块就写给客户端并flush.
+// 客户端读得慢时写操作阻塞, 不会继续读取上游, 网关中最多缓存一块数据
+type responseBodyStream struct {
+	resp *fasthttp.Response
+	conn net.Conn
+	done func()
+}
+
+func (s *responseBodyStream) Read(p []byte) (int, error) {
+	n, err := s.resp.BodyStream().Read(p)
+	if n > 0 && s.conn != nil {
+		_ = s.conn.SetWriteDeadline(time.Now().Add(streamIOTimeout))
+	}
+	return n, err
+}
+
+// CloseWithError 响应写完或者写出失败后由fasthttp调用.
+// 写出失败时上游连接上还有没读完的数据, 关闭连接而不是放回连接池
+func (s *responseBodyStream) CloseWithError(err error) error {
+	if err != nil {
+		s.resp.SetConnectionClose()
+	}
+	closeErr := s.resp.CloseBodyStream()
+	fasthttp.ReleaseResponse(s.resp)
+	s.done()
+	return closeErr
+}
@@ -59,7 +59,7 @@ func (h *HeaderChecker) Weight() int {
 }
 
 func (h *HeaderChecker) MatchCheck(req interface{}) bool {
-	request, ok := req.(fasthttp.RequestCtx)
+	request, ok := req.(*fasthttp.RequestCtx)
 	if !ok {
 		return false
 	}
@@ -78,7 +78,7 @@ func (c *CookieChecker) Weight() int {
 }
 
 func (c *CookieChecker) MatchCheck(req interface{}) bool {
-	request, ok := req.(fasthttp.RequestCtx)
+	request, ok := req.(*fasthttp.RequestCtx)
 	if !ok {
 		return false
 	}
@@ -97,7 +97,7 @@ func (q *QueryChecker) Weight() int {
 }
 
 func (q *QueryChecker) MatchCheck(req interface{}) bool {
-	request, ok := req.(fasthttp.RequestCtx)
+	request, ok := req.(*fasthttp.RequestCtx)
 	if !ok {
 		return false
 	}
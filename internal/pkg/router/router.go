@@ -7,6 +7,7 @@ import (
 	"go-faster-gateway/internal/pkg/protocols"
 	"go-faster-gateway/pkg/config/dynamic"
 	"go-faster-gateway/pkg/helper/md5"
+	"sort"
 	"strings"
 	"sync"
 
@@ -19,6 +20,7 @@ type IRouter interface {
 	BuildRouter([]*dynamic.ServiceRoute, *middleware.MiddlewareHandler)
 	Match(key string) *dynamic.ServiceRoute
 	GetMd5() string
+	GetRoutes() []*dynamic.ServiceRoute
 }
 
 // 静态路由处理
@@ -144,13 +146,15 @@ func (sr *DyRouter) loadSubrouter(routeInfo dynamic.Router, routeCfg *dynamic.Se
 	sr.SubRouters[routeCfg.ServiceName] = subRouter
 
 	// 每个路由对应的中间件不一样
-	var handlers []middleware.MiddlewareFunc
+	handlers := bodyMiddlewares(routeCfg)
 	for _, mw := range routeCfg.Middlewares {
 		if h, ok := mwHandler.Handler[strings.ToLower(mw)]; ok {
 			handlers = append(handlers, h)
 		}
 	}
+	routePath := routeInfo.Prefix + routeInfo.Path
 	h := func(ctx *fasthttp.RequestCtx) {
+		ctx.SetUserValue(constants.RouteKey, routePath)
 		handler := sr.ProtocolFactory.GetHandler(ctx)
 		//具体处理的事件
 		handler.Handle(ctx, temp)
@@ -174,13 +178,15 @@ func (sr *DyRouter) loadSubrouter(routeInfo dynamic.Router, routeCfg *dynamic.Se
 func (sr *DyRouter) loadWildcardRoute(currentRoute *fasthttprouter.Router, routeInfo dynamic.Router, routeCfg *dynamic.ServiceRoute, mwHandler *middleware.MiddlewareHandler) error {
 	temp := routeCfg
 	// 每个路由对应的中间件不一样
-	var handlers []middleware.MiddlewareFunc
+	handlers := bodyMiddlewares(routeCfg)
 	for _, mw := range routeCfg.Middlewares {
 		if h, ok := mwHandler.Handler[strings.ToLower(mw)]; ok {
 			handlers = append(handlers, h)
 		}
 	}
+	routePath := routeInfo.Prefix + routeInfo.Path
 	h := func(ctx *fasthttp.RequestCtx) {
+		ctx.SetUserValue(constants.RouteKey, routePath)
 		handler := sr.ProtocolFactory.GetHandler(ctx)
 		//具体处理的事件
 		handler.Handle(ctx, temp)
@@ -199,13 +205,15 @@ func (sr *DyRouter) loadWildcardRoute(currentRoute *fasthttprouter.Router, route
 func (sr *DyRouter) loadStandardRoute(currentRoute *fasthttprouter.Router, routeInfo dynamic.Router, routeCfg *dynamic.ServiceRoute, mwHandler *middleware.MiddlewareHandler) error {
 	temp := routeCfg
 	// 每个路由对应的中间件不一样
-	var handlers []middleware.MiddlewareFunc
+	handlers := bodyMiddlewares(routeCfg)
 	for _, mw := range routeCfg.Middlewares {
 		if h, ok := mwHandler.Handler[strings.ToLower(mw)]; ok {
 			handlers = append(handlers, h)
 		}
 	}
+	routePath := routeInfo.Prefix + routeInfo.Path
 	h := func(ctx *fasthttp.RequestCtx) {
+		ctx.SetUserValue(constants.RouteKey, routePath)
 		handler := sr.ProtocolFactory.GetHandler(ctx)
 		//具体处理的事件
 		handler.Handle(ctx, temp)
@@ -217,6 +225,14 @@ func (sr *DyRouter) loadStandardRoute(currentRoute *fasthttprouter.Router, route
 	return nil
 }
 
+// bodyMiddlewares 非流式路由在其他中间件之前把请求体读到内存中
+func bodyMiddlewares(routeCfg *dynamic.ServiceRoute) []middleware.MiddlewareFunc {
+	if routeCfg.Streaming != nil {
+		return nil
+	}
+	return []middleware.MiddlewareFunc{middleware.BufferBodyMiddleware}
+}
+
 func (sr *DyRouter) registerRoutePattenByMode(currentRoute *fasthttprouter.Router, route dynamic.Router, chains fasthttp.RequestHandler, webSocketType string) {
 	//websocket 特殊处理
 	if len(route.Methods) == 0 && webSocketType == constants.WebSocket {
@@ -265,3 +281,17 @@ func (sr *DyRouter) Match(_ string) *dynamic.ServiceRoute {
 func (sr *DyRouter) GetMd5() string {
 	return sr.Md5
 }
+
+// GetRoutes 获取当前加载的所有路由, 按服务名排序
+func (sr *DyRouter) GetRoutes() []*dynamic.ServiceRoute {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+	routes := make([]*dynamic.ServiceRoute, 0, len(sr.apis))
+	for _, v := range sr.apis {
+		routes = append(routes, v)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].ServiceName < routes[j].ServiceName
+	})
+	return routes
+}
@@ -2,15 +2,20 @@ package router
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"go-faster-gateway/internal/pkg/balancer"
 	"go-faster-gateway/internal/pkg/constants"
-	"go-faster-gateway/internal/pkg/data"
 	"go-faster-gateway/internal/pkg/data/provider"
 	"go-faster-gateway/internal/pkg/middleware"
 	"go-faster-gateway/internal/pkg/protocols"
+	"go-faster-gateway/internal/pkg/tracing"
 	"go-faster-gateway/pkg/config/dynamic"
 	"go-faster-gateway/pkg/helper/utils"
+	"go-faster-gateway/pkg/log"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/valyala/fasthttp"
 )
@@ -21,8 +26,9 @@ type RouterManager struct {
 	UpstreamsManager  *balancer.UpstreamManager      // 上游服务，一般路由会保存上游服务的名称，转发到对应的上游服务上去，可以使用负载均衡算法
 	ProtocolManager   *protocols.ProtocolFactory
 	MiddlewareHandler *middleware.MiddlewareHandler
-	Router            IRouter                 // 路由相关信息
-	RouteDataProvider data.IRouteResourceData //路由数据
+	Router            IRouter                     // 路由相关信息
+	entryMiddlewares  []middleware.MiddlewareFunc // 入口中间件(访问日志等), 不随动态配置变化, 始终在最外层
+	mu                sync.RWMutex                // 保护Router和MiddlewareHandler的切换
 }
 
 func NewRouterManager(upstreamsManager *balancer.UpstreamManager,
@@ -35,10 +41,8 @@ func NewRouterManager(upstreamsManager *balancer.UpstreamManager,
 
 // CreateRouters creates new TCPRouters
 func (f *RouterManager) CreateRouters(ctx context.Context, conf dynamic.Configuration) error {
-	// TODO 路由数据源初始化(后期可能http+websocket+tcp 这边需要修改 成配置，抽象
-	f.RouteDataProvider = provider.NewRouteResourceFileData(conf.EasyServiceRoute.Services)
-	//routeData
-	routeDataList, err := f.RouteDataProvider.GetAllList(ctx)
+	// 路由数据只来自合并后的动态配置, 文件/数据库/管理接口等数据源都以provider的形式接入配置监听
+	routeDataList, err := serviceRoutes(conf.EasyServiceRoute.Services)
 	if err != nil {
 		return err
 	}
@@ -50,8 +54,16 @@ func (f *RouterManager) CreateRouters(ctx context.Context, conf dynamic.Configur
 	f.RegisterMiddleHandlers(conf)
 	r := NewDyRouter(f.ProtocolManager)
 	//这边只需要把http,https,websocket的
+	// 新路由生效前先切换上游注册表, 每个配置版本只构建一次
+	if f.UpstreamsManager != nil {
+		if err = f.UpstreamsManager.Reload(filteredRouteDataList, conf.ServersTransports); err != nil {
+			log.Log.WithError(err).Error("reload upstreams fail")
+		}
+	}
 	r.BuildRouter(filteredRouteDataList, f.MiddlewareHandler)
+	f.mu.Lock()
 	f.Router = r
+	f.mu.Unlock()
 	handler := r.MainRouter.Handler
 	if len(f.MiddlewareHandler.Handler) > 0 {
 		for i := len(conf.GlobalMiddleware) - 1; i >= 0; i-- {
@@ -62,31 +74,70 @@ func (f *RouterManager) CreateRouters(ctx context.Context, conf dynamic.Configur
 			}
 		}
 	}
-	f.HttpHandler = handler
+	f.HttpHandler = middleware.Chain(handler, f.entryMiddlewares...)
 	return nil
 }
 
+// serviceRoutes 按服务名-路由名分组的路由展开成列表, 服务名称为 服务名_路由名
+func serviceRoutes(services map[string]map[string]*dynamic.ServiceRoute) ([]*dynamic.ServiceRoute, error) {
+	if services == nil {
+		return nil, errors.New("route data is empty")
+	}
+	var list = make([]*dynamic.ServiceRoute, 0)
+	for k, v := range services {
+		for k1, v1 := range v {
+			v1.ServiceName = fmt.Sprintf("%s_%s", k, k1)
+			list = append(list, v1)
+		}
+	}
+	return list, nil
+}
+
+// GetRouter 获取当前生效的路由
+func (f *RouterManager) GetRouter() IRouter {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.Router
+}
+
+// GetMiddlewareNames 获取当前加载的中间件名称
+func (f *RouterManager) GetMiddlewareNames() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.MiddlewareHandler == nil {
+		return nil
+	}
+	names := make([]string, 0, len(f.MiddlewareHandler.Handler))
+	for k := range f.MiddlewareHandler.Handler {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Use 添加入口中间件, 需要在CreateRouters之前调用
+func (f *RouterManager) Use(mws ...middleware.MiddlewareFunc) {
+	f.entryMiddlewares = append(f.entryMiddlewares, mws...)
+}
+
 func (f *RouterManager) RegisterMiddleHandlers(conf dynamic.Configuration) {
 	var m middleware.MiddlewareHandler
 	m.Handler = make(map[string]middleware.MiddlewareFunc)
 	// 所有的有配置项的中间件，都会配置在middlewares中
-	//for k, v := range s.GetConfig().Middlewares {
-	//	tempConfig := v
-	//	switch {
-	//	case v.IPBlacklist != nil:
-	//		m.HttpHandler[k] = middleware.IpBlacklistMiddleware(&tempConfig, s)
-	//	case v.IPWhitelist != nil:
-	//		m.HttpHandler[k] = middleware.IpWhitelistMiddleware(&tempConfig, s)
-	//	case v.RequestID != nil:
-	//		m.HttpHandler[k] = middleware.RequestIdMiddleware(&tempConfig, s)
-	//	case v.CORS != nil:
-	//		m.HttpHandler[k] = middleware.CorsMiddleware(&tempConfig, s)
-	//	case v.RemoteAuth != nil:
-	//		m.HttpHandler[k] = middleware.RemoteAuthMiddleware(&tempConfig, s)
-	//	case v.TokenLimiter != nil:
-	//		m.HttpHandler[k] = middleware.TokenLimiterMiddleware(&tempConfig, s)
-	//	}
-	//}
+	for k, v := range conf.Middlewares {
+		if v == nil {
+			continue
+		}
+		key := strings.ToLower(k)
+		h, err := buildMiddleware(k, v)
+		if err != nil {
+			log.Log.WithError(err).WithFields(map[string]interface{}{log.MiddlewareName: k}).Error("build middleware fail")
+			continue
+		}
+		if h != nil {
+			m.Handler[key] = h
+		}
+	}
 	// 没配置的(主要是一些全局的中间件)
 	for _, v := range conf.GlobalMiddleware {
 		v := strings.ToLower(v)
@@ -98,7 +149,38 @@ func (f *RouterManager) RegisterMiddleHandlers(conf dynamic.Configuration) {
 			m.Handler[v] = middleware.RecoveryMiddleware
 		case v == "errorhandler":
 			m.Handler[v] = middleware.ErrorHandlerMiddleware
+		case v == "requestid":
+			m.Handler[v] = middleware.RequestIdMiddleware
 		}
 	}
+	// 每个中间件创建一个子span
+	for k, h := range m.Handler {
+		m.Handler[k] = tracing.WrapMiddleware(k, h)
+	}
+	f.mu.Lock()
 	f.MiddlewareHandler = &m
+	f.mu.Unlock()
+}
+
+// buildMiddleware 根据中间件配置项构建对应的中间件
+func buildMiddleware(name string, conf *dynamic.Middleware) (middleware.MiddlewareFunc, error) {
+	switch {
+	case conf.JwtAuth != nil:
+		return middleware.NewJwtAuthMiddleware(name, conf.JwtAuth)
+	case conf.ForwardAuth != nil:
+		return middleware.NewForwardAuthMiddleware(name, conf.ForwardAuth)
+	case conf.KeyAuth != nil:
+		return middleware.NewKeyAuthMiddleware(name, conf.KeyAuth, provider.NewConsumerDbData())
+	case conf.HmacAuth != nil:
+		return middleware.NewHmacAuthMiddleware(name, conf.HmacAuth, provider.NewHmacCredentialDbData())
+	case conf.RequestId != nil:
+		return middleware.NewRequestIdMiddleware(conf.RequestId)
+	case conf.RequestHeaderModifier != nil:
+		return middleware.NewRequestHeaderModifierMiddleware(conf.RequestHeaderModifier)
+	case conf.ResponseHeaderModifier != nil:
+		return middleware.NewResponseHeaderModifierMiddleware(conf.ResponseHeaderModifier)
+	case conf.URLRewrite != nil:
+		return middleware.NewURLRewriteMiddleware(conf.URLRewrite)
+	}
+	return nil, nil
 }
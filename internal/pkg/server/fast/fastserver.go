@@ -3,6 +3,7 @@ package fast
 import (
 	"fmt"
 	"github.com/valyala/fasthttp"
+	"go-faster-gateway/internal/pkg/constants"
 	"go-faster-gateway/pkg/config/static"
 	"go-faster-gateway/pkg/log"
 	"go.uber.org/zap"
@@ -24,9 +25,14 @@ func NewHttpServer(staticConfig *static.Configuration,
 		staticConfig: staticConfig,
 		handler:      handler,
 		appServer: &fasthttp.Server{
+			Handler:      guardBody(handler),
 			IdleTimeout:  60 * time.Second,
 			ReadTimeout:  5 * time.Second,
 			WriteTimeout: 5 * time.Second,
+			// 超过MaxRequestBodySize的和chunked的请求体不预先读完, 流式路由直接转发给上游,
+			// 其他路由由BufferBodyMiddleware读到内存中. 代理不解析multipart, 原样转发
+			StreamRequestBody:            true,
+			DisablePreParseMultipartForm: true,
 		},
 	}
 }
@@ -45,7 +51,7 @@ func (s *HttpServer) Start() {
 }
 
 func (s *HttpServer) Stop() error {
-	sign := make(chan os.Signal)
+	sign := make(chan os.Signal, 1)
 	signal.Notify(sign, syscall.SIGKILL, syscall.SIGTERM, syscall.SIGINT)
 	select {
 	case ss := <-sign:
@@ -80,5 +86,22 @@ func (s *HttpServer) startHTTPSProxy() error {
 }
 
 func (s *HttpServer) SwitchRouter(handler func(ctx *fasthttp.RequestCtx)) {
-	s.appServer.Handler = handler
+	s.appServer.Handler = guardBody(handler)
+}
+
+// guardBody fasthttp在同一个连接处理下一个请求前不会读完上一个请求没有读取的请求体流,
+// 剩下的数据会被当成新的请求解析(请求走私). 请求体没有读完时响应后关闭连接
+func guardBody(handler fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		handler(ctx)
+		// 读到内存中的请求体(PostBody/SetBody)会释放请求体流
+		if ctx.RequestBodyStream() != nil && ctx.UserValue(constants.BodyConsumedKey) != true {
+			ctx.SetConnectionClose()
+		}
+	}
+}
+
+// OpenConnections 当前打开的连接数
+func (s *HttpServer) OpenConnections() int32 {
+	return s.appServer.GetOpenConnectionsCount()
 }
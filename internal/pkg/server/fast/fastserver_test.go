@@ -0,0 +1,87 @@
+package fast
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go-faster-gateway/pkg/config/static"
+)
+
+func TestPipelinedBodyStream(t *testing.T) {
+	// chunked请求体中夹带了第二个请求
+	inner := "GET /smuggled HTTP/1.1\r\nHost: gw\r\n\r\n"
+	smuggled := "POST /ignore HTTP/1.1\r\nHost: gw\r\nTransfer-Encoding: chunked\r\n\r\n" +
+		fmt.Sprintf("%x\r\n%s\r\n0\r\n\r\n", len(inner), inner)
+	const plain = "GET /next HTTP/1.1\r\nHost: gw\r\n\r\n"
+
+	for _, c := range []struct {
+		name  string
+		raw   string
+		paths []string
+		close bool
+	}{
+		// 处理器没有读取请求体, 响应后关闭连接, 夹带的请求不会被处理
+		{"unread", smuggled + plain, []string{"/ignore"}, true},
+		// 请求体读完后连接继续处理下一个请求
+		{"read", strings.Replace(smuggled, "/ignore", "/read", 1) + plain, []string{"/read", "/next"}, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var paths []string
+			s := NewHttpServer(&static.Configuration{}, func(ctx *fasthttp.RequestCtx) {
+				mu.Lock()
+				paths = append(paths, string(ctx.Path()))
+				mu.Unlock()
+				if string(ctx.Path()) == "/read" {
+					ctx.PostBody()
+				}
+			})
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			go s.appServer.Serve(ln)
+			defer s.appServer.Shutdown()
+
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			_ = conn.SetDeadline(time.Now().Add(3 * time.Second))
+			if _, err = io.WriteString(conn, c.raw); err != nil {
+				t.Fatal(err)
+			}
+
+			br := bufio.NewReader(conn)
+			var resp fasthttp.Response
+			if err = resp.Read(br); err != nil {
+				t.Fatal(err)
+			}
+			if resp.ConnectionClose() != c.close {
+				t.Errorf("connection close: got %v, want %v", resp.ConnectionClose(), c.close)
+			}
+			if c.close {
+				// 关闭连接后不应该再有响应
+				if _, err = br.ReadByte(); err != io.EOF {
+					t.Errorf("connection should be closed, got %v", err)
+				}
+			} else if err = resp.Read(br); err != nil {
+				t.Fatal(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if strings.Join(paths, ",") != strings.Join(c.paths, ",") {
+				t.Errorf("served %v, want %v", paths, c.paths)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"go-faster-gateway/internal/pkg/metrics"
 	"go-faster-gateway/internal/pkg/router"
 	"go-faster-gateway/internal/pkg/server/fast"
 	configLoader "go-faster-gateway/pkg/config"
@@ -68,8 +69,10 @@ func (f *ServiceManager) SwitchFastHttpRouter(conf dynamic.Configuration) {
 	err := f.routeManager.CreateRouters(f.ctx, conf)
 	if err != nil {
 		log.Log.WithError(err).Error("SwitchFastHttpRouter CreateRouters fail")
+		metrics.ObserveConfigReload(false)
 		return
 	}
 	f.fastServer.SwitchRouter(f.routeManager.HttpHandler)
+	metrics.ObserveConfigReload(true)
 	log.Log.Info("SwitchFastHttpRouter success")
 }
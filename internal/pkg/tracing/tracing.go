@@ -0,0 +1,185 @@
+package tracing
+
+import (
+	"context"
+	"go-faster-gateway/internal/pkg/constants"
+	"go-faster-gateway/pkg/config/static"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "go-faster-gateway"
+
+// Setup 根据静态配置初始化全局的TracerProvider和传播器, 返回的函数在退出时调用, 用于刷新剩余的span.
+// 未调用Setup时使用otel默认的空实现, 所有span都是空操作
+func Setup(ctx context.Context, conf *static.Tracing) (func(context.Context) error, error) {
+	conf.SetDefaults()
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(conf.OTLP.Endpoint)}
+	if len(conf.OTLP.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(conf.OTLP.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var batchOpts []sdktrace.BatchSpanProcessorOption
+	if conf.OTLP.BatchTimeout > 0 {
+		batchOpts = append(batchOpts, sdktrace.WithBatchTimeout(time.Duration(conf.OTLP.BatchTimeout)))
+	}
+	if conf.OTLP.MaxExportBatchSize > 0 {
+		batchOpts = append(batchOpts, sdktrace.WithMaxExportBatchSize(conf.OTLP.MaxExportBatchSize))
+	}
+	if conf.OTLP.MaxQueueSize > 0 {
+		batchOpts = append(batchOpts, sdktrace.WithMaxQueueSize(conf.OTLP.MaxQueueSize))
+	}
+
+	tp := NewTracerProvider(conf, sdktrace.WithBatcher(exporter, batchOpts...))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(NewPropagator(conf))
+	return tp.Shutdown, nil
+}
+
+// NewTracerProvider 创建TracerProvider, 新的链路按sampleRate采样, 有上游链路时沿用上游的采样结果
+func NewTracerProvider(conf *static.Tracing, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	conf.SetDefaults()
+	opts = append([]sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(*conf.SampleRate))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(conf.ServiceName))),
+	}, opts...)
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+// NewPropagator W3C trace context + baggage, 开启b3时同时支持b3头
+func NewPropagator(conf *static.Tracing) propagation.TextMapPropagator {
+	propagators := []propagation.TextMapPropagator{propagation.TraceContext{}, propagation.Baggage{}}
+	if conf.B3 {
+		propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader|b3.B3SingleHeader)))
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...)
+}
+
+// Handler 入口中间件, 从请求头中提取上游链路并为每个请求创建server span
+func Handler(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		parent := otel.GetTextMapPropagator().Extract(context.Background(), RequestHeaderCarrier{Header: &ctx.Request.Header})
+		spanCtx, span := tracer().Start(parent, "HTTP "+string(ctx.Method()),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(string(ctx.Method())),
+				semconv.URLPath(string(ctx.Path())),
+				semconv.ClientAddress(ctx.RemoteIP().String()),
+			))
+		defer span.End()
+		ctx.SetUserValue(constants.TraceContextKey, spanCtx)
+
+		next(ctx)
+
+		status := ctx.Response.StatusCode()
+		if route, ok := ctx.UserValue(constants.RouteKey).(string); ok {
+			span.SetName(string(ctx.Method()) + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		if service, ok := ctx.UserValue(constants.ServiceNameKey).(string); ok {
+			span.SetAttributes(attribute.String("gateway.service", service))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= fasthttp.StatusInternalServerError {
+			span.SetStatus(codes.Error, fasthttp.StatusMessage(status))
+		}
+	}
+}
+
+// WrapMiddleware 为中间件创建子span, span包含该中间件及其之后的处理
+func WrapMiddleware(name string, mw func(fasthttp.RequestHandler) fasthttp.RequestHandler) func(fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		wrapped := mw(next)
+		return func(ctx *fasthttp.RequestCtx) {
+			parent := FromCtx(ctx)
+			if !trace.SpanFromContext(parent).IsRecording() {
+				wrapped(ctx)
+				return
+			}
+			spanCtx, span := tracer().Start(parent, "middleware "+name, trace.WithSpanKind(trace.SpanKindInternal))
+			ctx.SetUserValue(constants.TraceContextKey, spanCtx)
+			wrapped(ctx)
+			ctx.SetUserValue(constants.TraceContextKey, parent)
+			if status := ctx.Response.StatusCode(); status >= fasthttp.StatusBadRequest {
+				span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			}
+			span.End()
+		}
+	}
+}
+
+// StartUpstreamSpan 创建上游请求的client span并把链路信息注入到上游请求头
+func StartUpstreamSpan(ctx *fasthttp.RequestCtx, req *fasthttp.Request, upstreamAddr string) trace.Span {
+	spanCtx, span := tracer().Start(FromCtx(ctx), "upstream "+upstreamAddr,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(string(req.Header.Method())),
+			semconv.ServerAddress(upstreamAddr),
+		))
+	otel.GetTextMapPropagator().Inject(spanCtx, RequestHeaderCarrier{Header: &req.Header})
+	return span
+}
+
+// EndUpstreamSpan 记录上游请求结果并结束span
+func EndUpstreamSpan(span trace.Span, resp *fasthttp.Response, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode()))
+		if resp.StatusCode() >= fasthttp.StatusInternalServerError {
+			span.SetStatus(codes.Error, fasthttp.StatusMessage(resp.StatusCode()))
+		}
+	}
+	span.End()
+}
+
+// FromCtx 获取当前请求的链路上下文
+func FromCtx(ctx *fasthttp.RequestCtx) context.Context {
+	if c, ok := ctx.UserValue(constants.TraceContextKey).(context.Context); ok {
+		return c
+	}
+	return context.Background()
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// RequestHeaderCarrier fasthttp请求头的TextMapCarrier实现
+type RequestHeaderCarrier struct {
+	Header *fasthttp.RequestHeader
+}
+
+var _ propagation.TextMapCarrier = RequestHeaderCarrier{}
+
+func (c RequestHeaderCarrier) Get(key string) string {
+	return string(c.Header.Peek(key))
+}
+
+func (c RequestHeaderCarrier) Set(key, value string) {
+	c.Header.Set(key, value)
+}
+
+func (c RequestHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, c.Header.Len())
+	c.Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+	return keys
+}
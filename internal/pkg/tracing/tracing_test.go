@@ -0,0 +1,90 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"go-faster-gateway/internal/pkg/constants"
+	"go-faster-gateway/pkg/config/static"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracingPipeline(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	conf := &static.Tracing{B3: true}
+	tp := NewTracerProvider(conf, sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(NewPropagator(conf))
+	defer func() { _ = tp.Shutdown(context.Background()) }()
+
+	const parentTraceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	var upstreamReq fasthttp.Request
+	upstream := func(ctx *fasthttp.RequestCtx) {
+		ctx.SetUserValue(constants.RouteKey, "/orders/:id")
+		span := StartUpstreamSpan(ctx, &upstreamReq, "10.0.0.1:80")
+		ctx.Response.SetStatusCode(fasthttp.StatusOK)
+		EndUpstreamSpan(span, &ctx.Response, nil)
+	}
+	authMw := func(next fasthttp.RequestHandler) fasthttp.RequestHandler { return next }
+	handler := Handler(WrapMiddleware("auth", authMw)(upstream))
+
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.SetRequestURI("/orders/1")
+	ctx.Request.Header.Set("traceparent", "00-"+parentTraceId+"-00f067aa0ba902b7-01")
+	handler(ctx)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want 3", len(spans))
+	}
+	byName := make(map[string]tracetest.SpanStub)
+	for _, s := range spans {
+		if s.SpanContext.TraceID().String() != parentTraceId {
+			t.Errorf("span %q trace id = %s, want incoming trace", s.Name, s.SpanContext.TraceID())
+		}
+		byName[s.Name] = s
+	}
+	server, ok := byName["GET /orders/:id"]
+	if !ok || server.SpanKind != trace.SpanKindServer {
+		t.Fatalf("server span missing: %v", byName)
+	}
+	mw := byName["middleware auth"]
+	if mw.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Error("middleware span is not a child of the server span")
+	}
+	client := byName["upstream 10.0.0.1:80"]
+	if client.Parent.SpanID() != mw.SpanContext.SpanID() {
+		t.Error("upstream span is not a child of the middleware span")
+	}
+
+	if got := string(upstreamReq.Header.Peek("traceparent")); got != "00-"+parentTraceId+"-"+client.SpanContext.SpanID().String()+"-01" {
+		t.Errorf("injected traceparent = %q", got)
+	}
+	if got := string(upstreamReq.Header.Peek("X-B3-TraceId")); got != parentTraceId {
+		t.Errorf("injected b3 trace id = %q", got)
+	}
+}
+
+func TestSampleRate(t *testing.T) {
+	zero := 0.0
+	for _, c := range []struct {
+		rate    *float64
+		sampled bool
+	}{
+		{nil, true},
+		{&zero, false},
+	} {
+		exporter := tracetest.NewInMemoryExporter()
+		tp := NewTracerProvider(&static.Tracing{SampleRate: c.rate}, sdktrace.WithSyncer(exporter))
+		_, span := tp.Tracer(instrumentationName).Start(context.Background(), "root")
+		span.End()
+		if got := len(exporter.GetSpans()) == 1; got != c.sampled {
+			t.Errorf("sampleRate %v: sampled = %v, want %v", c.rate, got, c.sampled)
+		}
+		_ = tp.Shutdown(context.Background())
+	}
+}
@@ -63,7 +63,7 @@ func (f *ConfigurationManager) newStaticConfig(filePath string) error {
 		v.OnConfigChange(func(e fsnotify.Event) {
 			log.Log.Debugf("Detach static config change:%s", e.Name)
 			// 重新解析配置
-			if err = v.Unmarshal(f.staticConfig); err != nil {
+			if err = v.Unmarshal(f.staticConfig, utils.ViperDecodeHook); err != nil {
 				log.Log.WithError(err).Errorf("重新解析配置失败: %v", err)
 				return
 			}
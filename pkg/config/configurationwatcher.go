@@ -10,6 +10,7 @@ import (
 	"go-faster-gateway/pkg/provider"
 	"go-faster-gateway/pkg/safe"
 	"reflect"
+	"sync/atomic"
 )
 
 // ConfigurationWatcher watches configuration changes.
@@ -20,7 +21,7 @@ type ConfigurationWatcher struct {
 
 	newConfigs chan dynamic.Configurations
 	// 动态文件
-	dyConfig               *dynamic.Configuration
+	dyConfig               atomic.Pointer[dynamic.Configuration]
 	requiredProvider       string
 	configurationListeners []func(dynamic.Configuration)
 
@@ -58,8 +59,8 @@ func (c *ConfigurationWatcher) Stop() {
 
 // get default config first
 func (c *ConfigurationWatcher) GetConfig() (*dynamic.Configuration, error) {
-	if c.dyConfig != nil {
-		return c.dyConfig, nil
+	if conf := c.dyConfig.Load(); conf != nil {
+		return conf, nil
 	}
 	msg, err := c.providerAggregator.GetConfig()
 	if err != nil {
@@ -176,13 +177,14 @@ func (c *ConfigurationWatcher) applyConfigurations(ctx context.Context) {
 				continue
 			}
 
-			conf, err := utils.DeepCopyMap(nil, newConfigs)
-			if err == nil {
-				for _, listener := range c.configurationListeners {
-					listener(*conf)
-				}
-				c.dyConfig = conf
+			conf, conflicts := dynamic.MergeConfigurations(newConfigs)
+			for _, err := range conflicts {
+				log.Log.WithError(err).Error("merge provider configurations conflict")
+			}
+			for _, listener := range c.configurationListeners {
+				listener(*conf)
 			}
+			c.dyConfig.Store(conf)
 			//这边是最新的动态配置信息
 			lastConfigurations = newConfigs
 			log.Log.Debugf("lastConfiguration is %s", utils.JsonMarshalToStrNoErr(lastConfigurations))
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/config/static"
+	"go-faster-gateway/pkg/provider/aggregator"
+	apiProvider "go-faster-gateway/pkg/provider/api"
+	"go-faster-gateway/pkg/provider/file"
+	"go-faster-gateway/pkg/safe"
+)
+
+func TestFileAndApiProviders(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "dynamic.yml")
+	if err := os.WriteFile(filename, []byte(`
+easyServiceRoute:
+  services:
+    order:
+      order_http:
+        handler: http
+        routers:
+          - path: /order
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	agg := aggregator.NewProviderAggregator(static.Providers{File: &file.Provider{Filename: filename}})
+	api := apiProvider.New(nil)
+	if err := agg.AddProvider(api); err != nil {
+		t.Fatal(err)
+	}
+
+	// api provider没有路由时不影响文件中的路由
+	msg, err := agg.GetConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if services := msg.Configuration.EasyServiceRoute.Services; len(services) != 1 || services["order"] == nil {
+		t.Fatalf("file routes lost when merged with an empty api provider: %+v", services)
+	}
+
+	pool := safe.NewPool(context.Background())
+	defer pool.Stop()
+	watcher := NewConfigurationWatcher(pool, agg, "file")
+	applied := make(chan *dynamic.Configuration, 10)
+	watcher.AddListener(func(conf dynamic.Configuration) { applied <- &conf })
+	watcher.Start()
+	waitServices(t, applied, "order")
+
+	if err = api.CreateRoute("user", "user_http", &dynamic.ServiceRoute{Handler: "http"}); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(t, applied, "order", "user")
+
+	// 同名服务以文件中的定义为准
+	if err = api.CreateRoute("order", "order_admin", &dynamic.ServiceRoute{Handler: "http"}); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err = agg.GetConfig(); err != nil {
+		t.Fatal(err)
+	}
+	order := msg.Configuration.EasyServiceRoute.Services["order"]
+	if len(order) != 1 || order["order_http"] == nil {
+		t.Errorf("conflicting api service should not replace the file service: %+v", order)
+	}
+}
+
+// waitServices 等待应用的配置中正好包含这些服务
+func waitServices(t *testing.T, applied <-chan *dynamic.Configuration, services ...string) {
+	t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case conf := <-applied:
+			got := conf.EasyServiceRoute.Services
+			ok := len(got) == len(services)
+			for _, s := range services {
+				ok = ok && got[s] != nil
+			}
+			if ok {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("services %v were not applied", services)
+		}
+	}
+}
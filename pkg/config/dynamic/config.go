@@ -1,6 +1,9 @@
 package dynamic
 
-import "go-faster-gateway/pkg/database"
+import (
+	"go-faster-gateway/pkg/database"
+	"go-faster-gateway/pkg/helper/parser"
+)
 
 // Message holds configuration information exchanged between parts of gateway
 type Message struct {
@@ -20,6 +23,10 @@ type Configuration struct {
 	BalanceMode BalanceMode `json:"balanceMode" toml:"balanceMode,omitempty" yaml:"balanceMode" `
 	//全局中间件
 	GlobalMiddleware []string `json:"globalMiddleware" toml:"globalMiddleware,omitempty" yaml:"globalMiddleware"`
+	//带配置项的中间件定义, key为中间件名称, 路由和全局中间件通过名称引用
+	Middlewares map[string]*Middleware `json:"middlewares,omitempty" toml:"middlewares,omitempty" yaml:"middlewares,omitempty" export:"true"`
+	//到上游结点的传输配置(tls, mtls), key为名称, 路由通过名称引用
+	ServersTransports map[string]*ServersTransport `json:"serversTransports,omitempty" toml:"serversTransports,omitempty" yaml:"serversTransports,omitempty" export:"true"`
 	//api对应的路由配置
 	EasyServiceRoute *ServiceRouteConfiguration `json:"easyServiceRoute,omitempty" toml:"easyServiceRoute,omitempty" yaml:"easyServiceRoute,omitempty"`
 }
@@ -50,6 +57,38 @@ type ServiceRoute struct {
 	Servers []Server `json:"servers,omitempty" toml:"servers,omitempty" yaml:"servers,omitempty"`
 	//对应的中间件
 	Middlewares []string `json:"middlewares,omitempty" toml:"middlewares,omitempty" yaml:"middlewares,omitempty"`
+	//连接上游结点使用的协议 http/https, 默认http, 结点上配置的scheme优先
+	Scheme string `json:"scheme,omitempty" toml:"scheme,omitempty" yaml:"scheme,omitempty" export:"true"`
+	//引用的serversTransport名称, scheme为https时使用其中的tls配置
+	ServersTransport string `json:"serversTransport,omitempty" toml:"serversTransport,omitempty" yaml:"serversTransport,omitempty" export:"true"`
+	//到上游结点的连接池配置, 同一个服务的多个路由以第一个路由的配置为准
+	Client *UpstreamClient `json:"client,omitempty" toml:"client,omitempty" yaml:"client,omitempty" export:"true"`
+	//流式转发请求体和响应体, 配置后开启, 用于大文件上传下载, SSE和长轮询
+	Streaming *Streaming `json:"streaming,omitempty" toml:"streaming,omitempty" yaml:"streaming,omitempty" export:"true"`
+}
+
+// Streaming 请求体边读边发给上游, 响应体收到一块就写给客户端一块, 都不在网关中缓存.
+// 客户端读得慢时不会继续读取上游. 路由上读取请求体的中间件(hmacAuth, forwardAuth的forwardBody)
+// 仍然会把请求体读到内存中, 超过上限(默认4MB, forwardAuth为maxBodySize)时返回413
+type Streaming struct {
+	//整个上游请求的超时, 包括传输请求体和响应体, 为0时不限制. SSE等长连接一般不配置
+	Timeout parser.Duration `json:"timeout,omitempty" toml:"timeout,omitempty" yaml:"timeout,omitempty" export:"true"`
+}
+
+// UpstreamClient 每个上游结点一个连接池, 结点移除时关闭. 没有配置的项使用fasthttp的默认值
+type UpstreamClient struct {
+	//每个结点的最大连接数
+	MaxConns int `json:"maxConns,omitempty" toml:"maxConns,omitempty" yaml:"maxConns,omitempty" export:"true"`
+	//空闲连接的保持时间
+	MaxIdleConnDuration parser.Duration `json:"maxIdleConnDuration,omitempty" toml:"maxIdleConnDuration,omitempty" yaml:"maxIdleConnDuration,omitempty" export:"true"`
+	//连接数达到上限时等待空闲连接的最长时间, 为0时直接返回错误
+	MaxConnWaitTimeout parser.Duration `json:"maxConnWaitTimeout,omitempty" toml:"maxConnWaitTimeout,omitempty" yaml:"maxConnWaitTimeout,omitempty" export:"true"`
+	//每个连接的读缓冲区大小, 限制响应头的大小
+	ReadBufferSize int `json:"readBufferSize,omitempty" toml:"readBufferSize,omitempty" yaml:"readBufferSize,omitempty" export:"true"`
+	//每个连接的写缓冲区大小
+	WriteBufferSize int `json:"writeBufferSize,omitempty" toml:"writeBufferSize,omitempty" yaml:"writeBufferSize,omitempty" export:"true"`
+	//同时尝试ipv4和ipv6地址
+	DialDualStack bool `json:"dialDualStack,omitempty" toml:"dialDualStack,omitempty" yaml:"dialDualStack,omitempty" export:"true"`
 }
 
 // 代理的路由信息
@@ -84,4 +123,12 @@ type Server struct {
 	Weight int `json:"weight,omitempty" toml:"weight,omitempty" yaml:"weight,omitempty"`
 	//是否健康
 	Healthy bool `json:"healthy,omitempty" toml:"healthy,omitempty" yaml:"healthy,omitempty"`
+	//连接结点使用的协议 http/https, 为空时使用路由的配置
+	Scheme string `json:"scheme,omitempty" toml:"scheme,omitempty" yaml:"scheme,omitempty"`
+}
+
+// ServersTransport 到上游结点的传输配置, 多个路由可以引用同一个配置
+type ServersTransport struct {
+	//ca证书, 客户端证书(mtls), sni和是否跳过证书校验
+	TLS *ClientTLS `json:"tls,omitempty" toml:"tls,omitempty" yaml:"tls,omitempty" export:"true"`
 }
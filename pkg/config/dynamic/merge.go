@@ -0,0 +1,88 @@
+package dynamic
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// filePrecedence 文件是网关的基础配置, 合并时最先处理
+const filePrecedence = "file"
+
+// MergeConfigurations 按服务合并各个provider的动态配置.
+// 服务, 中间件和serversTransport按名称合并, 全局中间件, 负载均衡策略和数据库配置取第一个配置了的provider.
+// provider按名称排序(file最先), 同一个名称在多个provider中定义且内容不同时保留先合并的定义,
+// 返回的冲突列表用于记录日志. configuration为nil的provider(还没有加载到配置)跳过
+func MergeConfigurations(configs Configurations) (*Configuration, []error) {
+	names := make([]string, 0, len(configs))
+	for name, conf := range configs {
+		if conf != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == filePrecedence) != (names[j] == filePrecedence) {
+			return names[i] == filePrecedence
+		}
+		return names[i] < names[j]
+	})
+
+	merged := &Configuration{
+		EasyServiceRoute: &ServiceRouteConfiguration{
+			Services: make(map[string]map[string]*ServiceRoute),
+		},
+	}
+	var conflicts []error
+	// owners 记录每一项配置来自哪个provider
+	owners := make(map[string]string)
+	claim := func(kind, key, provider string, same bool) bool {
+		id := kind + "/" + key
+		owner, ok := owners[id]
+		if !ok {
+			owners[id] = provider
+			return true
+		}
+		if !same {
+			conflicts = append(conflicts, fmt.Errorf("%s %q defined by both %s and %s providers, using %s", kind, key, owner, provider, owner))
+		}
+		return false
+	}
+
+	for _, name := range names {
+		conf := configs[name]
+		if conf.Databases != nil && claim("databases", "", name, reflect.DeepEqual(merged.Databases, conf.Databases)) {
+			merged.Databases = conf.Databases
+		}
+		if conf.BalanceMode != (BalanceMode{}) && claim("balanceMode", "", name, merged.BalanceMode == conf.BalanceMode) {
+			merged.BalanceMode = conf.BalanceMode
+		}
+		if len(conf.GlobalMiddleware) > 0 && claim("globalMiddleware", "", name, reflect.DeepEqual(merged.GlobalMiddleware, conf.GlobalMiddleware)) {
+			merged.GlobalMiddleware = conf.GlobalMiddleware
+		}
+		for key, mw := range conf.Middlewares {
+			if claim("middleware", key, name, reflect.DeepEqual(merged.Middlewares[key], mw)) {
+				if merged.Middlewares == nil {
+					merged.Middlewares = make(map[string]*Middleware)
+				}
+				merged.Middlewares[key] = mw
+			}
+		}
+		for key, st := range conf.ServersTransports {
+			if claim("serversTransport", key, name, reflect.DeepEqual(merged.ServersTransports[key], st)) {
+				if merged.ServersTransports == nil {
+					merged.ServersTransports = make(map[string]*ServersTransport)
+				}
+				merged.ServersTransports[key] = st
+			}
+		}
+		if conf.EasyServiceRoute == nil {
+			continue
+		}
+		for service, routes := range conf.EasyServiceRoute.Services {
+			if claim("service", service, name, reflect.DeepEqual(merged.EasyServiceRoute.Services[service], routes)) {
+				merged.EasyServiceRoute.Services[service] = routes
+			}
+		}
+	}
+	return merged, conflicts
+}
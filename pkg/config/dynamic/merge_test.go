@@ -0,0 +1,44 @@
+package dynamic
+
+import "testing"
+
+func TestMergeConfigurations(t *testing.T) {
+	route := func(handler string) map[string]*ServiceRoute {
+		return map[string]*ServiceRoute{"r": {Handler: handler}}
+	}
+	configs := Configurations{
+		"nacos": {
+			GlobalMiddleware: []string{"auth"},
+			EasyServiceRoute: &ServiceRouteConfiguration{Services: map[string]map[string]*ServiceRoute{
+				"order": route("https"), "pay": route("http"),
+			}},
+		},
+		"consulcatalog": {
+			EasyServiceRoute: &ServiceRouteConfiguration{Services: map[string]map[string]*ServiceRoute{
+				"user": route("http"), "pay": route("http"),
+			}},
+		},
+		"file": {
+			GlobalMiddleware: []string{"requestid"},
+			EasyServiceRoute: &ServiceRouteConfiguration{Services: map[string]map[string]*ServiceRoute{
+				"order": route("http"),
+			}},
+		},
+		// 还没有加载到配置
+		"http": nil,
+	}
+	for i := 0; i < 10; i++ {
+		merged, conflicts := MergeConfigurations(configs)
+		services := merged.EasyServiceRoute.Services
+		if len(services) != 3 || services["order"]["r"].Handler != "http" || services["user"] == nil || services["pay"] == nil {
+			t.Fatalf("unexpected services %+v", services)
+		}
+		if len(merged.GlobalMiddleware) != 1 || merged.GlobalMiddleware[0] != "requestid" {
+			t.Errorf("file global middlewares should win, got %v", merged.GlobalMiddleware)
+		}
+		// 相同的pay定义不算冲突
+		if len(conflicts) != 2 {
+			t.Errorf("expected order and globalMiddleware conflicts, got %v", conflicts)
+		}
+	}
+}
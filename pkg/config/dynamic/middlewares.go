@@ -2,6 +2,7 @@ package dynamic
 
 import (
 	"fmt"
+	"go-faster-gateway/pkg/helper/parser"
 	"go-faster-gateway/pkg/ip"
 )
 
@@ -19,6 +20,11 @@ type Middleware struct {
 	IPWhiteList *IPWhiteList `json:"ipWhiteList,omitempty" toml:"ipWhiteList,omitempty" yaml:"ipWhiteList,omitempty" export:"true"`
 	IPAllowList *IPAllowList `json:"ipAllowList,omitempty" toml:"ipAllowList,omitempty" yaml:"ipAllowList,omitempty" export:"true"`
 	BasicAuth   *BasicAuth   `json:"basicAuth,omitempty" toml:"basicAuth,omitempty" yaml:"basicAuth,omitempty" export:"true"`
+	JwtAuth     *JwtAuth     `json:"jwtAuth,omitempty" toml:"jwtAuth,omitempty" yaml:"jwtAuth,omitempty" export:"true"`
+	ForwardAuth *ForwardAuth `json:"forwardAuth,omitempty" toml:"forwardAuth,omitempty" yaml:"forwardAuth,omitempty" export:"true"`
+	KeyAuth     *KeyAuth     `json:"keyAuth,omitempty" toml:"keyAuth,omitempty" yaml:"keyAuth,omitempty" export:"true"`
+	HmacAuth    *HmacAuth    `json:"hmacAuth,omitempty" toml:"hmacAuth,omitempty" yaml:"hmacAuth,omitempty" export:"true"`
+	RequestId   *RequestId   `json:"requestId,omitempty" toml:"requestId,omitempty" yaml:"requestId,omitempty" export:"true"`
 	Buffering   *Buffering   `json:"buffering,omitempty" toml:"buffering,omitempty" yaml:"buffering,omitempty" export:"true"`
 	// Gateway API filter middlewares.
 	RequestHeaderModifier  *HeaderModifier `json:"requestHeaderModifier,omitempty" toml:"-" yaml:"-" label:"-" file:"-" kv:"-" export:"true"`
@@ -59,6 +65,146 @@ type BasicAuth struct {
 	HeaderField string `json:"headerField,omitempty" toml:"headerField,omitempty" yaml:"headerField,omitempty" export:"true"`
 }
 
+// JwtAuth holds the JWT authentication middleware configuration.
+// This middleware validates a bearer token before forwarding the request,
+// and maps selected claims into upstream request headers.
+type JwtAuth struct {
+	// Algorithms defines the accepted signing algorithms (HS256, RS256, ES256).
+	// Default: all of them.
+	Algorithms []string `json:"algorithms,omitempty" toml:"algorithms,omitempty" yaml:"algorithms,omitempty" export:"true"`
+	// Secret is the shared key used to verify HS256 tokens.
+	Secret string `json:"secret,omitempty" toml:"secret,omitempty" yaml:"secret,omitempty" loggable:"false"`
+	// PublicKey is the PEM encoded public key (or the path to it) used to verify RS256/ES256 tokens.
+	PublicKey string `json:"publicKey,omitempty" toml:"publicKey,omitempty" yaml:"publicKey,omitempty"`
+	// JwksURL is the URL of a JSON Web Key Set used to look up verification keys by their kid.
+	JwksURL string `json:"jwksUrl,omitempty" toml:"jwksUrl,omitempty" yaml:"jwksUrl,omitempty"`
+	// JwksRefreshInterval defines how long a fetched key set is cached before it is refreshed.
+	// Default: 5m.
+	JwksRefreshInterval parser.Duration `json:"jwksRefreshInterval,omitempty" toml:"jwksRefreshInterval,omitempty" yaml:"jwksRefreshInterval,omitempty" export:"true"`
+	// Issuer is the expected value of the iss claim. Not checked when empty.
+	Issuer string `json:"issuer,omitempty" toml:"issuer,omitempty" yaml:"issuer,omitempty" export:"true"`
+	// Audience is the expected value of the aud claim. Not checked when empty.
+	Audience string `json:"audience,omitempty" toml:"audience,omitempty" yaml:"audience,omitempty" export:"true"`
+	// Leeway defines the clock skew tolerated when validating exp and nbf.
+	Leeway parser.Duration `json:"leeway,omitempty" toml:"leeway,omitempty" yaml:"leeway,omitempty" export:"true"`
+	// AllowMissingExp accepts tokens without an exp claim, which never expire.
+	// Default: false (the exp claim is required).
+	AllowMissingExp bool `json:"allowMissingExp,omitempty" toml:"allowMissingExp,omitempty" yaml:"allowMissingExp,omitempty" export:"true"`
+	// TokenHeader is the request header holding the token.
+	// Default: Authorization.
+	TokenHeader string `json:"tokenHeader,omitempty" toml:"tokenHeader,omitempty" yaml:"tokenHeader,omitempty" export:"true"`
+	// TokenQuery is an optional query parameter holding the token, used when the header is absent.
+	TokenQuery string `json:"tokenQuery,omitempty" toml:"tokenQuery,omitempty" yaml:"tokenQuery,omitempty" export:"true"`
+	// ClaimsToHeaders maps upstream header names to the claim whose value they receive, e.g. X-User-Id: sub.
+	ClaimsToHeaders map[string]string `json:"claimsToHeaders,omitempty" toml:"claimsToHeaders,omitempty" yaml:"claimsToHeaders,omitempty" export:"true"`
+	// RemoveHeader removes the token header before forwarding the request.
+	RemoveHeader bool `json:"removeHeader,omitempty" toml:"removeHeader,omitempty" yaml:"removeHeader,omitempty" export:"true"`
+}
+
+// ForwardAuth holds the forward auth middleware configuration.
+// This middleware delegates the request authentication to a Service.
+// More info: https://doc.traefik.io/traefik/v3.3/middlewares/http/forwardauth/
+type ForwardAuth struct {
+	// Address defines the authentication server address.
+	Address string `json:"address,omitempty" toml:"address,omitempty" yaml:"address,omitempty"`
+	// TLS defines the configuration used to secure the connection to the authentication server.
+	TLS *ClientTLS `json:"tls,omitempty" toml:"tls,omitempty" yaml:"tls,omitempty" export:"true"`
+	// TrustForwardHeader defines whether to trust (ie: forward) all X-Forwarded-* headers.
+	TrustForwardHeader bool `json:"trustForwardHeader,omitempty" toml:"trustForwardHeader,omitempty" yaml:"trustForwardHeader,omitempty" export:"true"`
+	// AuthResponseHeaders defines the list of headers to copy from the authentication server response and set on forwarded request, replacing any existing conflicting headers.
+	AuthResponseHeaders []string `json:"authResponseHeaders,omitempty" toml:"authResponseHeaders,omitempty" yaml:"authResponseHeaders,omitempty" export:"true"`
+	// AuthResponseHeadersRegex defines the regex to match headers to copy from the authentication server response and set on forwarded request, after stripping all headers that match the regex.
+	AuthResponseHeadersRegex string `json:"authResponseHeadersRegex,omitempty" toml:"authResponseHeadersRegex,omitempty" yaml:"authResponseHeadersRegex,omitempty" export:"true"`
+	// AuthRequestHeaders defines the list of the headers to copy from the request to the authentication server.
+	// If not set or empty then all request headers are passed.
+	AuthRequestHeaders []string `json:"authRequestHeaders,omitempty" toml:"authRequestHeaders,omitempty" yaml:"authRequestHeaders,omitempty" export:"true"`
+	// ForwardBody defines whether to send the request body to the authentication server.
+	ForwardBody bool `json:"forwardBody,omitempty" toml:"forwardBody,omitempty" yaml:"forwardBody,omitempty" export:"true"`
+	// MaxBodySize defines the maximum body size in bytes allowed to be forwarded to the authentication server.
+	// A negative value falls back to the gateway's buffered body limit (4MB), larger bodies are rejected with 413.
+	// Default: -1 (the buffered body limit).
+	MaxBodySize *int64 `json:"maxBodySize,omitempty" toml:"maxBodySize,omitempty" yaml:"maxBodySize,omitempty" export:"true"`
+	// Timeout defines the time allowed for the authentication server to answer.
+	// Default: 30s.
+	Timeout parser.Duration `json:"timeout,omitempty" toml:"timeout,omitempty" yaml:"timeout,omitempty" export:"true"`
+}
+
+// SetDefaults sets the default values.
+func (f *ForwardAuth) SetDefaults() {
+	defaultMaxBodySize := ForwardAuthDefaultMaxBodySize
+	f.MaxBodySize = &defaultMaxBodySize
+}
+
+// KeyAuth holds the api key authentication middleware configuration.
+// This middleware resolves the consumer owning the api key from the database,
+// and makes its identity available to the following middlewares.
+type KeyAuth struct {
+	// HeaderName is the request header holding the api key.
+	// Default: X-Api-Key.
+	HeaderName string `json:"headerName,omitempty" toml:"headerName,omitempty" yaml:"headerName,omitempty" export:"true"`
+	// QueryParam is an optional query parameter holding the api key, used when the header is absent.
+	QueryParam string `json:"queryParam,omitempty" toml:"queryParam,omitempty" yaml:"queryParam,omitempty" export:"true"`
+	// CacheTTL defines how long a key lookup (found or not) is cached.
+	// Default: 1m.
+	CacheTTL parser.Duration `json:"cacheTTL,omitempty" toml:"cacheTTL,omitempty" yaml:"cacheTTL,omitempty" export:"true"`
+	// CacheSize defines the maximum number of cached key lookups.
+	// Default: 10000.
+	CacheSize int `json:"cacheSize,omitempty" toml:"cacheSize,omitempty" yaml:"cacheSize,omitempty" export:"true"`
+	// ConsumerHeader is the upstream header receiving the consumer name.
+	// Default: X-Consumer-Name.
+	ConsumerHeader string `json:"consumerHeader,omitempty" toml:"consumerHeader,omitempty" yaml:"consumerHeader,omitempty" export:"true"`
+	// RemoveKey removes the api key from the request before forwarding it.
+	RemoveKey bool `json:"removeKey,omitempty" toml:"removeKey,omitempty" yaml:"removeKey,omitempty" export:"true"`
+}
+
+// HmacAuth holds the HMAC request signature middleware configuration.
+// The client signs, with HMAC-SHA256 and the secret of its access key, the string:
+// METHOD \n PATH \n SORTED_QUERY \n HEX(SHA256(BODY)) \n TIMESTAMP \n NONCE
+// and sends the hex or base64 encoded signature in the signature header.
+type HmacAuth struct {
+	// Credentials defines the access keys and their secrets.
+	Credentials []HmacCredential `json:"credentials,omitempty" toml:"credentials,omitempty" yaml:"credentials,omitempty" loggable:"false"`
+	// UseDatabase looks up the access keys not found in Credentials from the hmac_credential table.
+	UseDatabase bool `json:"useDatabase,omitempty" toml:"useDatabase,omitempty" yaml:"useDatabase,omitempty" export:"true"`
+	// AccessKeyHeader is the request header holding the access key.
+	// Default: X-Access-Key.
+	AccessKeyHeader string `json:"accessKeyHeader,omitempty" toml:"accessKeyHeader,omitempty" yaml:"accessKeyHeader,omitempty" export:"true"`
+	// SignatureHeader is the request header holding the signature.
+	// Default: X-Signature.
+	SignatureHeader string `json:"signatureHeader,omitempty" toml:"signatureHeader,omitempty" yaml:"signatureHeader,omitempty" export:"true"`
+	// TimestampHeader is the request header holding the unix timestamp (seconds) of the signature.
+	// Default: X-Timestamp.
+	TimestampHeader string `json:"timestampHeader,omitempty" toml:"timestampHeader,omitempty" yaml:"timestampHeader,omitempty" export:"true"`
+	// NonceHeader is the request header holding the single-use nonce.
+	// Default: X-Nonce.
+	NonceHeader string `json:"nonceHeader,omitempty" toml:"nonceHeader,omitempty" yaml:"nonceHeader,omitempty" export:"true"`
+	// ClockSkew defines the maximum allowed difference between the timestamp and the gateway clock.
+	// Default: 5m.
+	ClockSkew parser.Duration `json:"clockSkew,omitempty" toml:"clockSkew,omitempty" yaml:"clockSkew,omitempty" export:"true"`
+	// NonceCacheSize defines the maximum number of remembered nonces.
+	// Nonces are kept for twice the ClockSkew, requests are rejected with 429 while the cache is full.
+	// Default: 100000.
+	NonceCacheSize int `json:"nonceCacheSize,omitempty" toml:"nonceCacheSize,omitempty" yaml:"nonceCacheSize,omitempty" export:"true"`
+}
+
+// HmacCredential holds an access key and its signing secret.
+type HmacCredential struct {
+	AccessKey string `json:"accessKey,omitempty" toml:"accessKey,omitempty" yaml:"accessKey,omitempty"`
+	Secret    string `json:"secret,omitempty" toml:"secret,omitempty" yaml:"secret,omitempty" loggable:"false"`
+}
+
+// RequestId holds the request id middleware configuration.
+type RequestId struct {
+	// HeaderName is the header used to read and propagate the request id.
+	// Default: X-Request-Id.
+	HeaderName string `json:"headerName,omitempty" toml:"headerName,omitempty" yaml:"headerName,omitempty" export:"true"`
+	// Generator defines how new request ids are generated: uuid or sid (sonyflake).
+	// Default: uuid.
+	Generator string `json:"generator,omitempty" toml:"generator,omitempty" yaml:"generator,omitempty" export:"true"`
+	// IgnoreIncoming always generates a new id instead of reusing the incoming header.
+	IgnoreIncoming bool `json:"ignoreIncoming,omitempty" toml:"ignoreIncoming,omitempty" yaml:"ignoreIncoming,omitempty" export:"true"`
+}
+
 // Buffering holds the buffering middleware configuration.
 // This middleware retries or limits the size of requests that can be forwarded to backends.
 // More info: https://doc.traefik.io/traefik/v3.3/middlewares/http/buffering/#maxrequestbodybytes
@@ -0,0 +1,77 @@
+package dynamic
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ClientTLS holds the TLS specific configurations as client.
+// CA, Cert and Key can be either file paths or the PEM contents themselves.
+type ClientTLS struct {
+	// CA is the certificate authority bundle used to verify the server certificate.
+	CA string `json:"ca,omitempty" toml:"ca,omitempty" yaml:"ca,omitempty"`
+	// Cert is the client certificate presented for mutual TLS.
+	Cert string `json:"cert,omitempty" toml:"cert,omitempty" yaml:"cert,omitempty"`
+	// Key is the private key of the client certificate.
+	Key string `json:"key,omitempty" toml:"key,omitempty" yaml:"key,omitempty" loggable:"false"`
+	// ServerName overrides the server name used for SNI and certificate verification.
+	ServerName string `json:"serverName,omitempty" toml:"serverName,omitempty" yaml:"serverName,omitempty" export:"true"`
+	// InsecureSkipVerify disables the server certificate verification. Only meant for development.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty" toml:"insecureSkipVerify,omitempty" yaml:"insecureSkipVerify,omitempty" export:"true"`
+}
+
+// CreateTLSConfig creates a TLS config from ClientTLS structures.
+func (c *ClientTLS) CreateTLSConfig() (*tls.Config, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	conf := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CA != "" {
+		ca, err := readFileOrContent(c.CA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("failed to parse CA")
+		}
+		conf.RootCAs = pool
+	}
+
+	if c.Cert != "" || c.Key != "" {
+		if c.Cert == "" || c.Key == "" {
+			return nil, errors.New("both cert and key are required for client certificate")
+		}
+		cert, err := readFileOrContent(c.Cert)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cert: %w", err)
+		}
+		key, err := readFileOrContent(c.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key: %w", err)
+		}
+		keyPair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS keypair: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{keyPair}
+	}
+
+	return conf, nil
+}
+
+func readFileOrContent(value string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return []byte(value), nil
+	}
+	return os.ReadFile(value)
+}
@@ -0,0 +1,57 @@
+package static
+
+import (
+	"go-faster-gateway/pkg/helper/parser"
+	"go-faster-gateway/pkg/log/logger"
+)
+
+const (
+	// CommonFormat is the common log format (CLF).
+	CommonFormat = "common"
+	// JSONFormat is the JSON logging format.
+	JSONFormat = "json"
+)
+
+const (
+	// AccessLogKeep is the keep string value.
+	AccessLogKeep = "keep"
+	// AccessLogDrop is the drop string value.
+	AccessLogDrop = "drop"
+	// AccessLogRedact is the redact string value.
+	AccessLogRedact = "redact"
+)
+
+// AccessLog holds the configuration settings for the access logger (middlewares currently).
+type AccessLog struct {
+	// Format is the access log format: json or common. Default: common.
+	Format string `description:"Access log format: json | common" json:"format,omitempty" toml:"format,omitempty" yaml:"format,omitempty" export:"true"`
+	// File writes the access log to a separate rotating file, stdout is used when empty.
+	File *logger.File `description:"Access log file settings." json:"file,omitempty" toml:"file,omitempty" yaml:"file,omitempty" export:"true"`
+	// Filters limits the requests that are logged.
+	Filters *AccessLogFilters `description:"Access log filters, used to keep only specific access logs." json:"filters,omitempty" toml:"filters,omitempty" yaml:"filters,omitempty" export:"true"`
+	// Fields configures the logged request headers.
+	Fields *AccessLogFields `description:"AccessLogFields." json:"fields,omitempty" toml:"fields,omitempty" yaml:"fields,omitempty" export:"true"`
+}
+
+// AccessLogFilters holds filters configuration.
+type AccessLogFilters struct {
+	// StatusCodes keeps only the access logs with status codes in the specified ranges, e.g. 200, 400-499.
+	StatusCodes []string `description:"Keep access logs with status codes in the specified range." json:"statusCodes,omitempty" toml:"statusCodes,omitempty" yaml:"statusCodes,omitempty" export:"true"`
+	// MinDuration keeps only the access logs of requests taking longer than the specified duration.
+	MinDuration parser.Duration `description:"Keep access logs when request took longer than the specified duration." json:"minDuration,omitempty" toml:"minDuration,omitempty" yaml:"minDuration,omitempty" export:"true"`
+	// SampleRate keeps only a fraction (0-1] of the access logs matching the other filters. Default: 1.
+	SampleRate float64 `description:"Fraction of the access logs to keep." json:"sampleRate,omitempty" toml:"sampleRate,omitempty" yaml:"sampleRate,omitempty" export:"true"`
+}
+
+// AccessLogFields holds configuration for access log fields.
+type AccessLogFields struct {
+	Headers *FieldHeaders `description:"Headers to keep, drop or redact." json:"headers,omitempty" toml:"headers,omitempty" yaml:"headers,omitempty" export:"true"`
+}
+
+// FieldHeaders holds configuration for access log headers.
+type FieldHeaders struct {
+	// DefaultMode applies to the headers not listed in Names: keep, drop or redact. Default: drop.
+	DefaultMode string `description:"Default mode for fields: keep | drop | redact" json:"defaultMode,omitempty" toml:"defaultMode,omitempty" yaml:"defaultMode,omitempty" export:"true"`
+	// Names overrides the mode per header name.
+	Names map[string]string `description:"Override mode for headers" json:"names,omitempty" toml:"names,omitempty" yaml:"names,omitempty" export:"true"`
+}
@@ -0,0 +1,26 @@
+package static
+
+// API holds the admin API configuration.
+type API struct {
+	// EntryPoint is the dedicated entry point serving the admin API, e.g. 127.0.0.1:9000.
+	EntryPoint string `description:"Dedicated entry point address for the admin API." json:"entryPoint,omitempty" toml:"entryPoint,omitempty" yaml:"entryPoint,omitempty" export:"true"`
+	// Token is the bearer token required by every admin API request.
+	Token string `description:"Bearer token protecting the admin API." json:"token,omitempty" toml:"token,omitempty" yaml:"token,omitempty" loggable:"false"`
+	// Persistence persists the routes managed through the admin API, they only live in memory when it is not set.
+	Persistence *APIPersistence `description:"Persist the routes managed through the admin API." json:"persistence,omitempty" toml:"persistence,omitempty" yaml:"persistence,omitempty" export:"true"`
+}
+
+// APIPersistence holds where the routes managed through the admin API are persisted.
+type APIPersistence struct {
+	// File is a json or yaml file, the format is the same as the easyServiceRoute of the file provider.
+	File string `description:"Persist the routes to a json or yaml file." json:"file,omitempty" toml:"file,omitempty" yaml:"file,omitempty" export:"true"`
+	// Database persists the routes to the provider_snapshot table of the configured database.
+	Database bool `description:"Persist the routes to the database." json:"database,omitempty" toml:"database,omitempty" yaml:"database,omitempty" export:"true"`
+}
+
+// SetDefaults sets the default values.
+func (a *API) SetDefaults() {
+	if a.EntryPoint == "" {
+		a.EntryPoint = "127.0.0.1:9000"
+	}
+}
@@ -0,0 +1,19 @@
+package static
+
+import (
+	"go-faster-gateway/pkg/helper/parser"
+	"time"
+)
+
+// DatabaseProvider holds the configuration of the provider loading routes from the api table.
+type DatabaseProvider struct {
+	// PollInterval is how often the revision of the api table is checked.
+	PollInterval parser.Duration `description:"Interval between two revision checks of the route table." json:"pollInterval,omitempty" toml:"pollInterval,omitempty" yaml:"pollInterval,omitempty" export:"true"`
+}
+
+// SetDefaults sets the default values.
+func (d *DatabaseProvider) SetDefaults() {
+	if d.PollInterval <= 0 {
+		d.PollInterval = parser.Duration(10 * time.Second)
+	}
+}
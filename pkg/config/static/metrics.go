@@ -0,0 +1,47 @@
+package static
+
+const (
+	// MetricsLabelRoute is the matched route path label.
+	MetricsLabelRoute = "route"
+	// MetricsLabelService is the service label.
+	MetricsLabelService = "service"
+	// MetricsLabelMethod is the request method label.
+	MetricsLabelMethod = "method"
+	// MetricsLabelCode is the response status code label.
+	MetricsLabelCode = "code"
+)
+
+// Metrics provides options to expose and send gateway metrics to different third party monitoring systems.
+type Metrics struct {
+	Prometheus *Prometheus `description:"Prometheus metrics exporter type." json:"prometheus,omitempty" toml:"prometheus,omitempty" yaml:"prometheus,omitempty" label:"allowEmpty" file:"allowEmpty" export:"true"`
+}
+
+// Prometheus can contain specific configuration used by the Prometheus Metrics exporter.
+type Prometheus struct {
+	// EntryPoint is the dedicated entry point serving the metrics, e.g. 127.0.0.1:9100.
+	EntryPoint string `description:"Dedicated entry point address for the metrics endpoint." json:"entryPoint,omitempty" toml:"entryPoint,omitempty" yaml:"entryPoint,omitempty" export:"true"`
+	// Path is the path of the metrics endpoint. Default: /metrics.
+	Path string `description:"Metrics endpoint path." json:"path,omitempty" toml:"path,omitempty" yaml:"path,omitempty" export:"true"`
+	// Buckets defines the latency histogram buckets in seconds.
+	Buckets []float64 `description:"Buckets for latency metrics." json:"buckets,omitempty" toml:"buckets,omitempty" yaml:"buckets,omitempty" export:"true"`
+	// Labels defines the request metrics labels among route, service, method and code. Default: all.
+	Labels []string `description:"Labels of the request metrics, used to control cardinality." json:"labels,omitempty" toml:"labels,omitempty" yaml:"labels,omitempty" export:"true"`
+	// DisableNodeLabel removes the node label from the upstream metrics.
+	DisableNodeLabel bool `description:"Remove the node label from the upstream metrics." json:"disableNodeLabel,omitempty" toml:"disableNodeLabel,omitempty" yaml:"disableNodeLabel,omitempty" export:"true"`
+}
+
+// SetDefaults sets the default values.
+func (p *Prometheus) SetDefaults() {
+	if p.EntryPoint == "" {
+		p.EntryPoint = "127.0.0.1:9100"
+	}
+	if p.Path == "" {
+		p.Path = "/metrics"
+	}
+	if len(p.Buckets) == 0 {
+		p.Buckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	}
+	if len(p.Labels) == 0 {
+		p.Labels = []string{MetricsLabelRoute, MetricsLabelService, MetricsLabelMethod, MetricsLabelCode}
+	}
+}
@@ -3,7 +3,13 @@ package static
 import (
 	"go-faster-gateway/pkg/helper/parser"
 	"go-faster-gateway/pkg/log"
+	"go-faster-gateway/pkg/provider/consulcatalog"
+	"go-faster-gateway/pkg/provider/docker"
+	"go-faster-gateway/pkg/provider/etcd"
 	"go-faster-gateway/pkg/provider/file"
+	"go-faster-gateway/pkg/provider/http"
+	"go-faster-gateway/pkg/provider/kubernetes"
+	"go-faster-gateway/pkg/provider/nacos"
 	"strings"
 )
 
@@ -15,14 +21,50 @@ type Configuration struct {
 	Providers *Providers `description:"Providers configuration." json:"providers,omitempty" toml:"providers,omitempty" yaml:"providers,omitempty" export:"true"`
 	//日志
 	Logger *log.Logger `description:"gateway log settings." json:"log,omitempty" toml:"logger,omitempty" yaml:"logger,omitempty" label:"allowEmpty" file:"allowEmpty" export:"true"`
+	//访问日志
+	AccessLog *AccessLog `description:"Access log settings." json:"accessLog,omitempty" toml:"accessLog,omitempty" yaml:"accessLog,omitempty" label:"allowEmpty" file:"allowEmpty" export:"true"`
+	//监控指标
+	Metrics *Metrics `description:"Enable a metrics exporter." json:"metrics,omitempty" toml:"metrics,omitempty" yaml:"metrics,omitempty" export:"true"`
+	//链路追踪
+	Tracing *Tracing `description:"OpenTelemetry tracing settings." json:"tracing,omitempty" toml:"tracing,omitempty" yaml:"tracing,omitempty" export:"true"`
+	//管理接口
+	API *API `description:"Enable the admin API." json:"api,omitempty" toml:"api,omitempty" yaml:"api,omitempty" export:"true"`
 }
 
 // Providers contains providers configuration.
 type Providers struct {
 	//刷新频率
 	ProvidersThrottleDuration parser.Duration `description:"Backends throttle duration: minimum duration between 2 events from providers before applying a new configuration. It avoids unnecessary reloads if multiples events are sent in a short amount of time." json:"providersThrottleDuration,omitempty" toml:"providersThrottleDuration,omitempty" yaml:"providersThrottleDuration,omitempty" export:"true"`
+	//应用动态配置前需要等待其第一份配置的provider名称(file, http, database, consulcatalog, etcd, nacos, kubernetes, docker, api), 见RequiredProviderName
+	RequiredProvider string `description:"Wait for the first configuration of this provider before applying any configuration. Defaults to file when the file provider is enabled." json:"requiredProvider,omitempty" toml:"requiredProvider,omitempty" yaml:"requiredProvider,omitempty" export:"true"`
 
 	File *file.Provider `description:"Enable File backend with default settings." json:"file,omitempty" toml:"file,omitempty" yaml:"file,omitempty" export:"true"`
+	//定时从http接口拉取配置
+	HTTP *http.Provider `description:"Enable HTTP backend with default settings." json:"http,omitempty" toml:"http,omitempty" yaml:"http,omitempty" export:"true"`
+	//从数据库api表加载路由
+	Database *DatabaseProvider `description:"Enable Database backend loading routes from the api table." json:"database,omitempty" toml:"database,omitempty" yaml:"database,omitempty" export:"true"`
+	//consul服务发现
+	ConsulCatalog *consulcatalog.Provider `description:"Enable ConsulCatalog backend with default settings." json:"consulCatalog,omitempty" toml:"consulCatalog,omitempty" yaml:"consulCatalog,omitempty" export:"true"`
+	//从etcd的键值中加载配置
+	Etcd *etcd.Provider `description:"Enable Etcd backend with default settings." json:"etcd,omitempty" toml:"etcd,omitempty" yaml:"etcd,omitempty" export:"true"`
+	//nacos服务发现和配置中心
+	Nacos *nacos.Provider `description:"Enable Nacos backend with default settings." json:"nacos,omitempty" toml:"nacos,omitempty" yaml:"nacos,omitempty" export:"true"`
+	//kubernetes的Ingress和Gateway API HTTPRoute
+	Kubernetes *kubernetes.Provider `description:"Enable Kubernetes Ingress and Gateway API backend with default settings." json:"kubernetes,omitempty" toml:"kubernetes,omitempty" yaml:"kubernetes,omitempty" export:"true"`
+	//从docker容器的标签中加载路由
+	Docker *docker.Provider `description:"Enable Docker backend with default settings." json:"docker,omitempty" toml:"docker,omitempty" yaml:"docker,omitempty" export:"true"`
+}
+
+// RequiredProviderName 应用动态配置前需要等待的provider. 没有配置requiredProvider时,
+// 启用了file provider就等待file, 否则不等待, 任意provider的配置到达后就开始应用
+func (p *Providers) RequiredProviderName() string {
+	if p.RequiredProvider != "" {
+		return p.RequiredProvider
+	}
+	if p.File != nil {
+		return "file"
+	}
+	return ""
 }
 
 // ValidateConfiguration validate that configuration is coherent.
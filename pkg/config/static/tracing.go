@@ -0,0 +1,52 @@
+package static
+
+import (
+	"go-faster-gateway/pkg/helper/parser"
+	"math"
+)
+
+// Tracing holds the tracing configuration.
+type Tracing struct {
+	// ServiceName is the service name reported in the spans. Default: go-faster-gateway.
+	ServiceName string `description:"Set the name for this service." json:"serviceName,omitempty" toml:"serviceName,omitempty" yaml:"serviceName,omitempty" export:"true"`
+	// SampleRate is the ratio [0-1] of the new traces that are sampled, the parent decision is respected. 0 disables sampling of new traces. Default: 1.
+	SampleRate *float64 `description:"Sets the rate between 0.0 and 1.0 of requests to trace." json:"sampleRate,omitempty" toml:"sampleRate,omitempty" yaml:"sampleRate,omitempty" export:"true"`
+	// B3 also extracts and injects the B3 headers in addition to W3C trace context and baggage.
+	B3 bool `description:"Enable B3 propagation." json:"b3,omitempty" toml:"b3,omitempty" yaml:"b3,omitempty" export:"true"`
+	// OTLP configures the OTLP/HTTP exporter.
+	OTLP *OTLPHTTP `description:"Settings for OpenTelemetry over HTTP." json:"otlp,omitempty" toml:"otlp,omitempty" yaml:"otlp,omitempty" export:"true"`
+}
+
+// OTLPHTTP holds the OTLP/HTTP exporter configuration.
+type OTLPHTTP struct {
+	// Endpoint is the full URL the spans are sent to. Default: http://localhost:4318/v1/traces.
+	Endpoint string `description:"Sets the HTTP endpoint (scheme://host:port/path) of the collector." json:"endpoint,omitempty" toml:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	// Headers are added to the export requests.
+	Headers map[string]string `description:"Headers sent with the payload." json:"headers,omitempty" toml:"headers,omitempty" yaml:"headers,omitempty" loggable:"false"`
+	// BatchTimeout is the maximum delay before a batch is exported. Default: 5s.
+	BatchTimeout parser.Duration `description:"Maximum delay before exporting a batch." json:"batchTimeout,omitempty" toml:"batchTimeout,omitempty" yaml:"batchTimeout,omitempty" export:"true"`
+	// MaxExportBatchSize is the maximum number of spans per batch. Default: 512.
+	MaxExportBatchSize int `description:"Maximum number of spans per batch." json:"maxExportBatchSize,omitempty" toml:"maxExportBatchSize,omitempty" yaml:"maxExportBatchSize,omitempty" export:"true"`
+	// MaxQueueSize is the maximum number of buffered spans, spans are dropped when the queue is full. Default: 2048.
+	MaxQueueSize int `description:"Maximum number of buffered spans." json:"maxQueueSize,omitempty" toml:"maxQueueSize,omitempty" yaml:"maxQueueSize,omitempty" export:"true"`
+}
+
+// SetDefaults sets the default values.
+func (t *Tracing) SetDefaults() {
+	if t.ServiceName == "" {
+		t.ServiceName = "go-faster-gateway"
+	}
+	if t.SampleRate == nil {
+		rate := 1.0
+		t.SampleRate = &rate
+	} else if *t.SampleRate < 0 || *t.SampleRate > 1 {
+		rate := math.Min(math.Max(*t.SampleRate, 0), 1)
+		t.SampleRate = &rate
+	}
+	if t.OTLP == nil {
+		t.OTLP = &OTLPHTTP{}
+	}
+	if t.OTLP.Endpoint == "" {
+		t.OTLP.Endpoint = "http://localhost:4318/v1/traces"
+	}
+}
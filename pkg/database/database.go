@@ -2,13 +2,14 @@ package database
 
 type Database struct {
 	DbAlisName      string // 数据库昵称如果有多库则有用
-	Driver          string
-	Source          string
+	Driver          string // mysql, postgres, sqlite
+	Source          string // dsn, sqlite为数据库文件路径
 	ConnMaxIdleTime int
 	ConnMaxLifeTime int
 	MaxIdleCons     int
 	MaxOpenCons     int
 	Registers       []DBResolverConfig
+	AutoMigrate     bool // 启动时自动建表/迁移网关用到的表
 }
 
 type DBResolverConfig struct {
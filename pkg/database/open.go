@@ -1,10 +1,15 @@
 package database
 
 import (
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+// Opens 支持的数据库驱动, sqlite使用纯go实现, 不依赖cgo, source为数据库文件路径
 var Opens = map[string]func(string) gorm.Dialector{
-	"mysql": mysql.Open,
+	"mysql":    mysql.Open,
+	"postgres": postgres.Open,
+	"sqlite":   sqlite.Open,
 }
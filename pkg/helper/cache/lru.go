@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrFull 缓存已满并且没有过期的数据可以清理
+var ErrFull = errors.New("cache is full")
+
+// LRU 带过期时间和容量上限的本地缓存, 超出容量时淘汰最久未使用的数据
+type LRU[K comparable, V any] struct {
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[K]*list.Element
+	mu       sync.Mutex
+	now      func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key      K
+	value    V
+	expireAt time.Time
+}
+
+// NewLRU capacity<=0表示不限制容量, ttl<=0表示不过期
+func NewLRU[K comparable, V any](capacity int, ttl time.Duration) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[K]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// Get 获取未过期的数据
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var zero V
+	el, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	e := el.Value.(*entry[K, V])
+	if c.expired(e) {
+		c.removeElement(el)
+		return zero, false
+	}
+	c.ll.MoveToFront(el)
+	return e.value, true
+}
+
+// Set 写入数据, 已存在时覆盖并刷新过期时间
+func (c *LRU[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(key, value)
+}
+
+// SetIfAbsent 仅当key不存在(或已过期)时写入, 返回是否写入成功
+func (c *LRU[K, V]) SetIfAbsent(key K, value V) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok && !c.expired(el.Value.(*entry[K, V])) {
+		return false
+	}
+	c.set(key, value)
+	return true
+}
+
+// SetIfAbsentNoEvict 和SetIfAbsent相同, 但不淘汰未过期的数据. 缓存已满时从最久未使用的一端清理过期数据,
+// 仍然没有空间时返回ErrFull. 用于防重放等淘汰数据后会放过请求的场景, 数据写入后不再读取时最久未使用的数据最先过期
+func (c *LRU[K, V]) SetIfAbsentNoEvict(key K, value V) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		if !c.expired(el.Value.(*entry[K, V])) {
+			return false, nil
+		}
+		c.set(key, value)
+		return true, nil
+	}
+	if c.capacity > 0 {
+		for c.ll.Len() >= c.capacity {
+			back := c.ll.Back()
+			if !c.expired(back.Value.(*entry[K, V])) {
+				return false, ErrFull
+			}
+			c.removeElement(back)
+		}
+	}
+	c.set(key, value)
+	return true, nil
+}
+
+// Delete 删除数据
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+// Len 当前缓存数量(包含尚未清理的过期数据)
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+func (c *LRU[K, V]) set(key K, value V) {
+	var expireAt time.Time
+	if c.ttl > 0 {
+		expireAt = c.now().Add(c.ttl)
+	}
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value = value
+		e.expireAt = expireAt
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.ll.PushFront(&entry[K, V]{key: key, value: value, expireAt: expireAt})
+	if c.capacity > 0 && c.ll.Len() > c.capacity {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *LRU[K, V]) expired(e *entry[K, V]) bool {
+	return !e.expireAt.IsZero() && c.now().After(e.expireAt)
+}
+
+func (c *LRU[K, V]) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry[K, V]).key)
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	c := NewLRU[string, int](2, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	// b 最久未使用, 写入c时被淘汰
+	c.Set("c", 3)
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if v, ok := c.Get("c"); !ok || v != 3 {
+		t.Errorf("Get(c) = %d, %v", v, ok)
+	}
+
+	if c.SetIfAbsent("c", 4) {
+		t.Error("SetIfAbsent should not overwrite a live entry")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected a to be expired")
+	}
+	if !c.SetIfAbsent("c", 5) {
+		t.Error("SetIfAbsent should overwrite an expired entry")
+	}
+}
+
+func TestSetIfAbsentNoEvict(t *testing.T) {
+	c := NewLRU[string, struct{}](2, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b"} {
+		if ok, err := c.SetIfAbsentNoEvict(key, struct{}{}); !ok || err != nil {
+			t.Fatalf("SetIfAbsentNoEvict(%s) = %v, %v", key, ok, err)
+		}
+	}
+	if ok, err := c.SetIfAbsentNoEvict("a", struct{}{}); ok || err != nil {
+		t.Errorf("SetIfAbsentNoEvict should not overwrite a live entry, got %v, %v", ok, err)
+	}
+	// 缓存中都是未过期的数据时拒绝写入, 不淘汰
+	if ok, err := c.SetIfAbsentNoEvict("c", struct{}{}); ok || err != ErrFull {
+		t.Errorf("expected ErrFull, got %v, %v", ok, err)
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("live entry should not be evicted")
+	}
+
+	// 过期的数据被清理后可以写入
+	now = now.Add(2 * time.Minute)
+	if ok, err := c.SetIfAbsentNoEvict("c", struct{}{}); !ok || err != nil {
+		t.Errorf("SetIfAbsentNoEvict after expiry = %v, %v", ok, err)
+	}
+}
@@ -32,20 +32,3 @@ func DeepCopyDefault[T any](dest *T, src *T) *T {
 	}
 	return dest
 }
-
-func DeepCopyMap[T any, R string](dest *T, src map[R]*T) (*T, error) {
-	if dest == nil {
-		dest = new(T)
-	}
-	var err error
-	for _, configuration := range src {
-		err = copier.CopyWithOption(dest, configuration, copier.Option{
-			IgnoreEmpty: true,
-		})
-		if err != nil {
-			log.Log.WithError(err).Error("DeepCopyMap fail")
-			return dest, err
-		}
-	}
-	return dest, err
-}
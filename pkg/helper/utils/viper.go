@@ -1,11 +1,23 @@
 package utils
 
 import (
-	"github.com/spf13/viper"
 	"path"
 	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
 )
 
+// DecodeHook 在viper默认hook的基础上支持实现了encoding.TextUnmarshaler的类型(如parser.Duration)
+var DecodeHook = mapstructure.ComposeDecodeHookFunc(
+	mapstructure.StringToTimeDurationHookFunc(),
+	mapstructure.StringToSliceHookFunc(","),
+	mapstructure.TextUnmarshallerHookFunc(),
+)
+
+// ViperDecodeHook viper.Unmarshal使用的DecodeHook
+var ViperDecodeHook = viper.DecodeHook(DecodeHook)
+
 func GetFile(filename string, element interface{}) error {
 	var err error
 	viper.AddConfigPath(path.Dir(filename))
@@ -16,7 +28,7 @@ func GetFile(filename string, element interface{}) error {
 	if err = viper.ReadInConfig(); err != nil {
 		return err
 	}
-	if err = viper.Unmarshal(element); err != nil {
+	if err = viper.Unmarshal(element, ViperDecodeHook); err != nil {
 		return err
 	}
 	return err
@@ -32,7 +44,7 @@ func GetViperFile(filename string, element interface{}) (*viper.Viper, error) {
 	if err = v.ReadInConfig(); err != nil {
 		return v, err
 	}
-	if err = v.Unmarshal(element); err != nil {
+	if err = v.Unmarshal(element, ViperDecodeHook); err != nil {
 		return v, err
 	}
 	return v, err
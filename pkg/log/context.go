@@ -0,0 +1,20 @@
+package log
+
+import (
+	"context"
+	"go-faster-gateway/pkg/log/logger"
+)
+
+// RequestIdKey 请求id在请求上下文(fasthttp.RequestCtx UserValue)中的key
+const RequestIdKey = "gateway.requestId"
+
+// WithContext 返回带有当前请求上下文信息(请求id)的日志helper, 同一个请求的日志可以通过请求id串起来
+func WithContext(ctx context.Context) *logger.Helper {
+	if ctx == nil {
+		return Log
+	}
+	if id, ok := ctx.Value(RequestIdKey).(string); ok && id != "" {
+		return Log.WithFields(map[string]interface{}{RequestID: id})
+	}
+	return Log
+}
@@ -13,7 +13,7 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-var Log *logger.Helper
+var Log = logger.NewHelper(logger.DefaultLogger)
 
 type Logger struct {
 	Driver  string            `json:"driver"`
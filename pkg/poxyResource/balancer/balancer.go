@@ -13,9 +13,11 @@ var (
 type Balancer interface {
 	Add(node *Node)
 	Remove(string)
+	Set(nodes []*Node)
 	Balance(string) (*Node, error)
 	Inc(string)
 	Done(string)
+	Nodes() []*Node
 }
 
 // Factory is the factory that generates Balancer,
@@ -24,8 +26,12 @@ type Factory func([]*Node) Balancer
 
 var factories = make(map[string]Factory)
 
-// Build generates the corresponding Balancer according to the algorithm
+// Build generates the corresponding Balancer according to the algorithm,
+// DefaultBalancer is used when the algorithm is empty
 func Build(algorithm string, hosts []*Node) (Balancer, error) {
+	if algorithm == "" {
+		algorithm = DefaultBalancer
+	}
 	factory, ok := factories[algorithm]
 	if !ok {
 		return nil, AlgorithmNotSupportedError
@@ -2,44 +2,109 @@ package balancer
 
 import (
 	"sync"
+	"sync/atomic"
 )
 
+// BaseBalancer 结点列表整体替换(copy-on-write), Balance读取时不加锁
 type BaseBalancer struct {
-	sync.RWMutex
-	hosts []*Node
+	mu    sync.Mutex // 串行化结点的变更
+	hosts atomic.Pointer[[]*Node]
+}
+
+// load returns the current hosts, the slice must not be modified
+func (b *BaseBalancer) load() []*Node {
+	if hosts := b.hosts.Load(); hosts != nil {
+		return *hosts
+	}
+	return nil
+}
+
+func (b *BaseBalancer) store(hosts []*Node) {
+	b.hosts.Store(&hosts)
 }
 
 // Add new host to the balancer
 func (b *BaseBalancer) Add(host *Node) {
-	b.Lock()
-	defer b.Unlock()
-	for _, h := range b.hosts {
-		if h.Service == host.Service {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	hosts := b.load()
+	key := host.Key()
+	for _, h := range hosts {
+		if h.Key() == key {
 			return
 		}
 	}
-	b.hosts = append(b.hosts, host)
+	b.store(append(append([]*Node(nil), hosts...), host))
 }
 
-// Remove new host from the balancer
-func (b *BaseBalancer) Remove(host string) {
-	b.Lock()
-	defer b.Unlock()
-	for i, h := range b.hosts {
-		if h.Service == host {
-			b.hosts = append(b.hosts[:i], b.hosts[i+1:]...)
+// Remove host from the balancer, key is the Node.Key of the host
+func (b *BaseBalancer) Remove(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	hosts := b.load()
+	for i, h := range hosts {
+		if h.Key() == key {
+			b.store(append(append([]*Node(nil), hosts[:i]...), hosts[i+1:]...))
 			return
 		}
 	}
 }
 
+// Set replaces all hosts of the balancer, see MergeNodes
+func (b *BaseBalancer) Set(hosts []*Node) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.store(MergeNodes(b.load(), hosts))
+}
+
 // Balance selects a suitable host according
 func (b *BaseBalancer) Balance(key string) (string, error) {
 	return "", nil
 }
 
-// Inc .
-func (b *BaseBalancer) Inc(_ string) {}
+// Inc increases the in-flight requests of the host, key is the Node.Key of the host
+func (b *BaseBalancer) Inc(key string) {
+	for _, h := range b.load() {
+		if h.Key() == key {
+			h.inflight.Add(1)
+			return
+		}
+	}
+}
 
-// Done .
-func (b *BaseBalancer) Done(_ string) {}
+// Done decreases the in-flight requests of the host, key is the Node.Key of the host
+func (b *BaseBalancer) Done(key string) {
+	for _, h := range b.load() {
+		if h.Key() == key {
+			h.inflight.Add(-1)
+			return
+		}
+	}
+}
+
+// Nodes returns a snapshot of the hosts in the balancer
+func (b *BaseBalancer) Nodes() []*Node {
+	return append([]*Node(nil), b.load()...)
+}
+
+// MergeNodes 按地址对比新旧结点: 没有变化的结点沿用原来的对象, 权重, 健康状态或连接池配置变化的结点使用新对象并保留正在处理的请求数,
+// 不在新列表中的结点被移除
+func MergeNodes(old, hosts []*Node) []*Node {
+	merged := make([]*Node, 0, len(hosts))
+	for _, h := range hosts {
+		key := h.Key()
+		for _, o := range old {
+			if o.Key() != key {
+				continue
+			}
+			if o.Weight == h.Weight && o.Healthy == h.Healthy && o.options == h.options {
+				h = o
+			} else {
+				h.inflight.Store(o.inflight.Load())
+			}
+			break
+		}
+		merged = append(merged, h)
+	}
+	return merged
+}
@@ -0,0 +1,122 @@
+package balancer
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ClientOptions 结点连接池的配置, 为0的项使用fasthttp的默认值
+type ClientOptions struct {
+	MaxConns            int
+	MaxIdleConnDuration time.Duration
+	MaxConnWaitTimeout  time.Duration
+	ReadBufferSize      int
+	WriteBufferSize     int
+	DialDualStack       bool
+	TLSConfig           *tls.Config // scheme为https的结点使用, 同一个配置需要复用同一个对象, 否则每次重载都会重建连接池
+}
+
+// nodeClients 结点的连接池, 连接在第一次请求时建立
+type nodeClients struct {
+	client *fasthttp.HostClient
+}
+
+func newNodeClients(n *Node) *nodeClients {
+	return &nodeClients{client: newHostClient(n)}
+}
+
+func newHostClient(n *Node) *fasthttp.HostClient {
+	o := n.options
+	c := &fasthttp.HostClient{
+		Addr:                n.Addr(),
+		IsTLS:               n.IsTLS(),
+		MaxConns:            o.MaxConns,
+		MaxIdleConnDuration: o.MaxIdleConnDuration,
+		MaxConnWaitTimeout:  o.MaxConnWaitTimeout,
+		ReadBufferSize:      o.ReadBufferSize,
+		WriteBufferSize:     o.WriteBufferSize,
+		DialDualStack:       o.DialDualStack,
+	}
+	if c.IsTLS {
+		c.TLSConfig = o.TLSConfig
+	}
+	return c
+}
+
+// close 关闭空闲连接, 正在使用的连接在请求结束后由连接池的清理协程关闭
+func (c *nodeClients) close() {
+	c.client.CloseIdleConnections()
+}
+
+// IsTLS 是否使用https连接结点
+func (n *Node) IsTLS() bool {
+	return n.Scheme == SchemeHTTPS
+}
+
+// URL 结点上path的完整地址
+func (n *Node) URL(path string) string {
+	scheme := SchemeHTTP
+	if n.IsTLS() {
+		scheme = SchemeHTTPS
+	}
+	return scheme + "://" + n.Addr() + path
+}
+
+// Key 结点的唯一标识 scheme://host:port, 同一个主机上的不同端口和协议是不同的结点
+func (n *Node) Key() string {
+	return n.URL("")
+}
+
+// Client 结点的连接池. 不在注册表中的临时结点没有连接池, 返回一次性的client
+func (n *Node) Client() *fasthttp.HostClient {
+	if n.clients == nil {
+		return newHostClient(n)
+	}
+	return n.clients.client
+}
+
+// ConnsCount 结点当前打开的连接数
+func (n *Node) ConnsCount() int {
+	if n.clients == nil {
+		return 0
+	}
+	return n.clients.client.ConnsCount()
+}
+
+// PendingRequests 结点正在等待响应的请求数
+func (n *Node) PendingRequests() int {
+	if n.clients == nil {
+		return 0
+	}
+	return n.clients.client.PendingRequests()
+}
+
+// attachClients 新结点沿用地址和配置相同的旧结点的连接池, 否则创建新的连接池.
+// 返回不再使用的旧连接池
+func attachClients(old, nodes []*Node) []*nodeClients {
+	used := make(map[*nodeClients]bool, len(nodes))
+	for _, n := range nodes {
+		if n.clients == nil {
+			for _, o := range old {
+				if o.clients != nil && o.Service == n.Service && o.Port == n.Port && o.Scheme == n.Scheme && o.options == n.options {
+					n.clients = o.clients
+					break
+				}
+			}
+		}
+		if n.clients == nil {
+			n.clients = newNodeClients(n)
+		}
+		used[n.clients] = true
+	}
+	var unused []*nodeClients
+	for _, o := range old {
+		if o.clients != nil && !used[o.clients] {
+			used[o.clients] = true
+			unused = append(unused, o.clients)
+		}
+	}
+	return unused
+}
@@ -9,4 +9,10 @@ const (
 	RandomBalancer = "random"
 	R2Balancer     = "roundRobin"
 	WWRBalancer    = "wwr"
+
+	// DefaultBalancer 路由没有配置负载均衡策略时使用
+	DefaultBalancer = R2Balancer
+
+	SchemeHTTP  = "http"
+	SchemeHTTPS = "https"
 )
@@ -19,20 +19,17 @@ type IPHash struct {
 
 // NewIPHash create new IPHash balancer
 func NewIPHash(hosts []*Node) Balancer {
-	return &IPHash{
-		BaseBalancer: BaseBalancer{
-			hosts: hosts,
-		},
-	}
+	b := &IPHash{}
+	b.store(hosts)
+	return b
 }
 
 // Balance selects a suitable host according
 func (r *IPHash) Balance(key string) (*Node, error) {
-	r.RLock()
-	defer r.RUnlock()
-	if len(r.hosts) == 0 {
+	hosts := r.load()
+	if len(hosts) == 0 {
 		return nil, NoHostError
 	}
-	value := crc32.ChecksumIEEE([]byte(key)) % uint32(len(r.hosts))
-	return r.hosts[value], nil
+	value := crc32.ChecksumIEEE([]byte(key)) % uint32(len(hosts))
+	return hosts[value], nil
 }
@@ -6,7 +6,6 @@ package balancer
 
 import (
 	"math/rand"
-	"time"
 )
 
 func init() {
@@ -16,25 +15,21 @@ func init() {
 // Random will randomly select a http server from the server
 type Random struct {
 	BaseBalancer
-	rnd *rand.Rand
 }
 
 // NewRandom create new Random balancer
 func NewRandom(hosts []*Node) Balancer {
-	return &Random{
-		BaseBalancer: BaseBalancer{
-			hosts: hosts,
-		},
-		rnd: rand.New(rand.NewSource(time.Now().UnixNano())),
-	}
+	b := &Random{}
+	b.store(hosts)
+	return b
 }
 
 // Balance selects a suitable host according
 func (r *Random) Balance(_ string) (*Node, error) {
-	r.RLock()
-	defer r.RUnlock()
-	if len(r.hosts) == 0 {
+	hosts := r.load()
+	if len(hosts) == 0 {
 		return nil, NoHostError
 	}
-	return r.hosts[r.rnd.Intn(len(r.hosts))], nil
+	// 全局的rand并发安全
+	return hosts[rand.Intn(len(hosts))], nil
 }
@@ -1,9 +1,11 @@
 package balancer
 
+import "sync/atomic"
+
 // RoundRobin will select the server in turn from the server to gateway
 type RoundRobin struct {
 	BaseBalancer
-	i uint64
+	i atomic.Uint64
 }
 
 func init() {
@@ -12,22 +14,16 @@ func init() {
 
 // NewRoundRobin create new RoundRobin balancer
 func NewRoundRobin(hosts []*Node) Balancer {
-	return &RoundRobin{
-		i: 0,
-		BaseBalancer: BaseBalancer{
-			hosts: hosts,
-		},
-	}
+	b := &RoundRobin{}
+	b.store(hosts)
+	return b
 }
 
 // Balance selects a suitable host according
 func (r *RoundRobin) Balance(_ string) (*Node, error) {
-	r.RLock()
-	defer r.RUnlock()
-	if len(r.hosts) == 0 {
+	hosts := r.load()
+	if len(hosts) == 0 {
 		return nil, NoHostError
 	}
-	host := r.hosts[r.i%uint64(len(r.hosts))]
-	r.i++
-	return host, nil
+	return hosts[(r.i.Add(1)-1)%uint64(len(hosts))], nil
 }
@@ -1,23 +1,42 @@
 package balancer
 
 import (
+	"errors"
+	"fmt"
 	"go-faster-gateway/internal/pkg/ecode"
 	"strconv"
 	"sync"
+	"sync/atomic"
 )
 
-// 上游负载均衡器，服务发现时会将服务缓存至upstreams map中，当进行负载均衡时，按照一定的规则进行负载均衡
+// 上游负载均衡器，服务发现时会将服务缓存至注册表中，当进行负载均衡时，按照一定的规则进行负载均衡.
+// 注册表在变更时整体替换(copy-on-write), 请求路径上的读取不加锁
 type Upstream struct {
-	LB        map[string]Balancer
-	SyncNodes SyncNodesCh
-	mu        sync.RWMutex
+	registry atomic.Pointer[map[string]*upstreamService]
+	mu       sync.Mutex // 串行化注册表的变更
+	pushed   map[string]pushedNodes
 }
 
-type SyncNodesCh chan NodeServer
+// upstreamService 服务的负载均衡器及其策略, 策略变化时重建负载均衡器
+type upstreamService struct {
+	algorithm string
+	options   ClientOptions
+	lb        Balancer
+	config    NodeServer // 最近一次配置中的服务, 用于判断推送的结点是否过期
+}
+
+// pushedNodes 通过SetNodes推送的结点, 服务的配置变化之前重载时代替配置中的结点
+type pushedNodes struct {
+	config NodeServer // 推送时服务的配置
+	ns     NodeServer
+}
 
+// NodeServer 服务的全部结点, 不在Nodes中的结点会被移除
 type NodeServer struct {
 	ServiceName string
 	Nodes       []*Node
+	Algorithm   string         // 负载均衡策略, 为空时使用默认策略, 推送结点时为空表示不变
+	Client      *ClientOptions // 结点连接池的配置, 推送结点时为nil表示不变
 }
 
 // 一个后端结点对应一个Upstream
@@ -26,49 +45,214 @@ type Node struct {
 	Port    uint32 // 端口
 	Weight  int32  // 权重
 	Healthy bool   // 是否健康
+	Scheme  string // 协议 http/https, 为空时使用http
+
+	inflight atomic.Int64  // 正在处理的请求数
+	options  ClientOptions // 连接池配置, 变化时重建连接池
+	clients  *nodeClients  // 连接池, 由注册表创建和关闭
 }
 
-func (u *Upstream) Watcher(ch SyncNodesCh) {
-	for {
-		select {
-		case data := <-ch:
-			u.mu.Lock()
-			for _, v := range data.Nodes {
-				u.LB[v.Service].Add(v)
-			}
-			u.mu.Lock()
-		default:
+// InFlight 当前正在处理的请求数
+func (n *Node) InFlight() int64 {
+	return n.inflight.Load()
+}
+
+// Addr 结点的 host:port
+func (n *Node) Addr() string {
+	return n.Service + ":" + strconv.Itoa(int(n.Port))
+}
 
+// SetNodes 用完整的结点列表原子地替换一个服务的结点, 不在列表中的结点被移除, 其他服务不受影响.
+// 推送的结点在服务的配置变化之前一直有效, 其他服务的配置重载不会覆盖; 配置中没有的服务在下一次重载时移除
+func (u *Upstream) SetNodes(ns NodeServer) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	current := u.load()
+	service := ns.ServiceName
+	var config NodeServer
+	if s, ok := current[service]; ok {
+		if ns.Algorithm == "" {
+			ns.Algorithm = s.algorithm
 		}
+		if ns.Client == nil {
+			options := s.options
+			ns.Client = &options
+		}
+		config = s.config
+	}
+	s, err := current[service].update(ns)
+	if err != nil {
+		return err
 	}
+	s.config = config
+	if u.pushed == nil {
+		u.pushed = make(map[string]pushedNodes)
+	}
+	u.pushed[service] = pushedNodes{config: config, ns: ns}
+	registry := make(map[string]*upstreamService, len(current)+1)
+	for k, v := range current {
+		registry[k] = v
+	}
+	registry[service] = s
+	u.registry.Store(&registry)
+	return nil
 }
 
-func (u *Upstream) AddToLB(service string, nodes []*Node, algorithm string) error {
-	var err error
+// Reload 用一个配置版本中的全部服务替换注册表. 和上一个版本对比:
+// 新增的服务创建负载均衡器, 已有的服务增删和更新结点, 不在配置中的服务被移除.
+// 配置没有变化的服务保留推送的结点. 策略不支持的服务被跳过, 错误合并返回
+func (u *Upstream) Reload(services []NodeServer) error {
 	u.mu.Lock()
 	defer u.mu.Unlock()
-	_, ok := u.LB[service]
-	if !ok {
-		u.LB[service], err = Build(algorithm, nodes)
+	current := u.load()
+	registry := make(map[string]*upstreamService, len(services))
+	var errs []error
+	for _, config := range services {
+		ns := config
+		if p, ok := u.pushed[ns.ServiceName]; ok {
+			if sameConfig(p.config, config) {
+				ns = p.ns
+			} else {
+				delete(u.pushed, ns.ServiceName)
+			}
+		}
+		s, err := current[ns.ServiceName].update(ns)
 		if err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("service %s: %w", ns.ServiceName, err))
+			continue
 		}
+		s.config = config
+		registry[ns.ServiceName] = s
 	}
-	for _, v := range nodes {
-		u.LB[service].Add(v)
+	for name := range u.pushed {
+		if _, ok := registry[name]; !ok {
+			delete(u.pushed, name)
+		}
 	}
-	return err
+	u.registry.Store(&registry)
+	// 移除的服务关闭全部连接池
+	for name, s := range current {
+		if _, ok := registry[name]; !ok {
+			for _, c := range attachClients(s.lb.Nodes(), nil) {
+				c.close()
+			}
+		}
+	}
+	return errors.Join(errs...)
 }
 
-func (u *Upstream) GetNextUpstream(service string) (string, error) {
-	u.mu.RLock()
-	defer u.mu.RUnlock()
-	if lb, ok := u.LB[service]; ok {
-		upstreamServer, err := lb.Balance(service)
-		if err != nil {
-			return "", err
+// update 策略没有变化时在原来的负载均衡器上替换结点, 否则用合并后的结点创建新的负载均衡器.
+// 结点的连接池在这里创建, 不再使用的连接池在替换后关闭
+func (s *upstreamService) update(ns NodeServer) (*upstreamService, error) {
+	algorithm := ns.Algorithm
+	if algorithm == "" {
+		algorithm = DefaultBalancer
+	}
+	var options ClientOptions
+	if ns.Client != nil {
+		options = *ns.Client
+	}
+	nodes := make([]*Node, 0, len(ns.Nodes))
+	for _, n := range ns.Nodes {
+		// 推送的结点可能被调用方复用, 复制一份再设置连接池
+		node := &Node{Service: n.Service, Port: n.Port, Weight: n.Weight, Healthy: n.Healthy, Scheme: n.Scheme, options: options}
+		nodes = append(nodes, node)
+	}
+	var old []*Node
+	if s != nil {
+		old = s.lb.Nodes()
+	}
+	nodes = MergeNodes(old, nodes)
+	unused := attachClients(old, nodes)
+	defer func() {
+		for _, c := range unused {
+			c.close()
+		}
+	}()
+	if s != nil && s.algorithm == algorithm {
+		s.lb.Set(nodes)
+		if s.options == options {
+			return s, nil
+		}
+		return &upstreamService{algorithm: algorithm, options: options, lb: s.lb}, nil
+	}
+	lb, err := Build(algorithm, nodes)
+	if err != nil {
+		// 失败时只关闭新创建的连接池, 旧结点的连接池仍在使用
+		unused = attachClients(nodes, old)
+		return nil, err
+	}
+	return &upstreamService{algorithm: algorithm, options: options, lb: lb}, nil
+}
+
+// sameConfig 服务的配置是否相同, 结点按顺序比较
+func sameConfig(a, b NodeServer) bool {
+	if a.ServiceName != b.ServiceName || a.Algorithm != b.Algorithm || len(a.Nodes) != len(b.Nodes) {
+		return false
+	}
+	if (a.Client == nil) != (b.Client == nil) || (a.Client != nil && *a.Client != *b.Client) {
+		return false
+	}
+	for i, n := range a.Nodes {
+		m := b.Nodes[i]
+		if n.Service != m.Service || n.Port != m.Port || n.Weight != m.Weight || n.Healthy != m.Healthy || n.Scheme != m.Scheme {
+			return false
 		}
-		return upstreamServer.Service + ":" + strconv.Itoa(int(upstreamServer.Port)), nil
 	}
-	return "", ecode.UpstreamNotInit
+	return true
+}
+
+func (u *Upstream) load() map[string]*upstreamService {
+	if registry := u.registry.Load(); registry != nil {
+		return *registry
+	}
+	return nil
+}
+
+func (u *Upstream) balancer(service string) (Balancer, bool) {
+	s, ok := u.load()[service]
+	if !ok {
+		return nil, false
+	}
+	return s.lb, true
+}
+
+func (u *Upstream) GetNextUpstream(service string) (string, error) {
+	node, err := u.NextNode(service)
+	if err != nil {
+		return "", err
+	}
+	return node.Addr(), nil
+}
+
+// NextNode 按负载均衡策略选择服务的结点
+func (u *Upstream) NextNode(service string) (*Node, error) {
+	if lb, ok := u.balancer(service); ok {
+		return lb.Balance(service)
+	}
+	return nil, ecode.UpstreamNotInit
+}
+
+// Nodes 获取所有服务的后端结点快照
+func (u *Upstream) Nodes() map[string][]*Node {
+	registry := u.load()
+	result := make(map[string][]*Node, len(registry))
+	for service, s := range registry {
+		result[service] = s.lb.Nodes()
+	}
+	return result
+}
+
+// Inc 结点开始处理一个请求, key为结点的Node.Key
+func (u *Upstream) Inc(service, key string) {
+	if lb, ok := u.balancer(service); ok {
+		lb.Inc(key)
+	}
+}
+
+// Done 结点处理完一个请求, key为结点的Node.Key
+func (u *Upstream) Done(service, key string) {
+	if lb, ok := u.balancer(service); ok {
+		lb.Done(key)
+	}
 }
@@ -0,0 +1,237 @@
+package balancer
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestSetNodes(t *testing.T) {
+	u := &Upstream{}
+	if err := u.SetNodes(NodeServer{ServiceName: "order", Nodes: []*Node{{Service: "10.0.0.1", Port: 80, Weight: 1, Healthy: true}}}); err != nil {
+		t.Fatal(err)
+	}
+	u.Inc("order", "http://10.0.0.1:80")
+
+	// 地址相同的结点保留正在处理的请求数, 不在列表中的结点被移除
+	if err := u.SetNodes(NodeServer{ServiceName: "order", Nodes: []*Node{
+		{Service: "10.0.0.1", Port: 80, Weight: 5, Healthy: true},
+		{Service: "10.0.0.2", Port: 80, Weight: 1, Healthy: true},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	nodes := u.Nodes()["order"]
+	if len(nodes) != 2 || nodes[0].Weight != 5 || nodes[0].InFlight() != 1 {
+		t.Fatalf("unexpected nodes %+v", nodes)
+	}
+
+	if err := u.SetNodes(NodeServer{ServiceName: "order", Nodes: []*Node{{Service: "10.0.0.2", Port: 80, Weight: 1, Healthy: true}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.SetNodes(NodeServer{ServiceName: "user", Nodes: []*Node{{Service: "10.0.1.1", Port: 80, Weight: 1, Healthy: true}}, Algorithm: "unknown"}); err == nil {
+		t.Error("expected error for unsupported algorithm")
+	}
+	if addr, err := u.GetNextUpstream("order"); err != nil || addr != "10.0.0.2:80" {
+		t.Errorf("unexpected upstream %s, err %v", addr, err)
+	}
+	if _, ok := u.Nodes()["user"]; ok {
+		t.Error("service with unsupported algorithm should not be created")
+	}
+}
+
+func TestNodeKey(t *testing.T) {
+	u := &Upstream{}
+	if err := u.SetNodes(NodeServer{ServiceName: "order", Nodes: []*Node{
+		{Service: "10.0.0.1", Port: 80, Weight: 1, Healthy: true},
+		{Service: "10.0.0.1", Port: 8080, Weight: 1, Healthy: true},
+		{Service: "10.0.0.1", Port: 8080, Weight: 1, Healthy: true, Scheme: SchemeHTTPS},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	// 同一个主机上的结点按端口和协议分别计数
+	u.Inc("order", "http://10.0.0.1:8080")
+	nodes := u.Nodes()["order"]
+	if len(nodes) != 3 || nodes[0].InFlight() != 0 || nodes[1].InFlight() != 1 || nodes[2].InFlight() != 0 {
+		t.Fatalf("unexpected in-flight counts %d %d %d", nodes[0].InFlight(), nodes[1].InFlight(), nodes[2].InFlight())
+	}
+	u.Done("order", "http://10.0.0.1:8080")
+	if nodes[1].InFlight() != 0 {
+		t.Errorf("unexpected in-flight count %d", nodes[1].InFlight())
+	}
+
+	lb, err := Build(R2Balancer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lb.Add(&Node{Service: "10.0.0.1", Port: 80, Weight: 1, Healthy: true})
+	lb.Add(&Node{Service: "10.0.0.1", Port: 8080, Weight: 1, Healthy: true})
+	lb.Add(&Node{Service: "10.0.0.1", Port: 80, Weight: 1, Healthy: true, Scheme: SchemeHTTP})
+	if len(lb.Nodes()) != 2 {
+		t.Errorf("nodes with different ports should both be added, got %d", len(lb.Nodes()))
+	}
+	lb.Remove("http://10.0.0.1:8080")
+	if nodes := lb.Nodes(); len(nodes) != 1 || nodes[0].Port != 80 {
+		t.Errorf("unexpected nodes after remove %+v", nodes)
+	}
+}
+
+func TestPushedNodes(t *testing.T) {
+	u := &Upstream{}
+	config := func(hosts ...string) []NodeServer {
+		ns := NodeServer{ServiceName: "order"}
+		for _, host := range hosts {
+			ns.Nodes = append(ns.Nodes, &Node{Service: host, Port: 80, Weight: 1, Healthy: true})
+		}
+		return []NodeServer{ns}
+	}
+	hosts := func() []string {
+		var result []string
+		for _, n := range u.Nodes()["order"] {
+			result = append(result, n.Service)
+		}
+		return result
+	}
+	if err := u.Reload(config("10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.SetNodes(NodeServer{ServiceName: "order", Nodes: []*Node{{Service: "10.0.0.2", Port: 80, Weight: 1, Healthy: true}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 其他provider触发的重载, 服务的配置没有变化, 保留推送的结点
+	if err := u.Reload(config("10.0.0.1")); err != nil {
+		t.Fatal(err)
+	}
+	if got := hosts(); len(got) != 1 || got[0] != "10.0.0.2" {
+		t.Errorf("pushed nodes lost after reload: %v", got)
+	}
+
+	// 服务的配置变化后以配置为准
+	if err := u.Reload(config("10.0.0.3")); err != nil {
+		t.Fatal(err)
+	}
+	if got := hosts(); len(got) != 1 || got[0] != "10.0.0.3" {
+		t.Errorf("config change should replace pushed nodes: %v", got)
+	}
+	if err := u.Reload(config("10.0.0.3")); err != nil {
+		t.Fatal(err)
+	}
+	if got := hosts(); len(got) != 1 || got[0] != "10.0.0.3" {
+		t.Errorf("expired pushed nodes came back: %v", got)
+	}
+}
+
+func TestReload(t *testing.T) {
+	u := &Upstream{}
+	node := func(host string, weight int32) *Node {
+		return &Node{Service: host, Port: 80, Weight: weight, Healthy: true}
+	}
+	if err := u.Reload([]NodeServer{
+		{ServiceName: "order", Nodes: []*Node{node("10.0.0.1", 1), node("10.0.0.2", 1)}},
+		{ServiceName: "user", Nodes: []*Node{node("10.0.1.1", 1)}, Algorithm: WWRBalancer},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	before := u.Nodes()["order"]
+
+	// 请求路径并发读取, 配合 -race 检查
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				if _, err := u.GetNextUpstream("order"); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}
+	}()
+	err := u.Reload([]NodeServer{
+		{ServiceName: "order", Nodes: []*Node{node("10.0.0.1", 1), node("10.0.0.2", 3), node("10.0.0.3", 1)}, Algorithm: R2Balancer},
+		{ServiceName: "pay", Nodes: []*Node{node("10.0.2.1", 1)}, Algorithm: "unknown"},
+	})
+	close(stop)
+	<-done
+	if err == nil {
+		t.Error("expected error for unsupported algorithm")
+	}
+
+	nodes := u.Nodes()
+	if _, ok := nodes["user"]; ok {
+		t.Error("service removed from config should be removed")
+	}
+	if _, ok := nodes["pay"]; ok {
+		t.Error("service with unsupported algorithm should be skipped")
+	}
+	order := nodes["order"]
+	if len(order) != 3 || order[1].Weight != 3 {
+		t.Fatalf("unexpected nodes %+v", order)
+	}
+	// 没有变化的结点沿用原来的对象, 权重变化的结点使用新对象
+	if order[0] != before[0] || order[1] == before[1] {
+		t.Error("unchanged nodes should be kept and changed nodes replaced")
+	}
+}
+
+func TestNodeClients(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	addr := server.Listener.Addr().(*net.TCPAddr)
+	nodes := func(weight int32) []*Node {
+		return []*Node{{Service: addr.IP.String(), Port: uint32(addr.Port), Weight: weight, Healthy: true}}
+	}
+
+	u := &Upstream{}
+	options := &ClientOptions{MaxConns: 4}
+	if err := u.Reload([]NodeServer{{ServiceName: "order", Nodes: nodes(1), Client: options}}); err != nil {
+		t.Fatal(err)
+	}
+	node, err := u.NextNode("order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := node.Client()
+	if client.MaxConns != 4 || node.Client() != client {
+		t.Fatalf("node should keep one configured client, got %+v", client)
+	}
+	req, resp := fasthttp.AcquireRequest(), fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+	req.SetRequestURI("http://" + node.Addr() + "/")
+	if err = client.Do(req, resp); err != nil {
+		t.Fatal(err)
+	}
+	if node.ConnsCount() != 1 {
+		t.Fatalf("open connections = %d, want 1", node.ConnsCount())
+	}
+
+	// 权重变化时沿用连接池, 连接池配置变化时重建
+	if err = u.Reload([]NodeServer{{ServiceName: "order", Nodes: nodes(2), Client: options}}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := u.NextNode("order"); n == node || n.Client() != client {
+		t.Error("client should be reused when only the weight changes")
+	}
+	if err = u.Reload([]NodeServer{{ServiceName: "order", Nodes: nodes(2), Client: &ClientOptions{MaxConns: 8}}}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := u.NextNode("order"); n.Client() == client || n.Client().MaxConns != 8 {
+		t.Error("client should be rebuilt when the options change")
+	}
+	if node.ConnsCount() != 0 {
+		t.Errorf("replaced client should be closed, open connections = %d", node.ConnsCount())
+	}
+
+	// 临时结点没有连接池
+	if c := (&Node{Service: "10.0.0.1", Port: 443, Scheme: SchemeHTTPS}).Client(); c.Addr != "10.0.0.1:443" || !c.IsTLS {
+		t.Error("unexpected client of a node outside the registry")
+	}
+}
@@ -15,11 +15,9 @@ type WWR struct {
 }
 
 func NewWWR(hosts []*Node) Balancer {
-	return &IPHash{
-		BaseBalancer: BaseBalancer{
-			hosts: hosts,
-		},
-	}
+	b := &WWR{}
+	b.store(hosts)
+	return b
 }
 
 type Chooser struct {
@@ -44,14 +42,13 @@ func (a nodes) Less(i, j int) bool {
 
 // Balance selects a suitable host according
 func (r *WWR) Balance(_ string) (*Node, error) {
-	r.RLock()
-	defer r.RUnlock()
-	if len(r.hosts) == 0 {
+	hosts := r.load()
+	if len(hosts) == 0 {
 		return nil, NoHostError
 	}
 	var result []*Node
 	mw := 0
-	for _, host := range r.hosts {
+	for _, host := range hosts {
 		if host.Healthy && host.Weight > 0 {
 			cw := int(math.Ceil(float64(host.Weight)))
 			if cw > mw {
@@ -60,6 +57,9 @@ func (r *WWR) Balance(_ string) (*Node, error) {
 			result = append(result, host)
 		}
 	}
+	if len(result) == 0 {
+		return nil, NoHostError
+	}
 	instance := newChooser(result).pick()
 	return instance, nil
 }
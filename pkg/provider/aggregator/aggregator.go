@@ -4,7 +4,6 @@ import (
 	"context"
 	"go-faster-gateway/pkg/config/dynamic"
 	"go-faster-gateway/pkg/config/static"
-	"go-faster-gateway/pkg/helper/utils"
 	"go-faster-gateway/pkg/log"
 	"go-faster-gateway/pkg/provider"
 	"go-faster-gateway/pkg/provider/file"
@@ -78,6 +77,30 @@ func NewProviderAggregator(conf static.Providers) *ProviderAggregator {
 		p.quietAddProvider(conf.File)
 	}
 
+	if conf.HTTP != nil {
+		p.quietAddProvider(conf.HTTP)
+	}
+
+	if conf.ConsulCatalog != nil {
+		p.quietAddProvider(conf.ConsulCatalog)
+	}
+
+	if conf.Etcd != nil {
+		p.quietAddProvider(conf.Etcd)
+	}
+
+	if conf.Nacos != nil {
+		p.quietAddProvider(conf.Nacos)
+	}
+
+	if conf.Kubernetes != nil {
+		p.quietAddProvider(conf.Kubernetes)
+	}
+
+	if conf.Docker != nil {
+		p.quietAddProvider(conf.Docker)
+	}
+
 	//如果有其他类型提供配置文件，比如nacos,apollo等
 
 	return p
@@ -128,31 +151,34 @@ func (p *ProviderAggregator) Provide(configurationChan chan<- dynamic.Message, p
 }
 
 func (p *ProviderAggregator) GetConfig() (dynamic.Message, error) {
-	var dyConfig = new(dynamic.Configuration)
-	//合并所有动态配置文件
+	//合并所有动态配置文件, 各个provider的服务按名称合并
+	configs := make(dynamic.Configurations)
 	if p.fileProvider != nil {
-		dyConfig = resolveProviderConfig(p.fileProvider, dyConfig)
+		resolveProviderConfig(p.fileProvider, configs)
 	}
 	for _, prd := range p.providers {
-		dyConfig = resolveProviderConfig(prd, dyConfig)
+		resolveProviderConfig(prd, configs)
+	}
+	dyConfig, conflicts := dynamic.MergeConfigurations(configs)
+	for _, err := range conflicts {
+		log.Log.WithError(err).Error("aggregate configuration conflict")
 	}
 	return dynamic.Message{
 		Configuration: dyConfig,
 	}, nil
 }
 
-func resolveProviderConfig(c provider.Provider, dyConfig *dynamic.Configuration) *dynamic.Configuration {
+func resolveProviderConfig(c provider.Provider, configs dynamic.Configurations) {
 	msg, err := c.GetConfig()
 	if err != nil {
-		log.Log.WithError(err).Error("aggregate GetConfig fail")
-		return nil
+		log.Log.WithError(err).Errorf("aggregate GetConfig of %T fail", c)
+		return
 	}
-	dyConfig, err = utils.DeepCopy(dyConfig, msg.Configuration)
-	if err != nil {
-		log.Log.WithError(err).Error("aggregate DeepCopy fail")
-		return nil
+	// 还没有加载到配置的provider不参与合并
+	if msg.Configuration == nil {
+		return
 	}
-	return dyConfig
+	configs[msg.ProviderName] = msg.Configuration
 }
 
 func (p *ProviderAggregator) launchProvider(configurationChan chan<- dynamic.Message, pool *safe.Pool, prd provider.Provider) {
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"errors"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/log"
+	"go-faster-gateway/pkg/provider"
+	"go-faster-gateway/pkg/safe"
+	"sync"
+)
+
+const providerName = "api"
+
+var (
+	// ErrRouteNotFound 路由不存在或者不是由api provider管理
+	ErrRouteNotFound = errors.New("api provider: route not found")
+	// ErrRouteExists 路由已存在
+	ErrRouteExists = errors.New("api provider: route already exists")
+	// ErrServerNotFound 路由下不存在该结点
+	ErrServerNotFound = errors.New("api provider: server not found")
+	// ErrServerExists 路由下已存在该结点
+	ErrServerExists = errors.New("api provider: server already exists")
+)
+
+var _ provider.Provider = (*Provider)(nil)
+
+// Store 持久化api provider管理的路由, 重启后从Store中恢复
+type Store interface {
+	Load() (map[string]map[string]*dynamic.ServiceRoute, error)
+	Save(services map[string]map[string]*dynamic.ServiceRoute) error
+}
+
+// Provider 通过管理接口在运行时增删改路由和上游结点, 每次变更都会发布新的动态配置,
+// 和其他provider的配置合并后走正常的重载流程
+type Provider struct {
+	store Store
+
+	mu                sync.Mutex
+	services          map[string]map[string]*dynamic.ServiceRoute
+	configurationChan chan<- dynamic.Message
+}
+
+// New 创建api provider, store为空时变更只保存在内存中
+func New(store Store) *Provider {
+	return &Provider{
+		store:    store,
+		services: make(map[string]map[string]*dynamic.ServiceRoute),
+	}
+}
+
+// Init the provider.
+func (p *Provider) Init() error {
+	return nil
+}
+
+// Provide 从Store中恢复路由并发布第一份配置, 之后每次变更都会通过configurationChan发布
+func (p *Provider) Provide(configurationChan chan<- dynamic.Message, pool *safe.Pool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.store != nil {
+		services, err := p.store.Load()
+		if err != nil {
+			log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName}).
+				WithError(err).Error("Error while loading persisted routes")
+		} else if services != nil {
+			p.services = services
+		}
+	}
+	p.configurationChan = configurationChan
+	p.publish()
+	return nil
+}
+
+// GetConfig for provider get config
+func (p *Provider) GetConfig() (dynamic.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return dynamic.Message{
+		ProviderName:  providerName,
+		Configuration: p.buildConfiguration(),
+	}, nil
+}
+
+// Routes 当前由api provider管理的路由
+func (p *Provider) Routes() map[string]map[string]*dynamic.ServiceRoute {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.buildConfiguration().EasyServiceRoute.Services
+}
+
+// Route 获取api provider管理的某个路由
+func (p *Provider) Route(service, name string) (*dynamic.ServiceRoute, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	route, ok := p.services[service][name]
+	if !ok {
+		return nil, false
+	}
+	return cloneRoute(route), true
+}
+
+// CreateRoute 新增路由, 已存在时返回ErrRouteExists
+func (p *Provider) CreateRoute(service, name string, route *dynamic.ServiceRoute) error {
+	return p.update(func(services map[string]map[string]*dynamic.ServiceRoute) error {
+		if _, ok := services[service][name]; ok {
+			return ErrRouteExists
+		}
+		if services[service] == nil {
+			services[service] = make(map[string]*dynamic.ServiceRoute)
+		}
+		services[service][name] = route
+		return nil
+	})
+}
+
+// UpdateRoute 替换整个路由, 不存在时返回ErrRouteNotFound
+func (p *Provider) UpdateRoute(service, name string, route *dynamic.ServiceRoute) error {
+	return p.update(func(services map[string]map[string]*dynamic.ServiceRoute) error {
+		if _, ok := services[service][name]; !ok {
+			return ErrRouteNotFound
+		}
+		services[service][name] = route
+		return nil
+	})
+}
+
+// DeleteRoute 删除路由, 服务下没有路由时一并删除服务
+func (p *Provider) DeleteRoute(service, name string) error {
+	return p.update(func(services map[string]map[string]*dynamic.ServiceRoute) error {
+		if _, ok := services[service][name]; !ok {
+			return ErrRouteNotFound
+		}
+		delete(services[service], name)
+		if len(services[service]) == 0 {
+			delete(services, service)
+		}
+		return nil
+	})
+}
+
+// AddServer 给路由添加一个上游结点
+func (p *Provider) AddServer(service, name string, server dynamic.Server) error {
+	return p.update(func(services map[string]map[string]*dynamic.ServiceRoute) error {
+		route, ok := services[service][name]
+		if !ok {
+			return ErrRouteNotFound
+		}
+		if indexServer(route.Servers, server.Host, server.Port) >= 0 {
+			return ErrServerExists
+		}
+		route.Servers = append(route.Servers, server)
+		return nil
+	})
+}
+
+// DrainServer 从路由中摘除一个上游结点, 新的请求不会再转发到该结点
+func (p *Provider) DrainServer(service, name, host string, port uint64) error {
+	return p.update(func(services map[string]map[string]*dynamic.ServiceRoute) error {
+		route, ok := services[service][name]
+		if !ok {
+			return ErrRouteNotFound
+		}
+		i := indexServer(route.Servers, host, port)
+		if i < 0 {
+			return ErrServerNotFound
+		}
+		route.Servers = append(route.Servers[:i], route.Servers[i+1:]...)
+		return nil
+	})
+}
+
+// SetServerHealth 修改上游结点的健康状态
+func (p *Provider) SetServerHealth(service, name, host string, port uint64, healthy bool) error {
+	return p.update(func(services map[string]map[string]*dynamic.ServiceRoute) error {
+		route, ok := services[service][name]
+		if !ok {
+			return ErrRouteNotFound
+		}
+		i := indexServer(route.Servers, host, port)
+		if i < 0 {
+			return ErrServerNotFound
+		}
+		route.Servers[i].Healthy = healthy
+		return nil
+	})
+}
+
+// update 在副本上修改, 持久化成功之后才替换当前路由并发布配置
+func (p *Provider) update(fn func(services map[string]map[string]*dynamic.ServiceRoute) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	services := p.buildConfiguration().EasyServiceRoute.Services
+	if err := fn(services); err != nil {
+		return err
+	}
+	if p.store != nil {
+		if err := p.store.Save(services); err != nil {
+			return err
+		}
+	}
+	p.services = services
+	p.publish()
+	return nil
+}
+
+// publish 需要持有锁, 保证配置按变更顺序发布
+func (p *Provider) publish() {
+	if p.configurationChan == nil {
+		return
+	}
+	p.configurationChan <- dynamic.Message{
+		ProviderName:  providerName,
+		Configuration: p.buildConfiguration(),
+	}
+}
+
+// buildConfiguration 返回路由的深拷贝, Services始终不为nil, 删除最后一个路由时也能发布空配置
+func (p *Provider) buildConfiguration() *dynamic.Configuration {
+	services := make(map[string]map[string]*dynamic.ServiceRoute, len(p.services))
+	for service, routes := range p.services {
+		services[service] = make(map[string]*dynamic.ServiceRoute, len(routes))
+		for name, route := range routes {
+			services[service][name] = cloneRoute(route)
+		}
+	}
+	return &dynamic.Configuration{
+		EasyServiceRoute: &dynamic.ServiceRouteConfiguration{Services: services},
+	}
+}
+
+func cloneRoute(route *dynamic.ServiceRoute) *dynamic.ServiceRoute {
+	r := *route
+	r.Routers = append([]dynamic.Router(nil), route.Routers...)
+	r.Servers = append([]dynamic.Server(nil), route.Servers...)
+	r.Middlewares = append([]string(nil), route.Middlewares...)
+	return &r
+}
+
+func indexServer(servers []dynamic.Server, host string, port uint64) int {
+	for i, s := range servers {
+		if s.Host == host && s.Port == port {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/safe"
+)
+
+func testRoute() *dynamic.ServiceRoute {
+	return &dynamic.ServiceRoute{
+		Handler: "http",
+		Routers: []dynamic.Router{{Path: "/order", Methods: []string{"GET"}}},
+		Servers: []dynamic.Server{{Host: "10.0.0.1", Port: 80, Weight: 1, Healthy: true}},
+	}
+}
+
+func TestProvider(t *testing.T) {
+	for _, ext := range []string{".yaml", ".json"} {
+		t.Run(ext, func(t *testing.T) {
+			store := NewFileStore(filepath.Join(t.TempDir(), "routes"+ext))
+			p := New(store)
+			ch := make(chan dynamic.Message, 10)
+			if err := p.Provide(ch, safe.NewPool(context.Background())); err != nil {
+				t.Fatal(err)
+			}
+			if msg := <-ch; msg.ProviderName != providerName || msg.Configuration.EasyServiceRoute.Services == nil {
+				t.Fatalf("unexpected first message %+v", msg)
+			}
+
+			if err := p.CreateRoute("order", "order_http", testRoute()); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.CreateRoute("order", "order_http", testRoute()); !errors.Is(err, ErrRouteExists) {
+				t.Errorf("create twice err = %v", err)
+			}
+			if err := p.AddServer("order", "order_http", dynamic.Server{Host: "10.0.0.2", Port: 80, Healthy: true}); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.SetServerHealth("order", "order_http", "10.0.0.1", 80, false); err != nil {
+				t.Fatal(err)
+			}
+			if err := p.DrainServer("order", "order_http", "10.0.0.3", 80); !errors.Is(err, ErrServerNotFound) {
+				t.Errorf("drain unknown server err = %v", err)
+			}
+			if err := p.UpdateRoute("user", "user_http", testRoute()); !errors.Is(err, ErrRouteNotFound) {
+				t.Errorf("update unknown route err = %v", err)
+			}
+
+			var msg dynamic.Message
+			for len(ch) > 0 {
+				msg = <-ch
+			}
+			servers := msg.Configuration.EasyServiceRoute.Services["order"]["order_http"].Servers
+			if len(servers) != 2 || servers[0].Healthy || !servers[1].Healthy {
+				t.Errorf("unexpected published servers %+v", servers)
+			}
+
+			// 重启后从文件恢复
+			restored, err := store.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if route := restored["order"]["order_http"]; route == nil || len(route.Servers) != 2 || route.Servers[0].Healthy {
+				t.Errorf("unexpected restored route %+v", route)
+			}
+
+			if err = p.DeleteRoute("order", "order_http"); err != nil {
+				t.Fatal(err)
+			}
+			msg = <-ch
+			if services := msg.Configuration.EasyServiceRoute.Services; services == nil || len(services) != 0 {
+				t.Errorf("expected empty services after delete, got %+v", services)
+			}
+		})
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Load() (map[string]map[string]*dynamic.ServiceRoute, error) { return nil, nil }
+
+func (failingStore) Save(map[string]map[string]*dynamic.ServiceRoute) error {
+	return errors.New("disk full")
+}
+
+func TestProviderSaveFailure(t *testing.T) {
+	p := New(failingStore{})
+	if err := p.CreateRoute("order", "order_http", testRoute()); err == nil {
+		t.Fatal("expected save error")
+	}
+	if _, ok := p.Route("order", "order_http"); ok {
+		t.Error("route must not be applied when persisting fails")
+	}
+}
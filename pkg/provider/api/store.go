@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"go-faster-gateway/pkg/config/dynamic"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var _ Store = (*FileStore)(nil)
+
+// FileStore 把路由保存到文件, 格式和file provider的easyServiceRoute一致, 根据扩展名使用json或者yaml
+type FileStore struct {
+	Filename string
+}
+
+// NewFileStore 创建文件存储
+func NewFileStore(filename string) *FileStore {
+	return &FileStore{Filename: filename}
+}
+
+// Load 文件不存在时返回空路由
+func (s *FileStore) Load() (map[string]map[string]*dynamic.ServiceRoute, error) {
+	data, err := os.ReadFile(s.Filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	conf := new(dynamic.Configuration)
+	if s.isJSON() {
+		err = json.Unmarshal(data, conf)
+	} else {
+		err = yaml.Unmarshal(data, conf)
+	}
+	if err != nil || conf.EasyServiceRoute == nil {
+		return nil, err
+	}
+	return conf.EasyServiceRoute.Services, nil
+}
+
+// Save 先写临时文件再重命名, 避免写到一半时进程退出导致文件损坏
+func (s *FileStore) Save(services map[string]map[string]*dynamic.ServiceRoute) error {
+	conf := &dynamic.Configuration{
+		EasyServiceRoute: &dynamic.ServiceRouteConfiguration{Services: services},
+	}
+	var data []byte
+	var err error
+	if s.isJSON() {
+		data, err = json.MarshalIndent(conf, "", "  ")
+	} else {
+		data, err = yaml.Marshal(conf)
+	}
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.Filename), filepath.Base(s.Filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Filename)
+}
+
+func (s *FileStore) isJSON() bool {
+	return strings.EqualFold(filepath.Ext(s.Filename), ".json")
+}
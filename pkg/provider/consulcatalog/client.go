@@ -0,0 +1,103 @@
+package consulcatalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// client consul http api的最小实现, 只包含catalog和health相关的阻塞查询.
+// 阻塞查询需要随ctx取消, 所以这里使用net/http
+type client struct {
+	address    string
+	datacenter string
+	token      string
+	waitTime   time.Duration
+	http       *http.Client
+}
+
+type serviceEntry struct {
+	Node struct {
+		Node    string `json:"Node"`
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		ID      string            `json:"ID"`
+		Service string            `json:"Service"`
+		Address string            `json:"Address"`
+		Port    int               `json:"Port"`
+		Tags    []string          `json:"Tags"`
+		Meta    map[string]string `json:"Meta"`
+		Weights struct {
+			Passing int `json:"Passing"`
+		} `json:"Weights"`
+	} `json:"Service"`
+}
+
+// services 所有服务名及其tag, index大于0时为阻塞查询, 直到数据变化或者超时
+func (c *client) services(ctx context.Context, index uint64) (map[string][]string, uint64, error) {
+	var services map[string][]string
+	newIndex, err := c.get(ctx, "/v1/catalog/services", url.Values{}, index, &services)
+	return services, newIndex, err
+}
+
+// healthState 任意健康检查变化都会更新index, 只用来感知结点健康状态的变化
+func (c *client) healthState(ctx context.Context, index uint64) (uint64, error) {
+	var checks []json.RawMessage
+	return c.get(ctx, "/v1/health/state/any", url.Values{}, index, &checks)
+}
+
+// healthyInstances 服务下健康检查全部通过的实例
+func (c *client) healthyInstances(ctx context.Context, service, tag string) ([]serviceEntry, error) {
+	q := url.Values{}
+	q.Set("passing", "true")
+	if tag != "" {
+		q.Set("tag", tag)
+	}
+	var entries []serviceEntry
+	_, err := c.get(ctx, "/v1/health/service/"+url.PathEscape(service), q, 0, &entries)
+	return entries, err
+}
+
+func (c *client) get(ctx context.Context, path string, q url.Values, index uint64, v interface{}) (uint64, error) {
+	if c.datacenter != "" {
+		q.Set("dc", c.datacenter)
+	}
+	timeout := 10 * time.Second
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", c.waitTime.String())
+		// consul会在wait的基础上加最多1/16的随机抖动
+		timeout = c.waitTime + c.waitTime/16 + 5*time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(c.address, "/")+path+"?"+q.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("consul request %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("consul request %s: unexpected status %d: %s", path, resp.StatusCode, body)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return 0, fmt.Errorf("consul request %s: %w", path, err)
+	}
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return newIndex, nil
+}
@@ -0,0 +1,262 @@
+package consulcatalog
+
+import (
+	"context"
+	"fmt"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/helper/parser"
+	"go-faster-gateway/pkg/log"
+	"go-faster-gateway/pkg/provider"
+	"go-faster-gateway/pkg/provider/label"
+	"go-faster-gateway/pkg/safe"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+const providerName = "consulcatalog"
+
+var _ provider.Provider = (*Provider)(nil)
+
+// Provider holds configurations of the provider.
+type Provider struct {
+	Endpoint        *EndpointConfig `description:"Consul endpoint settings" json:"endpoint,omitempty" toml:"endpoint,omitempty" yaml:"endpoint,omitempty" export:"true"`
+	Tag             string          `description:"Only the services carrying this tag are exposed." json:"tag,omitempty" toml:"tag,omitempty" yaml:"tag,omitempty" export:"true"`
+	WeightMetaKey   string          `description:"Service meta key holding the weight of an instance." json:"weightMetaKey,omitempty" toml:"weightMetaKey,omitempty" yaml:"weightMetaKey,omitempty" export:"true"`
+	RefreshInterval parser.Duration `description:"Interval between two retries when the Consul API fails." json:"refreshInterval,omitempty" toml:"refreshInterval,omitempty" yaml:"refreshInterval,omitempty" export:"true"`
+
+	client        *client
+	mu            sync.Mutex
+	configuration *dynamic.Configuration
+}
+
+// EndpointConfig holds configurations of the endpoint.
+type EndpointConfig struct {
+	Address          string             `description:"The address of the Consul server" json:"address,omitempty" toml:"address,omitempty" yaml:"address,omitempty"`
+	Datacenter       string             `description:"Data center to use. If not provided, the default agent data center is used" json:"datacenter,omitempty" toml:"datacenter,omitempty" yaml:"datacenter,omitempty"`
+	Token            string             `description:"Token is used to provide a per-request ACL token which overrides the agent's default token" json:"token,omitempty" toml:"token,omitempty" yaml:"token,omitempty" loggable:"false"`
+	TLS              *dynamic.ClientTLS `description:"Enable TLS support." json:"tls,omitempty" toml:"tls,omitempty" yaml:"tls,omitempty" export:"true"`
+	EndpointWaitTime parser.Duration    `description:"WaitTime limits how long a Watch will block. If not provided, the agent default values will be used" json:"endpointWaitTime,omitempty" toml:"endpointWaitTime,omitempty" yaml:"endpointWaitTime,omitempty" export:"true"`
+}
+
+// SetDefaults sets the default values.
+func (p *Provider) SetDefaults() {
+	if p.Endpoint == nil {
+		p.Endpoint = &EndpointConfig{}
+	}
+	if p.Endpoint.Address == "" {
+		p.Endpoint.Address = "http://127.0.0.1:8500"
+	}
+	if p.Endpoint.EndpointWaitTime <= 0 {
+		p.Endpoint.EndpointWaitTime = parser.Duration(30 * time.Second)
+	}
+	if p.Tag == "" {
+		p.Tag = "gateway"
+	}
+	if p.WeightMetaKey == "" {
+		p.WeightMetaKey = "weight"
+	}
+	if p.RefreshInterval <= 0 {
+		p.RefreshInterval = parser.Duration(5 * time.Second)
+	}
+}
+
+// Init the provider.
+func (p *Provider) Init() error {
+	p.SetDefaults()
+	tlsConfig, err := p.Endpoint.TLS.CreateTLSConfig()
+	if err != nil {
+		return fmt.Errorf("unable to create client TLS configuration: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	p.client = &client{
+		address:    p.Endpoint.Address,
+		datacenter: p.Endpoint.Datacenter,
+		token:      p.Endpoint.Token,
+		waitTime:   time.Duration(p.Endpoint.EndpointWaitTime),
+		http:       &http.Client{Transport: transport},
+	}
+	return nil
+}
+
+// Provide 通过阻塞查询监听服务列表和健康状态, 任意一个变化时重新生成配置
+func (p *Provider) Provide(configurationChan chan<- dynamic.Message, pool *safe.Pool) error {
+	changes := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+	pool.GoCtx(func(ctx context.Context) {
+		p.watch(ctx, "catalog services", func(index uint64) (uint64, error) {
+			_, newIndex, err := p.client.services(ctx, index)
+			return newIndex, err
+		}, notify)
+	})
+	pool.GoCtx(func(ctx context.Context) {
+		p.watch(ctx, "health state", func(index uint64) (uint64, error) {
+			return p.client.healthState(ctx, index)
+		}, notify)
+	})
+	pool.GoCtx(func(ctx context.Context) {
+		slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changes:
+				configuration, err := p.buildConfiguration(ctx)
+				if err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					slog.WithError(err).Error("Error while building configuration")
+					time.AfterFunc(time.Duration(p.RefreshInterval), notify)
+					continue
+				}
+				p.mu.Lock()
+				unchanged := reflect.DeepEqual(p.configuration, configuration)
+				p.configuration = configuration
+				p.mu.Unlock()
+				if unchanged {
+					continue
+				}
+				configurationChan <- dynamic.Message{
+					ProviderName:  providerName,
+					Configuration: configuration,
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// GetConfig 返回最近一次生成的配置, 第一次生成之前返回nil, 不参与配置合并
+func (p *Provider) GetConfig() (dynamic.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return dynamic.Message{
+		ProviderName:  providerName,
+		Configuration: p.configuration,
+	}, nil
+}
+
+// watch 循环执行阻塞查询, index变化时通知重新生成配置. consul的index变小时需要重置为0
+func (p *Provider) watch(ctx context.Context, name string, query func(index uint64) (uint64, error), notify func()) {
+	slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+	var index uint64
+	for {
+		newIndex, err := query(index)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			slog.WithError(err).Errorf("Error while watching %s", name)
+			index = 0
+			if !sleep(ctx, time.Duration(p.RefreshInterval)) {
+				return
+			}
+			continue
+		}
+		if newIndex != index {
+			notify()
+		}
+		switch {
+		case newIndex == 0:
+			// 不支持阻塞查询时退化为轮询
+			if !sleep(ctx, time.Duration(p.RefreshInterval)) {
+				return
+			}
+		case newIndex < index:
+			index = 0
+		default:
+			index = newIndex
+		}
+	}
+}
+
+// buildConfiguration 带有tag的服务中健康的实例, 没有健康实例的服务不生成路由
+func (p *Provider) buildConfiguration(ctx context.Context) (*dynamic.Configuration, error) {
+	slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+	services, _, err := p.client.services(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	configuration := emptyConfiguration()
+	for name, tags := range services {
+		if !contains(tags, p.Tag) {
+			continue
+		}
+		entries, err := p.client.healthyInstances(ctx, name, p.Tag)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Service.ID < entries[j].Service.ID })
+
+		servers := make([]dynamic.Server, 0, len(entries))
+		for _, e := range entries {
+			servers = append(servers, p.toServer(e))
+		}
+		labels := label.FromTags(entries[0].Service.Tags)
+		route, err := label.ServiceRoute(name, labels, servers)
+		if err != nil {
+			slog.WithError(err).Warn("Skip service")
+			continue
+		}
+		configuration.EasyServiceRoute.Services[name] = map[string]*dynamic.ServiceRoute{
+			label.RouteName(name, labels): route,
+		}
+	}
+	return configuration, nil
+}
+
+// toServer 权重优先取meta中的配置, 其次是consul的Weights.Passing
+func (p *Provider) toServer(e serviceEntry) dynamic.Server {
+	host := e.Service.Address
+	if host == "" {
+		host = e.Node.Address
+	}
+	weight := e.Service.Weights.Passing
+	if weight <= 0 {
+		weight = 1
+	}
+	return dynamic.Server{
+		Host:    host,
+		Port:    uint64(e.Service.Port),
+		Weight:  label.ParseWeight(e.Service.Meta[p.WeightMetaKey], weight),
+		Healthy: true,
+	}
+}
+
+func emptyConfiguration() *dynamic.Configuration {
+	return &dynamic.Configuration{
+		EasyServiceRoute: &dynamic.ServiceRouteConfiguration{
+			Services: make(map[string]map[string]*dynamic.ServiceRoute),
+		},
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
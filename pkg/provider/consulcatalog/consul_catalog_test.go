@@ -0,0 +1,225 @@
+package consulcatalog
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/helper/parser"
+	"go-faster-gateway/pkg/provider"
+	"go-faster-gateway/pkg/provider/file"
+	"go-faster-gateway/pkg/safe"
+)
+
+// fakeConsul 模拟consul的catalog和health接口, index变化前阻塞查询会一直等待
+type fakeConsul struct {
+	mu       sync.Mutex
+	index    uint64
+	changed  chan struct{}
+	services map[string][]string
+	entries  map[string][]serviceEntry
+}
+
+func newFakeConsul() *fakeConsul {
+	return &fakeConsul{
+		index:    1,
+		changed:  make(chan struct{}),
+		services: make(map[string][]string),
+		entries:  make(map[string][]serviceEntry),
+	}
+}
+
+func (f *fakeConsul) set(service string, tags []string, entries []serviceEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.services[service] = tags
+	f.entries[service] = entries
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Consul-Token") != "secret" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	index, changed := f.index, f.changed
+	f.mu.Unlock()
+	if wait, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); wait >= index {
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+	var body interface{}
+	switch path := r.URL.Path; {
+	case path == "/v1/catalog/services":
+		body = f.services
+	case path == "/v1/health/state/any":
+		body = []interface{}{}
+	case len(path) > len("/v1/health/service/"):
+		if r.URL.Query().Get("passing") != "true" || r.URL.Query().Get("tag") != "gateway" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = f.entries[path[len("/v1/health/service/"):]]
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func entry(id, nodeAddress, address string, port int, tags []string, meta map[string]string) serviceEntry {
+	var e serviceEntry
+	e.Node.Node = "node-" + id
+	e.Node.Address = nodeAddress
+	e.Service.ID = id
+	e.Service.Address = address
+	e.Service.Port = port
+	e.Service.Tags = tags
+	e.Service.Meta = meta
+	e.Service.Weights.Passing = 1
+	return e
+}
+
+func TestProvider(t *testing.T) {
+	consul := newFakeConsul()
+	tags := []string{"gateway", "gateway.path=/order/*filepath", "gateway.type=wildcard", "gateway.methods=get,post"}
+	consul.set("order", tags, []serviceEntry{
+		entry("order-2", "10.0.0.2", "", 8080, tags, map[string]string{"weight": "5"}),
+		entry("order-1", "10.0.0.1", "192.168.0.1", 8080, tags, nil),
+	})
+	consul.set("internal", []string{"private"}, []serviceEntry{
+		entry("internal-1", "10.0.0.3", "", 9000, nil, nil),
+	})
+	server := httptest.NewServer(consul)
+	defer server.Close()
+
+	p := &Provider{
+		Endpoint:        &EndpointConfig{Address: server.URL, Token: "secret", EndpointWaitTime: parser.Duration(time.Second)},
+		RefreshInterval: parser.Duration(100 * time.Millisecond),
+	}
+	if err := p.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if conf, _ := p.GetConfig(); conf.Configuration != nil {
+		t.Fatal("GetConfig should return nil before the first configuration is built")
+	}
+
+	ch := make(chan dynamic.Message, 10)
+	pool := safe.NewPool(context.Background())
+	defer pool.Stop()
+	if err := p.Provide(ch, pool); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := receive(t, ch)
+	services := msg.Configuration.EasyServiceRoute.Services
+	if msg.ProviderName != providerName || len(services) != 1 {
+		t.Fatalf("only tagged services should be exposed, got %+v", services)
+	}
+	route := services["order"]["order_http"]
+	if route == nil || len(route.Routers) != 1 || route.Routers[0].Path != "/order/*filepath" {
+		t.Fatalf("unexpected route %+v", route)
+	}
+	if methods := route.Routers[0].Methods; len(methods) != 2 || methods[0] != "GET" {
+		t.Errorf("unexpected methods %v", methods)
+	}
+	want := []dynamic.Server{
+		{Host: "192.168.0.1", Port: 8080, Weight: 1, Healthy: true},
+		{Host: "10.0.0.2", Port: 8080, Weight: 5, Healthy: true},
+	}
+	if len(route.Servers) != len(want) || route.Servers[0] != want[0] || route.Servers[1] != want[1] {
+		t.Errorf("unexpected servers %+v", route.Servers)
+	}
+
+	// 实例变为不健康后不再出现在结果中, 没有健康实例的服务整体移除
+	consul.set("order", tags, nil)
+	msg = receive(t, ch)
+	if len(msg.Configuration.EasyServiceRoute.Services) != 0 {
+		t.Errorf("service without healthy instances should be removed, got %+v", msg.Configuration.EasyServiceRoute.Services)
+	}
+	if conf, _ := p.GetConfig(); conf.Configuration != msg.Configuration {
+		t.Error("GetConfig should return the last published configuration")
+	}
+}
+
+func receive(t *testing.T, ch <-chan dynamic.Message) dynamic.Message {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no configuration published")
+	}
+	return dynamic.Message{}
+}
+
+func TestWithFileProvider(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "dynamic.yml")
+	if err := os.WriteFile(filename, []byte("easyServiceRoute:\n  services:\n    static:\n      static_http:\n        handler: http\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fileProvider := &file.Provider{Filename: filename}
+	consul := newFakeConsul()
+	tags := []string{"gateway", "gateway.path=/order"}
+	consul.set("order", tags, []serviceEntry{entry("order-1", "10.0.0.1", "", 8080, tags, nil)})
+	server := httptest.NewServer(consul)
+	defer server.Close()
+	p := &Provider{
+		Endpoint:        &EndpointConfig{Address: server.URL, Token: "secret", EndpointWaitTime: parser.Duration(time.Second)},
+		RefreshInterval: parser.Duration(100 * time.Millisecond),
+	}
+	if err := p.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次生成配置之前不影响文件中的路由
+	if services := mergeWithFile(t, fileProvider, p); len(services) != 1 || services["static"] == nil {
+		t.Fatalf("file routes lost before consul is loaded: %+v", services)
+	}
+	ch := make(chan dynamic.Message, 10)
+	pool := safe.NewPool(context.Background())
+	defer pool.Stop()
+	if err := p.Provide(ch, pool); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, ch)
+	if services := mergeWithFile(t, fileProvider, p); len(services) != 2 || services["static"] == nil || services["order"] == nil {
+		t.Errorf("unexpected merged services %+v", services)
+	}
+}
+
+// mergeWithFile 和aggregator一样合并文件和consul provider的配置
+func mergeWithFile(t *testing.T, providers ...provider.Provider) map[string]map[string]*dynamic.ServiceRoute {
+	t.Helper()
+	configs := make(dynamic.Configurations)
+	for _, prd := range providers {
+		msg, err := prd.GetConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		configs[msg.ProviderName] = msg.Configuration
+	}
+	conf, conflicts := dynamic.MergeConfigurations(configs)
+	if len(conflicts) > 0 {
+		t.Errorf("unexpected conflicts %v", conflicts)
+	}
+	return conf.EasyServiceRoute.Services
+}
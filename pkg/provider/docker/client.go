@@ -0,0 +1,144 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// client docker engine api的最小实现, 只包含容器列表和事件流.
+// 请求不带api版本前缀, 由docker使用当前支持的版本处理
+type client struct {
+	baseURL string
+	http    *http.Client
+}
+
+type container struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Labels map[string]string `json:"Labels"`
+	State  string            `json:"State"`
+	Status string            `json:"Status"`
+	Ports  []struct {
+		PrivatePort uint16 `json:"PrivatePort"`
+		Type        string `json:"Type"`
+	} `json:"Ports"`
+	HostConfig struct {
+		NetworkMode string `json:"NetworkMode"`
+	} `json:"HostConfig"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+type event struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID string `json:"ID"`
+	} `json:"Actor"`
+}
+
+// newClient endpoint支持 unix:///var/run/docker.sock 和 tcp://host:port
+func newClient(endpoint string) (*client, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker endpoint %q: %w", endpoint, err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	c := &client{http: &http.Client{Transport: transport}}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		dialer := &net.Dialer{Timeout: 5 * time.Second}
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		c.baseURL = "http://docker"
+	case "tcp", "http":
+		c.baseURL = "http://" + u.Host
+	default:
+		return nil, fmt.Errorf("unsupported docker endpoint scheme %q", u.Scheme)
+	}
+	return c, nil
+}
+
+// containers 运行中的容器
+func (c *client) containers(ctx context.Context) ([]container, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	resp, err := c.get(ctx, "/containers/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var list []container
+	if err = json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("docker request /containers/json: %w", err)
+	}
+	return list, nil
+}
+
+// events 订阅容器事件, 返回时订阅已经生效. 事件流结束或者出错时关闭返回的channel, 错误写入errc
+func (c *client) events(ctx context.Context, actions []string) (<-chan event, <-chan error, error) {
+	filters, _ := json.Marshal(map[string][]string{"type": {"container"}, "event": actions})
+	resp, err := c.get(ctx, "/events", url.Values{"filters": {string(filters)}})
+	if err != nil {
+		return nil, nil, err
+	}
+	events := make(chan event)
+	errc := make(chan error, 1)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+		decoder := json.NewDecoder(bufio.NewReader(resp.Body))
+		for {
+			var e event
+			if err := decoder.Decode(&e); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				errc <- fmt.Errorf("docker events: %w", err)
+				return
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return events, errc, nil
+}
+
+func (c *client) get(ctx context.Context, path string, q url.Values) (*http.Response, error) {
+	u := c.baseURL + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker request %s: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("docker request %s: unexpected status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
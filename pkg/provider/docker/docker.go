@@ -0,0 +1,260 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/log"
+	"go-faster-gateway/pkg/provider"
+	"go-faster-gateway/pkg/provider/label"
+	"go-faster-gateway/pkg/safe"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+)
+
+const (
+	providerName = "docker"
+	// composeService docker compose给容器加上的服务名标签
+	composeService = "com.docker.compose.service"
+)
+
+// watchedActions 会影响路由和结点的容器事件
+var watchedActions = []string{"start", "die", "destroy", "pause", "unpause", "health_status"}
+
+var _ provider.Provider = (*Provider)(nil)
+
+// Provider holds configurations of the provider.
+type Provider struct {
+	Endpoint         string `description:"Docker server endpoint. Can be a tcp or a unix socket endpoint." json:"endpoint,omitempty" toml:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	ExposedByDefault bool   `description:"Expose containers by default." json:"exposedByDefault,omitempty" toml:"exposedByDefault,omitempty" yaml:"exposedByDefault,omitempty" export:"true"`
+	Network          string `description:"Default Docker network used." json:"network,omitempty" toml:"network,omitempty" yaml:"network,omitempty" export:"true"`
+
+	client        *client
+	mu            sync.Mutex
+	configuration *dynamic.Configuration
+}
+
+// SetDefaults sets the default values.
+func (p *Provider) SetDefaults() {
+	if p.Endpoint == "" {
+		p.Endpoint = "unix:///var/run/docker.sock"
+	}
+}
+
+// Init the provider.
+func (p *Provider) Init() error {
+	p.SetDefaults()
+	c, err := newClient(p.Endpoint)
+	if err != nil {
+		return err
+	}
+	p.client = c
+	return nil
+}
+
+// Provide 先订阅容器事件再读取容器列表, 之后每个事件都重新生成配置. 事件流断开时按退避策略重新订阅
+func (p *Provider) Provide(configurationChan chan<- dynamic.Message, pool *safe.Pool) error {
+	slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+	pool.GoCtx(func(ctx context.Context) {
+		operation := func() error {
+			return p.watch(ctx, configurationChan)
+		}
+		notify := func(err error, d time.Duration) {
+			slog.WithError(err).Errorf("Provider connection error, retrying in %s", d)
+		}
+		// watch会长时间阻塞, 不限制总的重试时间, 否则运行超过MaxElapsedTime后的第一次断开就不再重连
+		bo := backoff.NewExponentialBackOff()
+		bo.MaxElapsedTime = 0
+		err := backoff.RetryNotify(safe.OperationWithRecover(operation), backoff.WithContext(bo, ctx), notify)
+		if err != nil && ctx.Err() == nil {
+			slog.WithError(err).Error("Cannot connect to docker server")
+		}
+	})
+	return nil
+}
+
+// GetConfig 返回最近一次生成的配置, 第一次读取容器之前返回nil, 不参与配置合并
+func (p *Provider) GetConfig() (dynamic.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return dynamic.Message{
+		ProviderName:  providerName,
+		Configuration: p.configuration,
+	}, nil
+}
+
+func (p *Provider) watch(ctx context.Context, configurationChan chan<- dynamic.Message) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, errc, err := p.client.events(ctx, watchedActions)
+	if err != nil {
+		return err
+	}
+	if err = p.refresh(ctx, configurationChan); err != nil {
+		return err
+	}
+	for range events {
+		if err = p.refresh(ctx, configurationChan); err != nil {
+			return err
+		}
+	}
+	if err = <-errc; ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func (p *Provider) refresh(ctx context.Context, configurationChan chan<- dynamic.Message) error {
+	containers, err := p.client.containers(ctx)
+	if err != nil {
+		return err
+	}
+	configuration := p.buildConfiguration(containers)
+	p.mu.Lock()
+	unchanged := reflect.DeepEqual(p.configuration, configuration)
+	p.configuration = configuration
+	p.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+	configurationChan <- dynamic.Message{
+		ProviderName:  providerName,
+		Configuration: configuration,
+	}
+	return nil
+}
+
+// buildConfiguration 同一个服务名的容器合并成一个服务, 路由使用按名称排序后第一个容器的标签
+func (p *Provider) buildConfiguration(containers []container) *dynamic.Configuration {
+	slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+	sort.Slice(containers, func(i, j int) bool { return containerName(containers[i]) < containerName(containers[j]) })
+
+	var names []string
+	servers := make(map[string][]dynamic.Server)
+	labels := make(map[string]map[string]string)
+	for _, c := range containers {
+		if c.State != "running" {
+			continue
+		}
+		gatewayLabels := make(map[string]string)
+		for k, v := range c.Labels {
+			if strings.HasPrefix(k, label.Prefix) {
+				gatewayLabels[k] = v
+			}
+		}
+		// 配置了路由标签的容器默认暴露, 可以用 gateway.enable=false 关闭
+		if !label.IsEnabled(gatewayLabels, p.ExposedByDefault || len(gatewayLabels) > 0) {
+			continue
+		}
+		server, err := p.toServer(c, gatewayLabels)
+		if err != nil {
+			slog.WithError(err).Warnf("Skip container %s", containerName(c))
+			continue
+		}
+		service := serviceName(c)
+		if _, ok := labels[service]; !ok {
+			names = append(names, service)
+			labels[service] = gatewayLabels
+		}
+		servers[service] = append(servers[service], server)
+	}
+
+	configuration := emptyConfiguration()
+	for _, service := range names {
+		route, err := label.ServiceRoute(service, labels[service], servers[service])
+		if err != nil {
+			slog.WithError(err).Warn("Skip service")
+			continue
+		}
+		configuration.EasyServiceRoute.Services[service] = map[string]*dynamic.ServiceRoute{
+			label.RouteName(service, labels[service]): route,
+		}
+	}
+	return configuration
+}
+
+// toServer 端口优先取 gateway.port 标签, 其次是容器暴露的最小tcp端口
+func (p *Provider) toServer(c container, labels map[string]string) (dynamic.Server, error) {
+	host, err := p.address(c)
+	if err != nil {
+		return dynamic.Server{}, err
+	}
+	var port uint64
+	if v, ok := labels[label.Port]; ok {
+		if port, err = strconv.ParseUint(strings.TrimSpace(v), 10, 16); err != nil || port == 0 {
+			return dynamic.Server{}, fmt.Errorf("invalid port label %q", v)
+		}
+	} else {
+		for _, cp := range c.Ports {
+			if cp.Type == "tcp" && cp.PrivatePort > 0 && (port == 0 || uint64(cp.PrivatePort) < port) {
+				port = uint64(cp.PrivatePort)
+			}
+		}
+		if port == 0 {
+			return dynamic.Server{}, fmt.Errorf("no exposed tcp port, set the %s label", label.Port)
+		}
+	}
+	// 配置了健康检查的容器只有检查通过后才接收流量
+	healthy := !strings.Contains(c.Status, "(unhealthy)") && !strings.Contains(c.Status, "(health: starting)")
+	return dynamic.Server{
+		Host:    host,
+		Port:    port,
+		Weight:  label.ParseWeight(labels[label.Weight], 1),
+		Healthy: healthy,
+	}, nil
+}
+
+// address 容器的ip, 连接多个网络时优先使用配置的网络, 否则使用按名称排序的第一个
+func (p *Provider) address(c container) (string, error) {
+	if c.HostConfig.NetworkMode == "host" {
+		return "127.0.0.1", nil
+	}
+	if p.Network != "" {
+		if n, ok := c.NetworkSettings.Networks[p.Network]; ok && n.IPAddress != "" {
+			return n.IPAddress, nil
+		}
+	}
+	networks := make([]string, 0, len(c.NetworkSettings.Networks))
+	for name := range c.NetworkSettings.Networks {
+		networks = append(networks, name)
+	}
+	sort.Strings(networks)
+	for _, name := range networks {
+		if ip := c.NetworkSettings.Networks[name].IPAddress; ip != "" {
+			return ip, nil
+		}
+	}
+	return "", fmt.Errorf("container has no ip address")
+}
+
+// serviceName 服务名依次取 gateway.service 标签, docker compose的服务名, 容器名
+func serviceName(c container) string {
+	if name := c.Labels[label.Service]; name != "" {
+		return name
+	}
+	if name := c.Labels[composeService]; name != "" {
+		return name
+	}
+	return containerName(c)
+}
+
+func containerName(c container) string {
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	return c.ID
+}
+
+func emptyConfiguration() *dynamic.Configuration {
+	return &dynamic.Configuration{
+		EasyServiceRoute: &dynamic.ServiceRouteConfiguration{
+			Services: make(map[string]map[string]*dynamic.ServiceRoute),
+		},
+	}
+}
@@ -0,0 +1,193 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/provider"
+	"go-faster-gateway/pkg/provider/file"
+	"go-faster-gateway/pkg/safe"
+)
+
+// fakeDocker 模拟docker的容器列表和事件流接口
+type fakeDocker struct {
+	mu         sync.Mutex
+	containers []container
+	events     chan event
+}
+
+func (f *fakeDocker) set(containers ...container) {
+	f.mu.Lock()
+	f.containers = containers
+	f.mu.Unlock()
+	f.events <- event{Type: "container", Action: "start"}
+}
+
+func (f *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/containers/json":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(f.containers)
+	case "/events":
+		var filters map[string][]string
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters); err != nil || filters["type"][0] != "container" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case e := <-f.events:
+				_ = json.NewEncoder(w).Encode(e)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newContainer(name, ip, status string, labels map[string]string, ports ...uint16) container {
+	c := container{ID: name + "-id", Names: []string{"/" + name}, Labels: labels, State: "running", Status: status}
+	for _, port := range ports {
+		c.Ports = append(c.Ports, struct {
+			PrivatePort uint16 `json:"PrivatePort"`
+			Type        string `json:"Type"`
+		}{port, "tcp"})
+	}
+	c.NetworkSettings.Networks = map[string]struct {
+		IPAddress string `json:"IPAddress"`
+	}{"bridge": {IPAddress: "172.17.0.1"}, "backend": {IPAddress: ip}}
+	return c
+}
+
+func TestProvider(t *testing.T) {
+	// unix socket的路径长度有限制, 不使用t.TempDir
+	dir, err := os.MkdirTemp("", "docker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docker := &fakeDocker{events: make(chan event)}
+	apiLabels := map[string]string{
+		"gateway.route.path":         "/api/*filepath",
+		"gateway.balance":            "roundRobin",
+		"com.docker.compose.service": "api",
+	}
+	docker.containers = []container{
+		newContainer("api-2", "10.0.0.2", "Up 1 minute (healthy)", apiLabels, 8080, 9090),
+		newContainer("api-1", "10.0.0.1", "Up 1 minute (health: starting)", apiLabels, 8080),
+		newContainer("db", "10.0.0.3", "Up 1 minute", nil, 5432),
+		newContainer("admin", "10.0.0.4", "Up 1 minute", map[string]string{"gateway.port": "3000"}),
+	}
+	server := &http.Server{Handler: docker}
+	go server.Serve(listener)
+	defer server.Close()
+
+	p := &Provider{Endpoint: "unix://" + socket, Network: "backend"}
+	if err = p.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// 第一次读取容器之前不影响文件中的路由
+	fileProvider := staticFileProvider(t)
+	if services := mergeWithFile(t, fileProvider, p); len(services) != 1 || services["static"] == nil {
+		t.Fatalf("file routes lost before docker is loaded: %+v", services)
+	}
+	ch := make(chan dynamic.Message, 10)
+	pool := safe.NewPool(context.Background())
+	defer pool.Stop()
+	if err = p.Provide(ch, pool); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := receive(t, ch)
+	services := msg.Configuration.EasyServiceRoute.Services
+	if len(services) != 2 {
+		t.Fatalf("containers without labels should not be exposed, got %+v", services)
+	}
+	if merged := mergeWithFile(t, fileProvider, p); len(merged) != 3 || merged["static"] == nil {
+		t.Errorf("unexpected merged services %+v", merged)
+	}
+	route := services["api"]["api_http"]
+	if route == nil || route.BalanceMode != "roundRobin" || route.Routers[0].Path != "/api/*filepath" || route.Routers[0].Type != "wildcard" {
+		t.Fatalf("unexpected route %+v", route)
+	}
+	want := []dynamic.Server{
+		{Host: "10.0.0.1", Port: 8080, Weight: 1, Healthy: false},
+		{Host: "10.0.0.2", Port: 8080, Weight: 1, Healthy: true},
+	}
+	if len(route.Servers) != 2 || route.Servers[0] != want[0] || route.Servers[1] != want[1] {
+		t.Errorf("unexpected servers %+v", route.Servers)
+	}
+	admin := services["admin"]["admin_http"]
+	if admin == nil || admin.Routers[0].Path != "/admin/*filepath" || admin.Servers[0].Port != 3000 {
+		t.Errorf("unexpected admin route %+v", admin)
+	}
+
+	// 容器停止后收到事件, 重新生成配置
+	docker.set(newContainer("api-2", "10.0.0.2", "Up 1 minute", apiLabels, 8080))
+	msg = receive(t, ch)
+	route = msg.Configuration.EasyServiceRoute.Services["api"]["api_http"]
+	if len(msg.Configuration.EasyServiceRoute.Services) != 1 || len(route.Servers) != 1 || route.Servers[0].Host != "10.0.0.2" {
+		t.Errorf("stopped containers should be removed, got %+v", msg.Configuration.EasyServiceRoute.Services)
+	}
+	if conf, _ := p.GetConfig(); conf.Configuration != msg.Configuration {
+		t.Error("GetConfig should return the last published configuration")
+	}
+}
+
+func receive(t *testing.T, ch <-chan dynamic.Message) dynamic.Message {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no configuration published")
+	}
+	return dynamic.Message{}
+}
+
+// staticFileProvider 文件provider, 只有一个static服务
+func staticFileProvider(t *testing.T) *file.Provider {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "dynamic.yml")
+	if err := os.WriteFile(filename, []byte("easyServiceRoute:\n  services:\n    static:\n      static_http:\n        handler: http\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &file.Provider{Filename: filename}
+}
+
+// mergeWithFile 和aggregator一样合并文件和docker provider的配置
+func mergeWithFile(t *testing.T, providers ...provider.Provider) map[string]map[string]*dynamic.ServiceRoute {
+	t.Helper()
+	configs := make(dynamic.Configurations)
+	for _, prd := range providers {
+		msg, err := prd.GetConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		configs[msg.ProviderName] = msg.Configuration
+	}
+	conf, conflicts := dynamic.MergeConfigurations(configs)
+	if len(conflicts) > 0 {
+		t.Errorf("unexpected conflicts %v", conflicts)
+	}
+	return conf.EasyServiceRoute.Services
+}
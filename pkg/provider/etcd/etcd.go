@@ -0,0 +1,178 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/helper/parser"
+	"go-faster-gateway/pkg/log"
+	"go-faster-gateway/pkg/provider"
+	"go-faster-gateway/pkg/safe"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+)
+
+const providerName = "etcd"
+
+var _ provider.Provider = (*Provider)(nil)
+
+// Provider holds configurations of the provider.
+type Provider struct {
+	Endpoints   []string           `description:"KV store endpoints." json:"endpoints,omitempty" toml:"endpoints,omitempty" yaml:"endpoints,omitempty" export:"true"`
+	RootKey     string             `description:"Root key used for KV store." json:"rootKey,omitempty" toml:"rootKey,omitempty" yaml:"rootKey,omitempty" export:"true"`
+	Username    string             `description:"KV Username" json:"username,omitempty" toml:"username,omitempty" yaml:"username,omitempty" loggable:"false"`
+	Password    string             `description:"KV Password" json:"password,omitempty" toml:"password,omitempty" yaml:"password,omitempty" loggable:"false"`
+	TLS         *dynamic.ClientTLS `description:"Enable TLS support." json:"tls,omitempty" toml:"tls,omitempty" yaml:"tls,omitempty" export:"true"`
+	DialTimeout parser.Duration    `description:"Timeout used to connect to etcd." json:"dialTimeout,omitempty" toml:"dialTimeout,omitempty" yaml:"dialTimeout,omitempty" export:"true"`
+
+	client        *clientv3.Client
+	mu            sync.Mutex
+	configuration *dynamic.Configuration
+}
+
+// SetDefaults sets the default values.
+func (p *Provider) SetDefaults() {
+	if len(p.Endpoints) == 0 {
+		p.Endpoints = []string{"127.0.0.1:2379"}
+	}
+	if p.RootKey == "" {
+		p.RootKey = "/gateway"
+	}
+	if p.DialTimeout <= 0 {
+		p.DialTimeout = parser.Duration(5 * time.Second)
+	}
+}
+
+// Init the provider.
+func (p *Provider) Init() error {
+	p.SetDefaults()
+	tlsConfig, err := p.TLS.CreateTLSConfig()
+	if err != nil {
+		return fmt.Errorf("unable to create client TLS configuration: %w", err)
+	}
+	// 客户端不会在创建时阻塞等待连接, etcd不可用时由Provide中的重试处理
+	p.client, err = clientv3.New(clientv3.Config{
+		Endpoints:   p.Endpoints,
+		DialTimeout: time.Duration(p.DialTimeout),
+		Username:    p.Username,
+		Password:    p.Password,
+		TLS:         tlsConfig,
+		Logger:      zap.NewNop(),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create etcd client: %w", err)
+	}
+	return nil
+}
+
+// Provide 读取rootKey下的全部键值后从对应的revision开始watch, 连接断开或者revision被压缩时按退避策略重新读取
+func (p *Provider) Provide(configurationChan chan<- dynamic.Message, pool *safe.Pool) error {
+	slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+	pool.GoCtx(func(ctx context.Context) {
+		defer p.client.Close()
+		operation := func() error {
+			return p.watch(ctx, configurationChan)
+		}
+		notify := func(err error, d time.Duration) {
+			slog.WithError(err).Errorf("Provider connection error, retrying in %s", d)
+		}
+		// watch会长时间阻塞, 不限制总的重试时间, 否则运行超过MaxElapsedTime后的第一次断开就不再重连
+		bo := backoff.NewExponentialBackOff()
+		bo.MaxElapsedTime = 0
+		err := backoff.RetryNotify(safe.OperationWithRecover(operation), backoff.WithContext(bo, ctx), notify)
+		if err != nil && ctx.Err() == nil {
+			slog.WithError(err).Error("Cannot connect to etcd")
+		}
+	})
+	return nil
+}
+
+// GetConfig 返回最近一次生成的配置, 第一次读取etcd之前返回nil, 不参与配置合并
+func (p *Provider) GetConfig() (dynamic.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return dynamic.Message{
+		ProviderName:  providerName,
+		Configuration: p.configuration,
+	}, nil
+}
+
+// watch 一次完整的 读取-监听 过程. 同一个watch响应中的事件属于同一个或连续的事务,
+// 全部应用后才生成配置, 保证发布的是某个revision下完整的快照
+func (p *Provider) watch(ctx context.Context, configurationChan chan<- dynamic.Message) error {
+	prefix := strings.TrimSuffix(p.RootKey, "/") + "/"
+	resp, err := p.client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", prefix, err)
+	}
+	pairs := make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		pairs[string(kv.Key)] = string(kv.Value)
+	}
+	revision := resp.Header.Revision
+	p.publish(pairs, revision, configurationChan)
+
+	watchCtx, cancel := context.WithCancel(clientv3.WithRequireLeader(ctx))
+	defer cancel()
+	watchChan := p.client.Watch(watchCtx, prefix, clientv3.WithPrefix(), clientv3.WithRev(revision+1))
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case wresp, ok := <-watchChan:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return errors.New("watch channel closed")
+			}
+			if err = wresp.Err(); err != nil {
+				return fmt.Errorf("watch %s: %w", prefix, err)
+			}
+			if len(wresp.Events) == 0 || wresp.Header.Revision <= revision {
+				continue
+			}
+			for _, ev := range wresp.Events {
+				if ev.Kv.ModRevision <= revision {
+					continue
+				}
+				switch ev.Type {
+				case clientv3.EventTypePut:
+					pairs[string(ev.Kv.Key)] = string(ev.Kv.Value)
+				case clientv3.EventTypeDelete:
+					delete(pairs, string(ev.Kv.Key))
+				}
+			}
+			revision = wresp.Header.Revision
+			p.publish(pairs, revision, configurationChan)
+		}
+	}
+}
+
+// publish 生成配置, 解析失败时保留上一次的配置
+func (p *Provider) publish(pairs map[string]string, revision int64, configurationChan chan<- dynamic.Message) {
+	slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+	configuration, err := decodeConfiguration(p.RootKey, pairs)
+	if err != nil {
+		slog.WithError(err).Errorf("Invalid configuration at revision %d", revision)
+		return
+	}
+	p.mu.Lock()
+	unchanged := reflect.DeepEqual(p.configuration, configuration)
+	p.configuration = configuration
+	p.mu.Unlock()
+	if unchanged {
+		return
+	}
+	configurationChan <- dynamic.Message{
+		ProviderName:  providerName,
+		Configuration: configuration,
+	}
+}
@@ -0,0 +1,204 @@
+package etcd
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/provider"
+	"go-faster-gateway/pkg/provider/file"
+	"go-faster-gateway/pkg/safe"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+func freeURL(t *testing.T) url.URL {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return url.URL{Scheme: "http", Host: l.Addr().String()}
+}
+
+func startEtcd(t *testing.T) *embed.Etcd {
+	t.Helper()
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.LogLevel = "error"
+	clientURL, peerURL := freeURL(t), freeURL(t)
+	cfg.ListenClientUrls, cfg.AdvertiseClientUrls = []url.URL{clientURL}, []url.URL{clientURL}
+	cfg.ListenPeerUrls, cfg.AdvertisePeerUrls = []url.URL{peerURL}, []url.URL{peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("etcd server took too long to start")
+	}
+	return e
+}
+
+func TestProvider(t *testing.T) {
+	e := startEtcd(t)
+	endpoint := e.Clients[0].Addr().String()
+	cli, err := clientv3.New(clientv3.Config{Endpoints: []string{endpoint}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	ctx := context.Background()
+	if _, err = cli.Put(ctx, "/gateway/services/order/order_http/handler", "http"); err != nil {
+		t.Fatal(err)
+	}
+	// 前缀相同但不在rootKey下的键不会被读取
+	if _, err = cli.Put(ctx, "/gatewayfoo/services/other/other_http/handler", "http"); err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Provider{Endpoints: []string{endpoint}}
+	if err = p.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// 第一次读取etcd之前不影响文件中的路由
+	fileProvider := staticFileProvider(t)
+	if services := mergeWithFile(t, fileProvider, p); len(services) != 1 || services["static"] == nil {
+		t.Fatalf("file routes lost before etcd is loaded: %+v", services)
+	}
+	ch := make(chan dynamic.Message, 10)
+	pool := safe.NewPool(context.Background())
+	defer pool.Stop()
+	if err = p.Provide(ch, pool); err != nil {
+		t.Fatal(err)
+	}
+	msg := receive(t, ch)
+	if services := msg.Configuration.EasyServiceRoute.Services; len(services) != 1 || services["order"]["order_http"] == nil {
+		t.Fatalf("unexpected services %+v", services)
+	}
+	if services := mergeWithFile(t, fileProvider, p); len(services) != 2 || services["static"] == nil || services["order"] == nil {
+		t.Errorf("unexpected merged services %+v", services)
+	}
+
+	// 事务中的多个键作为一个快照发布
+	_, err = cli.Txn(ctx).Then(
+		clientv3.OpPut("/gateway/services/order/order_http/routers/0/path", "/order"),
+		clientv3.OpPut("/gateway/services/order/order_http/servers/0/host", "10.0.0.1"),
+		clientv3.OpPut("/gateway/services/order/order_http/servers/0/port", "8080"),
+		clientv3.OpPut("/gateway/services/order/order_http/servers/1/host", "10.0.0.2"),
+		clientv3.OpPut("/gateway/services/order/order_http/servers/1/port", "8080"),
+	).Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg = receive(t, ch)
+	route := msg.Configuration.EasyServiceRoute.Services["order"]["order_http"]
+	if len(route.Routers) != 1 || len(route.Servers) != 2 || route.Servers[1].Port != 8080 {
+		t.Fatalf("transaction should be published as one snapshot, got %+v", route)
+	}
+
+	if _, err = cli.Delete(ctx, "/gateway/services/order", clientv3.WithPrefix()); err != nil {
+		t.Fatal(err)
+	}
+	msg = receive(t, ch)
+	if len(msg.Configuration.EasyServiceRoute.Services) != 0 {
+		t.Errorf("deleted service should be removed, got %+v", msg.Configuration.EasyServiceRoute.Services)
+	}
+	if conf, _ := p.GetConfig(); conf.Configuration != msg.Configuration {
+		t.Error("GetConfig should return the last published configuration")
+	}
+}
+
+func TestDecodeConfiguration(t *testing.T) {
+	conf, err := decodeConfiguration("/gateway/", map[string]string{
+		"/gateway/globalMiddleware/1":                              "auth",
+		"/gateway/globalMiddleware/0":                              "requestid",
+		"/gateway/balanceMode/balance":                             "round_robin",
+		"/gateway/services/User/User_http/handler":                 "http",
+		"/gateway/services/User/User_http/routers/0/methods":       "GET,POST",
+		"/gateway/services/User/User_http/routers/0/params/id":     `\d+`,
+		"/gateway/services/User/User_http/servers/10/host":         "10.0.0.2",
+		"/gateway/services/User/User_http/servers/2/host":          "10.0.0.1",
+		"/gateway/services/User/User_http/servers/2/weight":        "3",
+		"/gateway/services/User/User_http/servers/2/healthy":       "true",
+		"/gateway/services/User/User_http/middlewares/0":           "auth",
+		"/gateway/services/User/User_http/serviceName":             "",
+		"/gateway/middlewares/auth/forwardAuth/address":            "http://auth",
+		"/gateway/middlewares/auth/forwardAuth/trustForwardHeader": "true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.GlobalMiddleware) != 2 || conf.GlobalMiddleware[0] != "requestid" || conf.BalanceMode.Balance != "round_robin" {
+		t.Errorf("unexpected global settings %+v", conf)
+	}
+	route := conf.EasyServiceRoute.Services["User"]["User_http"]
+	if route == nil {
+		t.Fatalf("service names should keep their case, got %+v", conf.EasyServiceRoute.Services)
+	}
+	if len(route.Routers[0].Methods) != 2 || route.Routers[0].Params["id"] != `\d+` {
+		t.Errorf("unexpected router %+v", route.Routers[0])
+	}
+	if len(route.Servers) != 2 || route.Servers[0].Host != "10.0.0.1" || route.Servers[0].Weight != 3 || !route.Servers[0].Healthy {
+		t.Errorf("servers should be ordered by index, got %+v", route.Servers)
+	}
+	if conf.Middlewares["auth"] == nil || conf.Middlewares["auth"].ForwardAuth == nil {
+		t.Errorf("unexpected middlewares %+v", conf.Middlewares)
+	}
+
+	if _, err = decodeConfiguration("/gateway", map[string]string{
+		"/gateway/services/order":                    "x",
+		"/gateway/services/order/order_http/handler": "http",
+	}); err == nil {
+		t.Error("expected error for a key that is both a value and a directory")
+	}
+}
+
+func receive(t *testing.T, ch <-chan dynamic.Message) dynamic.Message {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(10 * time.Second):
+		t.Fatal("no configuration published")
+	}
+	return dynamic.Message{}
+}
+
+// staticFileProvider 文件provider, 只有一个static服务
+func staticFileProvider(t *testing.T) *file.Provider {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "dynamic.yml")
+	if err := os.WriteFile(filename, []byte("easyServiceRoute:\n  services:\n    static:\n      static_http:\n        handler: http\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &file.Provider{Filename: filename}
+}
+
+// mergeWithFile 和aggregator一样合并文件和etcd provider的配置
+func mergeWithFile(t *testing.T, providers ...provider.Provider) map[string]map[string]*dynamic.ServiceRoute {
+	t.Helper()
+	configs := make(dynamic.Configurations)
+	for _, prd := range providers {
+		msg, err := prd.GetConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		configs[msg.ProviderName] = msg.Configuration
+	}
+	conf, conflicts := dynamic.MergeConfigurations(configs)
+	if len(conflicts) > 0 {
+		t.Errorf("unexpected conflicts %v", conflicts)
+	}
+	return conf.EasyServiceRoute.Services
+}
@@ -0,0 +1,127 @@
+package etcd
+
+import (
+	"fmt"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/helper/utils"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// decodeConfiguration 把rootKey下的键值还原成dynamic.Configuration.
+// 键按/分隔为层级, 全部为数字的层级表示数组下标, 比如
+//
+//	/gateway/services/order/order_http/handler = http
+//	/gateway/services/order/order_http/routers/0/path = /order
+//	/gateway/services/order/order_http/servers/0/host = 10.0.0.1
+//	/gateway/globalMiddleware/0 = requestid
+//
+// services 对应文件配置中的 easyServiceRoute.services
+func decodeConfiguration(rootKey string, pairs map[string]string) (*dynamic.Configuration, error) {
+	tree := make(map[string]interface{})
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if pairs[key] == "" {
+			continue
+		}
+		parts := splitKey(rootKey, key)
+		if len(parts) == 0 {
+			continue
+		}
+		if err := insert(tree, parts, pairs[key]); err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+	}
+	if services, ok := tree["services"]; ok {
+		delete(tree, "services")
+		tree["easyServiceRoute"] = map[string]interface{}{"services": services}
+	}
+
+	c := &dynamic.Configuration{
+		EasyServiceRoute: &dynamic.ServiceRouteConfiguration{
+			Services: make(map[string]map[string]*dynamic.ServiceRoute),
+		},
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       utils.DecodeHook,
+		WeaklyTypedInput: true,
+		Result:           c,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = decoder.Decode(toSlices(tree)); err != nil {
+		return nil, err
+	}
+	if c.EasyServiceRoute.Services == nil {
+		c.EasyServiceRoute.Services = make(map[string]map[string]*dynamic.ServiceRoute)
+	}
+	return c, nil
+}
+
+func splitKey(rootKey, key string) []string {
+	key = strings.TrimPrefix(key, strings.TrimSuffix(rootKey, "/"))
+	var parts []string
+	for _, part := range strings.Split(key, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func insert(tree map[string]interface{}, parts []string, value string) error {
+	for i, part := range parts[:len(parts)-1] {
+		switch child := tree[part].(type) {
+		case nil:
+			next := make(map[string]interface{})
+			tree[part] = next
+			tree = next
+		case map[string]interface{}:
+			tree = child
+		default:
+			return fmt.Errorf("%s already has a value", strings.Join(parts[:i+1], "/"))
+		}
+	}
+	last := parts[len(parts)-1]
+	if _, ok := tree[last].(map[string]interface{}); ok {
+		return fmt.Errorf("%s has children", strings.Join(parts, "/"))
+	}
+	tree[last] = value
+	return nil
+}
+
+// toSlices 下标全部为数字的层级转换成按下标排序的数组
+func toSlices(node interface{}) interface{} {
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return node
+	}
+	type element struct {
+		index int
+		key   string
+	}
+	elements := make([]element, 0, len(m))
+	for key, child := range m {
+		m[key] = toSlices(child)
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 {
+			elements = append(elements, element{index: i, key: key})
+		}
+	}
+	if len(m) == 0 || len(elements) != len(m) {
+		return m
+	}
+	sort.Slice(elements, func(i, j int) bool { return elements[i].index < elements[j].index })
+	list := make([]interface{}, 0, len(elements))
+	for _, e := range elements {
+		list = append(list, m[e.key])
+	}
+	return list
+}
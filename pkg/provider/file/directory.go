@@ -0,0 +1,176 @@
+package file
+
+import (
+	"fmt"
+	"go-faster-gateway/pkg/config/dynamic"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// configExtensions 目录模式下会加载的文件类型
+var configExtensions = map[string]bool{
+	".yml":  true,
+	".yaml": true,
+	".toml": true,
+	".json": true,
+}
+
+func isConfigFile(name string) bool {
+	return configExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// listDirectories 需要监听的目录, 递归模式下包含所有子目录
+func (p *Provider) listDirectories() ([]string, error) {
+	if !p.Recursive {
+		return []string{p.Directory}, nil
+	}
+	var dirs []string
+	err := filepath.WalkDir(p.Directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+// listConfigFiles 目录下的配置文件, 按文件名排序保证合并结果稳定
+func (p *Provider) listConfigFiles(directory string) ([]string, error) {
+	var files []string
+	if !p.Recursive {
+		entries, err := os.ReadDir(directory)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && isConfigFile(e.Name()) {
+				files = append(files, filepath.Join(directory, e.Name()))
+			}
+		}
+		return files, nil
+	}
+	err := filepath.WalkDir(directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && isConfigFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// loadDirectoryConfig 加载目录下所有配置文件并合并, 同名的服务或中间件出现在多个文件中时报错
+func (p *Provider) loadDirectoryConfig(directory string) (*dynamic.Configuration, error) {
+	files, err := p.listConfigFiles(directory)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read directory %s: %w", directory, err)
+	}
+	m := newConfigurationMerger()
+	for _, filename := range files {
+		c, err := p.loadFileConfig(filename)
+		if err != nil {
+			return nil, err
+		}
+		if err = m.merge(filename, c); err != nil {
+			return nil, err
+		}
+	}
+	return m.configuration, nil
+}
+
+// configurationMerger 合并多个文件的配置, 记录每一项来自哪个文件以便报告冲突
+type configurationMerger struct {
+	configuration   *dynamic.Configuration
+	serviceFiles    map[string]string
+	middlewareFiles map[string]string
+	transportFiles  map[string]string
+	balanceModeFile string
+	databasesFile   string
+}
+
+func newConfigurationMerger() *configurationMerger {
+	return &configurationMerger{
+		configuration: &dynamic.Configuration{
+			EasyServiceRoute: &dynamic.ServiceRouteConfiguration{
+				Services: make(map[string]map[string]*dynamic.ServiceRoute),
+			},
+		},
+		serviceFiles:    make(map[string]string),
+		middlewareFiles: make(map[string]string),
+		transportFiles:  make(map[string]string),
+	}
+}
+
+func (m *configurationMerger) merge(filename string, c *dynamic.Configuration) error {
+	if c.EasyServiceRoute != nil {
+		for name, routes := range c.EasyServiceRoute.Services {
+			if other, ok := m.serviceFiles[name]; ok {
+				return fmt.Errorf("service %q is defined in both %s and %s", name, other, filename)
+			}
+			m.serviceFiles[name] = filename
+			m.configuration.EasyServiceRoute.Services[name] = routes
+		}
+	}
+
+	for name, mw := range c.Middlewares {
+		if other, ok := m.middlewareFiles[name]; ok {
+			return fmt.Errorf("middleware %q is defined in both %s and %s", name, other, filename)
+		}
+		m.middlewareFiles[name] = filename
+		if m.configuration.Middlewares == nil {
+			m.configuration.Middlewares = make(map[string]*dynamic.Middleware)
+		}
+		m.configuration.Middlewares[name] = mw
+	}
+
+	for name, transport := range c.ServersTransports {
+		if other, ok := m.transportFiles[name]; ok {
+			return fmt.Errorf("serversTransport %q is defined in both %s and %s", name, other, filename)
+		}
+		m.transportFiles[name] = filename
+		if m.configuration.ServersTransports == nil {
+			m.configuration.ServersTransports = make(map[string]*dynamic.ServersTransport)
+		}
+		m.configuration.ServersTransports[name] = transport
+	}
+
+	for _, name := range c.GlobalMiddleware {
+		if !contains(m.configuration.GlobalMiddleware, name) {
+			m.configuration.GlobalMiddleware = append(m.configuration.GlobalMiddleware, name)
+		}
+	}
+
+	if c.BalanceMode.Balance != "" {
+		if m.balanceModeFile != "" && m.configuration.BalanceMode != c.BalanceMode {
+			return fmt.Errorf("balanceMode is defined differently in both %s and %s", m.balanceModeFile, filename)
+		}
+		m.balanceModeFile = filename
+		m.configuration.BalanceMode = c.BalanceMode
+	}
+
+	if c.Databases != nil {
+		if m.databasesFile != "" && !reflect.DeepEqual(m.configuration.Databases, c.Databases) {
+			return fmt.Errorf("databases is defined differently in both %s and %s", m.databasesFile, filename)
+		}
+		m.databasesFile = filename
+		m.configuration.Databases = c.Databases
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/safe"
+)
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "order.yml"), `
+globalMiddleware: [requestid]
+easyServiceRoute:
+  services:
+    order:
+      order_http:
+        handler: http
+        routers:
+          - path: /order
+`)
+	writeFile(t, filepath.Join(dir, "user.json"), `{"globalMiddleware":["requestid"],"easyServiceRoute":{"services":{"user":{"user_http":{"handler":"http"}}}}}`)
+	writeFile(t, filepath.Join(dir, "README.md"), "not a config")
+	writeFile(t, filepath.Join(dir, "team", "pay.toml"), `
+[easyServiceRoute.services.pay.pay_http]
+handler = "http"
+`)
+
+	conf, err := (&Provider{Directory: dir}).buildConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	services := conf.EasyServiceRoute.Services
+	if len(services) != 2 || services["order"] == nil || services["user"] == nil {
+		t.Errorf("unexpected services %+v", services)
+	}
+	if len(conf.GlobalMiddleware) != 1 {
+		t.Errorf("global middlewares should be merged without duplicates, got %v", conf.GlobalMiddleware)
+	}
+
+	conf, err = (&Provider{Directory: dir, Recursive: true}).buildConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.EasyServiceRoute.Services["pay"] == nil {
+		t.Errorf("recursive mode should load sub directories, got %+v", conf.EasyServiceRoute.Services)
+	}
+
+	conflict := filepath.Join(dir, "team", "order.yaml")
+	writeFile(t, conflict, `
+easyServiceRoute:
+  services:
+    order:
+      order_ws:
+        handler: websocket
+`)
+	_, err = (&Provider{Directory: dir, Recursive: true}).buildConfiguration()
+	if err == nil || !strings.Contains(err.Error(), conflict) || !strings.Contains(err.Error(), "order.yml") {
+		t.Errorf("expected conflict error naming both files, got %v", err)
+	}
+}
+
+func TestDirectoryWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "order.yml"), `
+easyServiceRoute:
+  services:
+    order:
+      order_http:
+        handler: http
+`)
+	p := &Provider{Directory: dir, Recursive: true, Watch: true}
+	ch := make(chan dynamic.Message, 10)
+	pool := safe.NewPool(context.Background())
+	defer pool.Stop()
+	if err := p.Provide(ch, pool); err != nil {
+		t.Fatal(err)
+	}
+	<-ch
+
+	writeFile(t, filepath.Join(dir, "team", "user.yml"), `
+easyServiceRoute:
+  services:
+    user:
+      user_http:
+        handler: http
+`)
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-ch:
+			if msg.Configuration.EasyServiceRoute.Services["user"] != nil {
+				return
+			}
+		case <-timeout:
+			t.Fatal("file created in a new sub directory was not picked up")
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package file
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -13,9 +14,11 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
 )
 
 const providerName = "file"
@@ -24,8 +27,11 @@ var _ provider.Provider = (*Provider)(nil)
 
 // Provider holds configurations of the provider.
 type Provider struct {
-	Watch    bool   `description:"Watch provider." json:"watch,omitempty" toml:"watch,omitempty" yaml:"watch,omitempty" export:"true"`
-	Filename string `description:"Load dynamic configuration from a file." json:"filename,omitempty" toml:"filename,omitempty" yaml:"filename,omitempty" export:"true"`
+	Directory string `description:"Load dynamic configuration from one or more .yml, .yaml, .toml or .json files in a directory." json:"directory,omitempty" toml:"directory,omitempty" yaml:"directory,omitempty" export:"true"`
+	Recursive bool   `description:"Also load the files in the sub directories of the directory." json:"recursive,omitempty" toml:"recursive,omitempty" yaml:"recursive,omitempty" export:"true"`
+	Watch     bool   `description:"Watch provider." json:"watch,omitempty" toml:"watch,omitempty" yaml:"watch,omitempty" export:"true"`
+	Filename  string `description:"Load dynamic configuration from a file." json:"filename,omitempty" toml:"filename,omitempty" yaml:"filename,omitempty" export:"true"`
+	Template  bool   `description:"Render the files as Go templates with sprig functions before parsing them." json:"template,omitempty" toml:"template,omitempty" yaml:"template,omitempty" export:"true"`
 }
 
 // SetDefaults sets the default values.
@@ -48,6 +54,12 @@ func (p *Provider) Provide(configurationChan chan<- dynamic.Message, pool *safe.
 		var watchItems []string
 
 		switch {
+		case len(p.Directory) > 0:
+			dirs, err := p.listDirectories()
+			if err != nil {
+				return err
+			}
+			watchItems = append(watchItems, dirs...)
 		case len(p.Filename) > 0:
 			watchItems = append(watchItems, filepath.Dir(p.Filename), p.Filename)
 		default:
@@ -92,7 +104,7 @@ func (p *Provider) Provide(configurationChan chan<- dynamic.Message, pool *safe.
 func (p *Provider) GetConfig() (dynamic.Message, error) {
 	configuration, err := p.buildConfiguration()
 	return dynamic.Message{
-		ProviderName:  "file",
+		ProviderName:  providerName,
 		Configuration: configuration,
 	}, err
 }
@@ -120,7 +132,25 @@ func (p *Provider) addWatcher(pool *safe.Pool, items []string, configurationChan
 			case <-ctx.Done():
 				return
 			case evt := <-watcher.Events:
-				if len(p.Filename) > 0 {
+				if len(p.Directory) > 0 {
+					// 递归模式下新建的子目录也需要监听, 监听之前可能已经写入了文件, 所以同时重新加载一次
+					newDir := false
+					if p.Recursive && evt.Has(fsnotify.Create) {
+						if fi, err := os.Stat(evt.Name); err == nil && fi.IsDir() {
+							newDir = true
+							if err = watcher.Add(evt.Name); err != nil {
+								slog.WithError(err).Errorf("Error adding file watcher on %s", evt.Name)
+							}
+						}
+					}
+					if !newDir && !isConfigFile(evt.Name) && !evt.Has(fsnotify.Remove) && !evt.Has(fsnotify.Rename) {
+						continue
+					}
+					err := callback(configurationChan)
+					if err != nil {
+						slog.WithError(err).Error("Error occurred during watcher callback")
+					}
+				} else if len(p.Filename) > 0 {
 					_, evtFileName := filepath.Split(evt.Name)
 					_, confFileName := filepath.Split(p.Filename)
 					if evtFileName == confFileName {
@@ -153,18 +183,21 @@ func (p *Provider) applyConfiguration(configurationChan chan<- dynamic.Message)
 	return nil
 }
 
-// buildConfiguration loads configuration either from file
-// specified by 'Filename' and returns a 'Configuration' object.
+// buildConfiguration loads configuration either from file or directory
+// specified by 'Filename'/'Directory' and returns a 'Configuration' object.
 func (p *Provider) buildConfiguration() (*dynamic.Configuration, error) {
+	if len(p.Directory) > 0 {
+		return p.loadDirectoryConfig(p.Directory)
+	}
 	if len(p.Filename) > 0 {
 		return p.loadFileConfig(p.Filename)
 	}
-	return nil, errors.New("error using file configuration provider, neither filename is not defined")
+	return nil, errors.New("error using file configuration provider, neither filename nor directory is defined")
 }
 
 func sendConfigToChannel(configurationChan chan<- dynamic.Message, configuration *dynamic.Configuration) {
 	configurationChan <- dynamic.Message{
-		ProviderName:  "file",
+		ProviderName:  providerName,
 		Configuration: configuration,
 	}
 }
@@ -175,6 +208,22 @@ func (p *Provider) loadFileConfig(filename string) (*dynamic.Configuration, erro
 			Services: make(map[string]map[string]*dynamic.ServiceRoute),
 		},
 	}
-	err := utils.GetFile(filename, c)
-	return c, err
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return c, err
+	}
+	if p.Template {
+		if content, err = renderTemplate(filename, content); err != nil {
+			return c, err
+		}
+	}
+	v := viper.New()
+	v.SetConfigType(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if err = v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return c, fmt.Errorf("%s: %w", filename, err)
+	}
+	if err = v.Unmarshal(c, utils.ViperDecodeHook); err != nil {
+		return c, fmt.Errorf("%s: %w", filename, err)
+	}
+	return c, nil
 }
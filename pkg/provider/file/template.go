@@ -0,0 +1,36 @@
+package file
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+)
+
+// renderTemplate 把配置文件当作go模板渲染, 支持sprig的函数(env, default, until等)和requiredEnv.
+// 模板以文件名命名, 解析和执行的错误信息中都会带上文件名和行号
+func renderTemplate(filename string, content []byte) ([]byte, error) {
+	tmpl, err := template.New(filename).
+		Funcs(sprig.TxtFuncMap()).
+		Funcs(template.FuncMap{"requiredEnv": requiredEnv}).
+		Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, nil); err != nil {
+		return nil, fmt.Errorf("error rendering template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// requiredEnv 环境变量不存在时渲染失败, 避免生成空的host等配置
+func requiredEnv(name string) (string, error) {
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return v, nil
+}
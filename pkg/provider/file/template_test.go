@@ -0,0 +1,53 @@
+package file
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	t.Setenv("ORDER_HOST", "10.0.0.9")
+	filename := filepath.Join(t.TempDir(), "routes.yml")
+	writeFile(t, filename, `
+easyServiceRoute:
+  services:
+{{- range $i := until 3 }}
+    svc{{ $i }}:
+      svc{{ $i }}_http:
+        handler: http
+        routers:
+          - path: /svc{{ $i }}
+        servers:
+          - host: {{ requiredEnv "ORDER_HOST" }}
+            port: {{ add 8080 $i }}
+{{- end }}
+`)
+	conf, err := (&Provider{Filename: filename, Template: true}).buildConfiguration()
+	if err != nil {
+		t.Fatal(err)
+	}
+	services := conf.EasyServiceRoute.Services
+	if len(services) != 3 {
+		t.Fatalf("expected 3 generated services, got %+v", services)
+	}
+	for i := 0; i < 3; i++ {
+		route := services[fmt.Sprintf("svc%d", i)][fmt.Sprintf("svc%d_http", i)]
+		if route == nil || route.Servers[0].Host != "10.0.0.9" || route.Servers[0].Port != uint64(8080+i) {
+			t.Errorf("unexpected route %d: %+v", i, route)
+		}
+	}
+
+	// 没有开启模板时原样解析
+	if _, err = (&Provider{Filename: filename}).buildConfiguration(); err == nil {
+		t.Error("expected yaml error for an unrendered template")
+	}
+
+	broken := filepath.Join(t.TempDir(), "broken.yml")
+	writeFile(t, broken, "easyServiceRoute:\n  services:\n    {{ requiredEnv \"GATEWAY_MISSING_ENV\" }}: {}\n")
+	_, err = (&Provider{Filename: broken, Template: true}).buildConfiguration()
+	if err == nil || !strings.Contains(err.Error(), broken+":3:") {
+		t.Errorf("expected error with file name and line number, got %v", err)
+	}
+}
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/helper/parser"
+	"go-faster-gateway/pkg/log"
+	"go-faster-gateway/pkg/provider"
+	"go-faster-gateway/pkg/safe"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+	"gopkg.in/yaml.v3"
+)
+
+const providerName = "http"
+
+var _ provider.Provider = (*Provider)(nil)
+
+// Provider holds configurations of the provider.
+type Provider struct {
+	Endpoint     string             `description:"Load dynamic configuration from this URL." json:"endpoint,omitempty" toml:"endpoint,omitempty" yaml:"endpoint,omitempty" export:"true"`
+	PollInterval parser.Duration    `description:"Polling interval for endpoint." json:"pollInterval,omitempty" toml:"pollInterval,omitempty" yaml:"pollInterval,omitempty" export:"true"`
+	PollTimeout  parser.Duration    `description:"Polling timeout for endpoint." json:"pollTimeout,omitempty" toml:"pollTimeout,omitempty" yaml:"pollTimeout,omitempty" export:"true"`
+	Token        string             `description:"Bearer token sent in the Authorization header." json:"token,omitempty" toml:"token,omitempty" yaml:"token,omitempty" loggable:"false"`
+	TLS          *dynamic.ClientTLS `description:"Enable TLS support." json:"tls,omitempty" toml:"tls,omitempty" yaml:"tls,omitempty" export:"true"`
+
+	client        *fasthttp.Client
+	mu            sync.Mutex
+	etag          string
+	configuration *dynamic.Configuration
+}
+
+// SetDefaults sets the default values.
+func (p *Provider) SetDefaults() {
+	if p.PollInterval <= 0 {
+		p.PollInterval = parser.Duration(5 * time.Second)
+	}
+	if p.PollTimeout <= 0 {
+		p.PollTimeout = parser.Duration(5 * time.Second)
+	}
+}
+
+// Init the provider.
+func (p *Provider) Init() error {
+	if p.Endpoint == "" {
+		return errors.New("error using http configuration provider, endpoint is not defined")
+	}
+	p.SetDefaults()
+	tlsConfig, err := p.TLS.CreateTLSConfig()
+	if err != nil {
+		return fmt.Errorf("unable to create client TLS configuration: %w", err)
+	}
+	p.client = &fasthttp.Client{
+		TLSConfig:                tlsConfig,
+		NoDefaultUserAgentHeader: true,
+	}
+	return nil
+}
+
+// Provide 先拉取一次配置, 之后按pollInterval轮询, 服务端返回304时跳过
+func (p *Provider) Provide(configurationChan chan<- dynamic.Message, pool *safe.Pool) error {
+	slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+	if err := p.poll(configurationChan); err != nil {
+		slog.WithError(err).Error("Error while fetching configuration (for the first time)")
+	}
+	pool.GoCtx(func(ctx context.Context) {
+		ticker := time.NewTicker(time.Duration(p.PollInterval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := p.poll(configurationChan); err != nil {
+					slog.WithError(err).Error("Error while fetching configuration")
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// GetConfig 返回最近一次拉取到的配置, 不在这里发起请求, 避免启动时被远端阻塞.
+// 第一次拉取成功之前返回nil, 不参与配置合并
+func (p *Provider) GetConfig() (dynamic.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return dynamic.Message{
+		ProviderName:  providerName,
+		Configuration: p.configuration,
+	}, nil
+}
+
+func (p *Provider) poll(configurationChan chan<- dynamic.Message) error {
+	configuration, changed, err := p.fetch()
+	if err != nil || !changed {
+		return err
+	}
+	configurationChan <- dynamic.Message{
+		ProviderName:  providerName,
+		Configuration: configuration,
+	}
+	return nil
+}
+
+// fetch 带上If-None-Match请求配置, 返回的changed为false表示配置没有变化
+func (p *Provider) fetch() (*dynamic.Configuration, bool, error) {
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+
+	req.SetRequestURI(p.Endpoint)
+	req.Header.SetMethod(fasthttp.MethodGet)
+	req.Header.Set(fasthttp.HeaderAccept, "application/json, application/yaml")
+	if p.Token != "" {
+		req.Header.Set(fasthttp.HeaderAuthorization, "Bearer "+p.Token)
+	}
+	p.mu.Lock()
+	etag := p.etag
+	p.mu.Unlock()
+	if etag != "" {
+		req.Header.Set(fasthttp.HeaderIfNoneMatch, etag)
+	}
+
+	if err := p.client.DoTimeout(req, resp, time.Duration(p.PollTimeout)); err != nil {
+		return nil, false, fmt.Errorf("cannot fetch configuration data: %w", err)
+	}
+	switch resp.StatusCode() {
+	case fasthttp.StatusOK:
+	case fasthttp.StatusNotModified:
+		return nil, false, nil
+	default:
+		return nil, false, fmt.Errorf("received non-ok response code: %d", resp.StatusCode())
+	}
+
+	configuration, err := decodeConfiguration(string(resp.Header.ContentType()), resp.Body())
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot decode configuration data: %w", err)
+	}
+	p.mu.Lock()
+	p.etag = string(resp.Header.Peek(fasthttp.HeaderETag))
+	p.configuration = configuration
+	p.mu.Unlock()
+	return configuration, true, nil
+}
+
+// decodeConfiguration 根据Content-Type解析json或者yaml, 未声明时先按json解析
+func decodeConfiguration(contentType string, body []byte) (*dynamic.Configuration, error) {
+	configuration := new(dynamic.Configuration)
+	var err error
+	switch {
+	case strings.Contains(contentType, "yaml"):
+		err = yaml.Unmarshal(body, configuration)
+	case strings.Contains(contentType, "json"):
+		err = json.Unmarshal(body, configuration)
+	default:
+		if err = json.Unmarshal(body, configuration); err != nil {
+			configuration = new(dynamic.Configuration)
+			err = yaml.Unmarshal(body, configuration)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if configuration.EasyServiceRoute == nil || configuration.EasyServiceRoute.Services == nil {
+		configuration.EasyServiceRoute = &dynamic.ServiceRouteConfiguration{
+			Services: make(map[string]map[string]*dynamic.ServiceRoute),
+		}
+	}
+	return configuration, nil
+}
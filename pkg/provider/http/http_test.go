@@ -0,0 +1,160 @@
+package http
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/provider"
+	"go-faster-gateway/pkg/provider/file"
+	"go-faster-gateway/pkg/safe"
+)
+
+const yamlConfig = `
+easyServiceRoute:
+  services:
+    order:
+      order_http:
+        handler: http
+        routers:
+          - path: /order
+        servers:
+          - host: 10.0.0.1
+            port: 80
+`
+
+func TestProvider(t *testing.T) {
+	var requests, notModified atomic.Int32
+	handler := nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		requests.Add(1)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(nethttp.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(nethttp.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write([]byte(yamlConfig))
+	})
+
+	for name, server := range map[string]*httptest.Server{
+		"http":  httptest.NewServer(handler),
+		"https": httptest.NewTLSServer(handler),
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer server.Close()
+			p := &Provider{Endpoint: server.URL, Token: "secret", TLS: &dynamic.ClientTLS{InsecureSkipVerify: true}}
+			if err := p.Init(); err != nil {
+				t.Fatal(err)
+			}
+			ch := make(chan dynamic.Message, 10)
+			pool := safe.NewPool(context.Background())
+			defer pool.Stop()
+			if err := p.Provide(ch, pool); err != nil {
+				t.Fatal(err)
+			}
+			msg := <-ch
+			route := msg.Configuration.EasyServiceRoute.Services["order"]["order_http"]
+			if msg.ProviderName != providerName || route == nil || len(route.Servers) != 1 {
+				t.Fatalf("unexpected configuration %+v", msg.Configuration.EasyServiceRoute)
+			}
+
+			// 第二次请求带上ETag, 服务端返回304, 不发布配置
+			notModified.Store(0)
+			if err := p.poll(ch); err != nil {
+				t.Fatal(err)
+			}
+			if notModified.Load() != 1 || len(ch) != 0 {
+				t.Errorf("expected a 304 without publishing, notModified=%d queued=%d", notModified.Load(), len(ch))
+			}
+			if conf, _ := p.GetConfig(); conf.Configuration != msg.Configuration {
+				t.Error("GetConfig should return the last fetched configuration")
+			}
+		})
+	}
+
+	p := &Provider{Endpoint: "http://127.0.0.1:1/unreachable"}
+	if err := p.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.fetch(); err == nil {
+		t.Error("expected error for unreachable endpoint")
+	}
+	if err := (&Provider{}).Init(); err == nil {
+		t.Error("expected error without endpoint")
+	}
+}
+
+func TestDecodeConfiguration(t *testing.T) {
+	for _, c := range []struct{ contentType, body string }{
+		{"application/json", `{"easyServiceRoute":{"services":{"order":{"order_http":{"handler":"http"}}}}}`},
+		{"", `{"easyServiceRoute":{"services":{"order":{"order_http":{"handler":"http"}}}}}`},
+		{"text/plain", yamlConfig},
+	} {
+		conf, err := decodeConfiguration(c.contentType, []byte(c.body))
+		if err != nil {
+			t.Fatalf("%q: %v", c.contentType, err)
+		}
+		if conf.EasyServiceRoute.Services["order"]["order_http"] == nil {
+			t.Errorf("%q: route not decoded", c.contentType)
+		}
+	}
+	conf, err := decodeConfiguration("application/json", []byte(`{}`))
+	if err != nil || conf.EasyServiceRoute == nil || conf.EasyServiceRoute.Services == nil {
+		t.Errorf("empty payload should produce empty services, got %+v, %v", conf, err)
+	}
+}
+
+func TestWithFileProvider(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "dynamic.yml")
+	if err := os.WriteFile(filename, []byte("easyServiceRoute:\n  services:\n    static:\n      static_http:\n        handler: http\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fileProvider := &file.Provider{Filename: filename}
+	server := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		_, _ = w.Write([]byte(yamlConfig))
+	}))
+	defer server.Close()
+	p := &Provider{Endpoint: server.URL}
+	if err := p.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次拉取成功之前不影响文件中的路由
+	if services := mergeWithFile(t, fileProvider, p); len(services) != 1 || services["static"] == nil {
+		t.Fatalf("file routes lost before the first fetch: %+v", services)
+	}
+	if err := p.poll(make(chan dynamic.Message, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if services := mergeWithFile(t, fileProvider, p); len(services) != 2 || services["static"] == nil || services["order"] == nil {
+		t.Errorf("unexpected merged services %+v", services)
+	}
+}
+
+// mergeWithFile 和aggregator一样合并文件和http provider的配置
+func mergeWithFile(t *testing.T, providers ...provider.Provider) map[string]map[string]*dynamic.ServiceRoute {
+	t.Helper()
+	configs := make(dynamic.Configurations)
+	for _, prd := range providers {
+		msg, err := prd.GetConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		configs[msg.ProviderName] = msg.Configuration
+	}
+	conf, conflicts := dynamic.MergeConfigurations(configs)
+	if len(conflicts) > 0 {
+		t.Errorf("unexpected conflicts %v", conflicts)
+	}
+	return conf.EasyServiceRoute.Services
+}
@@ -0,0 +1,191 @@
+package kubernetes
+
+import (
+	"fmt"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// builder 把Ingress和HTTPRoute转换成dynamic.Configuration
+type builder struct {
+	lister        *lister
+	configuration *dynamic.Configuration
+	// 已注册的路径和请求方法, 路由按路径匹配, 同一路径重复注册会冲突
+	paths map[string]map[string]bool
+}
+
+// backendPort Service的端口, number和name二选一
+type backendPort struct {
+	number int32
+	name   string
+}
+
+func (p backendPort) String() string {
+	if p.name != "" {
+		return p.name
+	}
+	return strconv.Itoa(int(p.number))
+}
+
+// claim 登记路由的路径, 和已经登记的路径冲突的路由会被去掉.
+// 路由树中通配符不能和同一前缀下的其他路径共存, 比如 /*filepath 和 /login, 注册时会panic
+func (b *builder) claim(owner string, routers []dynamic.Router) []dynamic.Router {
+	claimed := make([]dynamic.Router, 0, len(routers))
+	for _, r := range routers {
+		if other, ok := b.conflict(r); ok {
+			log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName}).
+				Warnf("%s: path %s %v conflicts with %s of another route, skipped", owner, r.Path, r.Methods, other)
+			continue
+		}
+		methods := b.paths[r.Path]
+		if methods == nil {
+			methods = make(map[string]bool)
+			b.paths[r.Path] = methods
+		}
+		for _, m := range r.Methods {
+			methods[m] = true
+		}
+		claimed = append(claimed, r)
+	}
+	return claimed
+}
+
+func (b *builder) conflict(r dynamic.Router) (string, bool) {
+	for path, methods := range b.paths {
+		if !overlaps(methods, r.Methods) {
+			continue
+		}
+		if path == r.Path || coveredByCatchAll(path, r.Path) || coveredByCatchAll(r.Path, path) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// coveredByCatchAll catchAll为通配符路径时, path是否在其前缀下
+func coveredByCatchAll(catchAll, path string) bool {
+	i := strings.Index(catchAll, "*")
+	return i >= 0 && strings.HasPrefix(path, catchAll[:i])
+}
+
+func overlaps(registered map[string]bool, methods []string) bool {
+	if registered["*"] && len(methods) > 0 {
+		return true
+	}
+	for _, m := range methods {
+		if m == "*" || registered[m] {
+			return true
+		}
+	}
+	return false
+}
+
+// addRoute 把路由加入配置, 所有路径都冲突时不添加
+func (b *builder) addRoute(service, name string, route *dynamic.ServiceRoute) {
+	route.Routers = b.claim(name, route.Routers)
+	if len(route.Routers) == 0 {
+		return
+	}
+	routes := b.configuration.EasyServiceRoute.Services[service]
+	if routes == nil {
+		routes = make(map[string]*dynamic.ServiceRoute)
+		b.configuration.EasyServiceRoute.Services[service] = routes
+	}
+	routes[name] = route
+}
+
+func (b *builder) addMiddleware(name string, middleware *dynamic.Middleware) {
+	if b.configuration.Middlewares == nil {
+		b.configuration.Middlewares = make(map[string]*dynamic.Middleware)
+	}
+	b.configuration.Middlewares[name] = middleware
+}
+
+// pathRouters 前缀匹配转换成精确路径加通配符两条路由, /的前缀匹配只需要通配符.
+// 路径中的:和*在路由树中有特殊含义, 包含它们的路径不支持
+func pathRouters(path string, exact bool, methods []string) []dynamic.Router {
+	if path == "" {
+		path = "/"
+	}
+	if strings.ContainsAny(path, ":*") {
+		log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName}).
+			Warnf("path %s contains : or *, skipped", path)
+		return nil
+	}
+	if len(methods) == 0 {
+		methods = []string{"*"}
+	}
+	if exact {
+		return []dynamic.Router{{Path: path, Methods: methods}}
+	}
+	prefix := strings.TrimSuffix(path, "/")
+	routers := []dynamic.Router{{Path: prefix + "/*filepath", Type: "wildcard", Methods: methods}}
+	if prefix != "" {
+		routers = append([]dynamic.Router{{Path: prefix, Methods: methods}}, routers...)
+	}
+	return routers
+}
+
+// servers Service端口对应的pod地址, 通过EndpointSlice中同名的端口找到容器端口
+func (b *builder) servers(namespace, name string, port backendPort) ([]dynamic.Server, error) {
+	svc, err := b.lister.service(namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("service %s/%s: %w", namespace, name, err)
+	}
+	var svcPort *corev1.ServicePort
+	for i, sp := range svc.Spec.Ports {
+		if (port.name != "" && sp.Name == port.name) || (port.name == "" && sp.Port == port.number) {
+			svcPort = &svc.Spec.Ports[i]
+			break
+		}
+	}
+	if svcPort == nil {
+		return nil, fmt.Errorf("service %s/%s has no port %s", namespace, name, port)
+	}
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		return []dynamic.Server{{Host: svc.Spec.ExternalName, Port: uint64(svcPort.Port), Weight: 1, Healthy: true}}, nil
+	}
+
+	slices, err := b.lister.endpointSlices(namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("service %s/%s: %w", namespace, name, err)
+	}
+	seen := make(map[string]bool)
+	var servers []dynamic.Server
+	for _, slice := range slices {
+		var targetPort int32
+		for _, p := range slice.Ports {
+			if p.Port != nil && ((p.Name == nil && svcPort.Name == "") || (p.Name != nil && *p.Name == svcPort.Name)) {
+				targetPort = *p.Port
+				break
+			}
+		}
+		if targetPort == 0 {
+			continue
+		}
+		for _, ep := range slice.Endpoints {
+			ready := ep.Conditions.Ready == nil || *ep.Conditions.Ready
+			for _, addr := range ep.Addresses {
+				key := net.JoinHostPort(addr, strconv.Itoa(int(targetPort)))
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				servers = append(servers, dynamic.Server{Host: addr, Port: uint64(targetPort), Weight: 1, Healthy: ready})
+			}
+		}
+	}
+	sort.Slice(servers, func(i, j int) bool {
+		if servers[i].Host != servers[j].Host {
+			return servers[i].Host < servers[j].Host
+		}
+		return servers[i].Port < servers[j].Port
+	})
+	return servers, nil
+}
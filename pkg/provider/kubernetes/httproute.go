@@ -0,0 +1,179 @@
+package kubernetes
+
+import (
+	"fmt"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/log"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// loadHTTPRoutes HTTPRoute的每条rule生成一个服务, rule中的每个match生成一个路由.
+// 路由目前只按路径和请求方法匹配, hostnames, header和query的匹配条件不支持
+func (b *builder) loadHTTPRoutes(routes []*gatewayv1.HTTPRoute, selector labels.Selector) {
+	slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+	for _, hr := range routes {
+		if !selector.Matches(labels.Set(hr.Labels)) {
+			continue
+		}
+		for i, rule := range hr.Spec.Rules {
+			service := fmt.Sprintf("httproute-%s-%s-%d", hr.Namespace, hr.Name, i)
+			servers := b.backendServers(hr, rule.BackendRefs)
+			middlewares, rewrite := b.filterMiddlewares(service, rule.Filters)
+
+			matches := rule.Matches
+			if len(matches) == 0 {
+				matches = []gatewayv1.HTTPRouteMatch{{}}
+			}
+			for j, match := range matches {
+				routeName := fmt.Sprintf("%s-%d", service, j)
+				if len(match.Headers) > 0 || len(match.QueryParams) > 0 {
+					slog.Warnf("HTTPRoute %s/%s: header and query param matches are not supported, match %s skipped", hr.Namespace, hr.Name, routeName)
+					continue
+				}
+				path, exact, ok := matchPath(match.Path)
+				if !ok {
+					slog.Warnf("HTTPRoute %s/%s: only Exact and PathPrefix path matches are supported, match %s skipped", hr.Namespace, hr.Name, routeName)
+					continue
+				}
+				var methods []string
+				if match.Method != nil {
+					methods = []string{string(*match.Method)}
+				}
+				routeMiddlewares := middlewares
+				if rewrite != nil {
+					name := routeName + "-urlrewrite"
+					b.addMiddleware(name, &dynamic.Middleware{URLRewrite: urlRewrite(rewrite, path)})
+					routeMiddlewares = append(append([]string{}, middlewares...), name)
+				}
+				b.addRoute(service, routeName, &dynamic.ServiceRoute{
+					ServiceName: service,
+					Handler:     "http",
+					Routers:     pathRouters(path, exact, methods),
+					Servers:     servers,
+					Middlewares: routeMiddlewares,
+				})
+			}
+		}
+	}
+}
+
+// backendServers 合并rule中所有后端的pod, 后端的weight作为其下每个pod的权重
+func (b *builder) backendServers(hr *gatewayv1.HTTPRoute, refs []gatewayv1.HTTPBackendRef) []dynamic.Server {
+	slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+	var servers []dynamic.Server
+	for _, ref := range refs {
+		if (ref.Group != nil && *ref.Group != "") || (ref.Kind != nil && *ref.Kind != "Service") {
+			slog.Warnf("HTTPRoute %s/%s: only Service backends are supported", hr.Namespace, hr.Name)
+			continue
+		}
+		// 跨namespace引用需要ReferenceGrant, 暂不支持
+		if ref.Namespace != nil && string(*ref.Namespace) != hr.Namespace {
+			slog.Warnf("HTTPRoute %s/%s: cross namespace backend %s/%s is not supported", hr.Namespace, hr.Name, *ref.Namespace, ref.Name)
+			continue
+		}
+		if ref.Port == nil {
+			slog.Warnf("HTTPRoute %s/%s: port is required for backend %s", hr.Namespace, hr.Name, ref.Name)
+			continue
+		}
+		weight := 1
+		if ref.Weight != nil {
+			weight = int(*ref.Weight)
+		}
+		if weight <= 0 {
+			continue
+		}
+		backend, err := b.servers(hr.Namespace, string(ref.Name), backendPort{number: int32(*ref.Port)})
+		if err != nil {
+			slog.WithError(err).Warnf("HTTPRoute %s/%s: skip backend", hr.Namespace, hr.Name)
+			continue
+		}
+		for _, s := range backend {
+			s.Weight = weight
+			servers = append(servers, s)
+		}
+	}
+	return servers
+}
+
+// filterMiddlewares 把header相关的filter转换成中间件. URLRewrite的前缀替换依赖匹配到的路径, 由每个路由单独生成
+func (b *builder) filterMiddlewares(service string, filters []gatewayv1.HTTPRouteFilter) ([]string, *gatewayv1.HTTPURLRewriteFilter) {
+	var names []string
+	var rewrite *gatewayv1.HTTPURLRewriteFilter
+	for i, f := range filters {
+		name := fmt.Sprintf("%s-%s-%d", service, strings.ToLower(string(f.Type)), i)
+		switch {
+		case f.Type == gatewayv1.HTTPRouteFilterRequestHeaderModifier && f.RequestHeaderModifier != nil:
+			b.addMiddleware(name, &dynamic.Middleware{RequestHeaderModifier: headerModifier(f.RequestHeaderModifier)})
+			names = append(names, name)
+		case f.Type == gatewayv1.HTTPRouteFilterResponseHeaderModifier && f.ResponseHeaderModifier != nil:
+			b.addMiddleware(name, &dynamic.Middleware{ResponseHeaderModifier: headerModifier(f.ResponseHeaderModifier)})
+			names = append(names, name)
+		case f.Type == gatewayv1.HTTPRouteFilterURLRewrite && f.URLRewrite != nil:
+			rewrite = f.URLRewrite
+		default:
+			log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName}).
+				Warnf("%s: filter %s is not supported", service, f.Type)
+		}
+	}
+	return names, rewrite
+}
+
+func headerModifier(f *gatewayv1.HTTPHeaderFilter) *dynamic.HeaderModifier {
+	m := &dynamic.HeaderModifier{Remove: f.Remove}
+	for _, h := range f.Set {
+		if m.Set == nil {
+			m.Set = make(map[string]string)
+		}
+		m.Set[string(h.Name)] = h.Value
+	}
+	for _, h := range f.Add {
+		if m.Add == nil {
+			m.Add = make(map[string]string)
+		}
+		m.Add[string(h.Name)] = h.Value
+	}
+	return m
+}
+
+// urlRewrite ReplacePrefixMatch需要知道匹配到的前缀
+func urlRewrite(f *gatewayv1.HTTPURLRewriteFilter, matchPath string) *dynamic.URLRewrite {
+	r := &dynamic.URLRewrite{}
+	if f.Hostname != nil {
+		hostname := string(*f.Hostname)
+		r.Hostname = &hostname
+	}
+	if f.Path != nil {
+		switch f.Path.Type {
+		case gatewayv1.FullPathHTTPPathModifier:
+			r.Path = f.Path.ReplaceFullPath
+		case gatewayv1.PrefixMatchHTTPPathModifier:
+			if f.Path.ReplacePrefixMatch != nil {
+				prefix := matchPath
+				r.Path = f.Path.ReplacePrefixMatch
+				r.PathPrefix = &prefix
+			}
+		}
+	}
+	return r
+}
+
+// matchPath 没有配置路径时默认为前缀/
+func matchPath(m *gatewayv1.HTTPPathMatch) (path string, exact bool, ok bool) {
+	if m == nil {
+		return "/", false, true
+	}
+	path = "/"
+	if m.Value != nil {
+		path = *m.Value
+	}
+	if m.Type == nil || *m.Type == gatewayv1.PathMatchPathPrefix {
+		return path, false, true
+	}
+	if *m.Type == gatewayv1.PathMatchExact {
+		return path, true, true
+	}
+	return "", false, false
+}
@@ -0,0 +1,79 @@
+package kubernetes
+
+import (
+	"fmt"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/log"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const annotationIngressClass = "kubernetes.io/ingress.class"
+
+// loadIngresses Ingress的每个后端Service生成一个ServiceRoute.
+// 路由目前只按路径匹配, rule中的host会被忽略
+func (b *builder) loadIngresses(ingresses []*networkingv1.Ingress, selector labels.Selector, ingressClass string) {
+	slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+	for _, ing := range ingresses {
+		if !selector.Matches(labels.Set(ing.Labels)) || !matchIngressClass(ing, ingressClass) {
+			continue
+		}
+		routeName := fmt.Sprintf("ingress-%s-%s", ing.Namespace, ing.Name)
+		// 同一个Ingress中指向同一个后端的路径合并成一个路由
+		var backends []string
+		routes := make(map[string]*dynamic.ServiceRoute)
+		add := func(backend *networkingv1.IngressServiceBackend, routers []dynamic.Router) {
+			port := backendPort{number: backend.Port.Number, name: backend.Port.Name}
+			service := fmt.Sprintf("%s-%s-%s", ing.Namespace, backend.Name, port)
+			if route, ok := routes[service]; ok {
+				route.Routers = append(route.Routers, routers...)
+				return
+			}
+			servers, err := b.servers(ing.Namespace, backend.Name, port)
+			if err != nil {
+				slog.WithError(err).Warnf("Ingress %s/%s: skip backend", ing.Namespace, ing.Name)
+				return
+			}
+			backends = append(backends, service)
+			routes[service] = &dynamic.ServiceRoute{
+				ServiceName: service,
+				Handler:     "http",
+				Routers:     routers,
+				Servers:     servers,
+			}
+		}
+
+		for _, rule := range ing.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, p := range rule.HTTP.Paths {
+				if p.Backend.Service == nil {
+					slog.Warnf("Ingress %s/%s: only service backends are supported", ing.Namespace, ing.Name)
+					continue
+				}
+				exact := p.PathType != nil && *p.PathType == networkingv1.PathTypeExact
+				add(p.Backend.Service, pathRouters(p.Path, exact, nil))
+			}
+		}
+		// defaultBackend 没有匹配到其他路径的请求
+		if ing.Spec.DefaultBackend != nil && ing.Spec.DefaultBackend.Service != nil {
+			add(ing.Spec.DefaultBackend.Service, pathRouters("/", false, nil))
+		}
+
+		for _, service := range backends {
+			b.addRoute(service, routeName, routes[service])
+		}
+	}
+}
+
+func matchIngressClass(ing *networkingv1.Ingress, ingressClass string) bool {
+	if ingressClass == "" {
+		return true
+	}
+	if ing.Spec.IngressClassName != nil {
+		return *ing.Spec.IngressClassName == ingressClass
+	}
+	return ing.Annotations[annotationIngressClass] == ingressClass
+}
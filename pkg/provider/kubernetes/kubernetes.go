@@ -0,0 +1,170 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/helper/parser"
+	"go-faster-gateway/pkg/log"
+	"go-faster-gateway/pkg/provider"
+	"go-faster-gateway/pkg/safe"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	gatewayclient "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
+)
+
+const providerName = "kubernetes"
+
+var _ provider.Provider = (*Provider)(nil)
+
+// Provider holds configurations of the provider.
+type Provider struct {
+	Endpoint         string          `description:"Kubernetes server endpoint (required for external cluster client)." json:"endpoint,omitempty" toml:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	Token            string          `description:"Kubernetes bearer token (not needed for in-cluster client)." json:"token,omitempty" toml:"token,omitempty" yaml:"token,omitempty" loggable:"false"`
+	CertAuthFilePath string          `description:"Kubernetes certificate authority file path (not needed for in-cluster client)." json:"certAuthFilePath,omitempty" toml:"certAuthFilePath,omitempty" yaml:"certAuthFilePath,omitempty"`
+	Namespaces       []string        `description:"Kubernetes namespaces." json:"namespaces,omitempty" toml:"namespaces,omitempty" yaml:"namespaces,omitempty" export:"true"`
+	LabelSelector    string          `description:"Kubernetes label selector to use for Ingress and HTTPRoute." json:"labelSelector,omitempty" toml:"labelSelector,omitempty" yaml:"labelSelector,omitempty" export:"true"`
+	IngressClass     string          `description:"Value of ingressClassName or kubernetes.io/ingress.class annotation to watch for, all Ingresses are watched when empty." json:"ingressClass,omitempty" toml:"ingressClass,omitempty" yaml:"ingressClass,omitempty" export:"true"`
+	GatewayAPI       bool            `description:"Also watch Gateway API HTTPRoute objects." json:"gatewayAPI,omitempty" toml:"gatewayAPI,omitempty" yaml:"gatewayAPI,omitempty" export:"true"`
+	ThrottleDuration parser.Duration `description:"Ingress refresh throttle duration" json:"throttleDuration,omitempty" toml:"throttleDuration,omitempty" yaml:"throttleDuration,omitempty" export:"true"`
+
+	client        kubernetes.Interface
+	gatewayClient gatewayclient.Interface
+	selector      labels.Selector
+	mu            sync.Mutex
+	configuration *dynamic.Configuration
+}
+
+// Init the provider.
+func (p *Provider) Init() error {
+	selector, err := labels.Parse(p.LabelSelector)
+	if err != nil {
+		return fmt.Errorf("invalid label selector %q: %w", p.LabelSelector, err)
+	}
+	p.selector = selector
+	if p.client != nil {
+		return nil
+	}
+	config, err := p.restConfig()
+	if err != nil {
+		return err
+	}
+	if p.client, err = kubernetes.NewForConfig(config); err != nil {
+		return fmt.Errorf("unable to create kubernetes client: %w", err)
+	}
+	if p.GatewayAPI {
+		if p.gatewayClient, err = gatewayclient.NewForConfig(config); err != nil {
+			return fmt.Errorf("unable to create gateway api client: %w", err)
+		}
+	}
+	return nil
+}
+
+// restConfig 集群内运行时使用ServiceAccount, 设置了KUBECONFIG时使用对应的配置文件, 否则使用endpoint和token
+func (p *Provider) restConfig() (*rest.Config, error) {
+	switch {
+	case os.Getenv("KUBERNETES_SERVICE_HOST") != "" && os.Getenv("KUBERNETES_SERVICE_PORT") != "" && p.Endpoint == "":
+		log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName}).Info("Creating in-cluster Provider client")
+		return rest.InClusterConfig()
+	case os.Getenv("KUBECONFIG") != "":
+		return clientcmd.BuildConfigFromFlags(p.Endpoint, os.Getenv("KUBECONFIG"))
+	case p.Endpoint == "":
+		return nil, errors.New("error using kubernetes configuration provider, endpoint is not defined")
+	}
+	config := &rest.Config{
+		Host:        p.Endpoint,
+		BearerToken: p.Token,
+	}
+	if p.CertAuthFilePath != "" {
+		if _, err := os.Stat(p.CertAuthFilePath); err != nil {
+			return nil, fmt.Errorf("failed to read CA file %s: %w", p.CertAuthFilePath, err)
+		}
+		config.TLSClientConfig.CAFile = p.CertAuthFilePath
+	}
+	return config, nil
+}
+
+// Provide 通过informer监听Ingress, HTTPRoute, Service和EndpointSlice, 任意变化时重新生成配置
+func (p *Provider) Provide(configurationChan chan<- dynamic.Message, pool *safe.Pool) error {
+	pool.GoCtx(func(ctx context.Context) {
+		slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+		changes := make(chan struct{}, 1)
+		l, err := newLister(ctx, p.client, p.gatewayClient, p.Namespaces, changes)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.WithError(err).Error("Cannot start kubernetes informers")
+			}
+			return
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changes:
+			}
+			p.publish(p.buildConfiguration(l), configurationChan)
+			if p.ThrottleDuration > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(time.Duration(p.ThrottleDuration)):
+				}
+			}
+		}
+	})
+	return nil
+}
+
+// GetConfig 返回最近一次生成的配置, 第一次生成之前返回nil, 不参与配置合并
+func (p *Provider) GetConfig() (dynamic.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return dynamic.Message{
+		ProviderName:  providerName,
+		Configuration: p.configuration,
+	}, nil
+}
+
+func (p *Provider) publish(configuration *dynamic.Configuration, configurationChan chan<- dynamic.Message) {
+	p.mu.Lock()
+	unchanged := reflect.DeepEqual(p.configuration, configuration)
+	p.configuration = configuration
+	p.mu.Unlock()
+	if unchanged {
+		return
+	}
+	configurationChan <- dynamic.Message{
+		ProviderName:  providerName,
+		Configuration: configuration,
+	}
+}
+
+// buildConfiguration 先处理Ingress再处理HTTPRoute, 同一路径只保留最先出现的路由
+func (p *Provider) buildConfiguration(l *lister) *dynamic.Configuration {
+	b := &builder{
+		lister:        l,
+		configuration: emptyConfiguration(),
+		paths:         make(map[string]map[string]bool),
+	}
+	b.loadIngresses(l.ingresses(), p.selector, p.IngressClass)
+	if p.gatewayClient != nil {
+		b.loadHTTPRoutes(l.httpRoutes(), p.selector)
+	}
+	return b.configuration
+}
+
+func emptyConfiguration() *dynamic.Configuration {
+	return &dynamic.Configuration{
+		EasyServiceRoute: &dynamic.ServiceRouteConfiguration{
+			Services: make(map[string]map[string]*dynamic.ServiceRoute),
+		},
+	}
+}
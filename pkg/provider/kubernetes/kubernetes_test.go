@@ -0,0 +1,299 @@
+package kubernetes
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/provider"
+	"go-faster-gateway/pkg/provider/file"
+	"go-faster-gateway/pkg/safe"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayfake "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned/fake"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func service(name string, ports ...corev1.ServicePort) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       corev1.ServiceSpec{Ports: ports},
+	}
+}
+
+func endpointSlice(svc, portName string, port int32, ready bool, addresses ...string) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      svc + "-abc",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: svc},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Name: ptr(portName), Port: ptr(port)}},
+	}
+	for _, addr := range addresses {
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{addr},
+			Conditions: discoveryv1.EndpointConditions{Ready: ptr(ready)},
+		})
+	}
+	return slice
+}
+
+func TestIngress(t *testing.T) {
+	prefix, exact := networkingv1.PathTypePrefix, networkingv1.PathTypeExact
+	backend := func(name string, port networkingv1.ServiceBackendPort) networkingv1.IngressBackend {
+		return networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: name, Port: port}}
+	}
+	objects := []runtime.Object{
+		service("user", corev1.ServicePort{Name: "http", Port: 80}),
+		endpointSlice("user", "http", 8080, true, "10.1.0.2", "10.1.0.1"),
+		service("order", corev1.ServicePort{Port: 80}),
+		endpointSlice("order", "", 9000, true, "10.2.0.1"),
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: ptr("gateway"),
+				DefaultBackend:   &networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{Name: "order", Port: networkingv1.ServiceBackendPort{Number: 80}}},
+				Rules: []networkingv1.IngressRule{{IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{
+						{Path: "/user/", PathType: &prefix, Backend: backend("user", networkingv1.ServiceBackendPort{Name: "http"})},
+						{Path: "/login", PathType: &exact, Backend: backend("user", networkingv1.ServiceBackendPort{Number: 80})},
+						{Path: "/missing", PathType: &prefix, Backend: backend("missing", networkingv1.ServiceBackendPort{Number: 80})},
+					},
+				}}}},
+			},
+		},
+		// 路径和上面的Ingress冲突, 按名称排序在后面, 被忽略
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "conflict"},
+			Spec: networkingv1.IngressSpec{
+				IngressClassName: ptr("gateway"),
+				Rules: []networkingv1.IngressRule{{IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{Path: "/login", PathType: &exact, Backend: backend("order", networkingv1.ServiceBackendPort{Number: 80})}},
+				}}}},
+			},
+		},
+		&networkingv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other-class", Annotations: map[string]string{annotationIngressClass: "nginx"}},
+			Spec: networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{{IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{Path: "/nginx", PathType: &prefix, Backend: backend("order", networkingv1.ServiceBackendPort{Number: 80})}},
+				}}}},
+			},
+		},
+	}
+	client := fake.NewSimpleClientset(objects...)
+	p := &Provider{IngressClass: "gateway", client: client}
+	// 第一次生成配置之前不影响文件中的路由
+	fileProvider := staticFileProvider(t)
+	if services := mergeWithFile(t, fileProvider, p); len(services) != 1 || services["static"] == nil {
+		t.Fatalf("file routes lost before kubernetes is loaded: %+v", services)
+	}
+	ch := provide(t, p)
+
+	msg := receive(t, ch)
+	services := msg.Configuration.EasyServiceRoute.Services
+	if len(services) != 2 {
+		t.Fatalf("unexpected services %+v", services)
+	}
+	if merged := mergeWithFile(t, fileProvider, p); len(merged) != 3 || merged["static"] == nil {
+		t.Errorf("unexpected merged services %+v", merged)
+	}
+	user := services["default-user-http"]["ingress-default-api"]
+	if user == nil {
+		t.Fatalf("user route missing: %+v", services)
+	}
+	want := []dynamic.Server{
+		{Host: "10.1.0.1", Port: 8080, Weight: 1, Healthy: true},
+		{Host: "10.1.0.2", Port: 8080, Weight: 1, Healthy: true},
+	}
+	if len(user.Servers) != 2 || user.Servers[0] != want[0] || user.Servers[1] != want[1] {
+		t.Errorf("unexpected servers %+v", user.Servers)
+	}
+	if paths := routerPaths(user); len(paths) != 2 || paths[0] != "/user" || paths[1] != "/user/*filepath" {
+		t.Errorf("unexpected user paths %v", paths)
+	}
+	// 通过端口号引用的是另一个服务key, 同一个后端的端口名和端口号分开处理.
+	// defaultBackend的/*filepath和其他路径在路由树中冲突, 被忽略
+	if services["default-user-80"]["ingress-default-api"] == nil || services["default-order-80"] != nil {
+		t.Errorf("conflicting path should keep the first route, got %+v", services)
+	}
+
+	// EndpointSlice变化后重新生成配置
+	slice := endpointSlice("user", "http", 8080, false, "10.1.0.3")
+	if _, err := client.DiscoveryV1().EndpointSlices("default").Update(context.Background(), slice, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	msg = receive(t, ch)
+	servers := msg.Configuration.EasyServiceRoute.Services["default-user-http"]["ingress-default-api"].Servers
+	if len(servers) != 1 || servers[0].Host != "10.1.0.3" || servers[0].Healthy {
+		t.Errorf("endpoint update not applied, got %+v", servers)
+	}
+	if conf, _ := p.GetConfig(); conf.Configuration != msg.Configuration {
+		t.Error("GetConfig should return the last published configuration")
+	}
+}
+
+func TestHTTPRoute(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		service("user", corev1.ServicePort{Name: "http", Port: 80}),
+		endpointSlice("user", "http", 8080, true, "10.1.0.1"),
+		service("user-canary", corev1.ServicePort{Name: "http", Port: 80}),
+		endpointSlice("user-canary", "http", 8080, true, "10.1.1.1"),
+	)
+	route := &gatewayv1.HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "user", Labels: map[string]string{"team": "user"}},
+		Spec: gatewayv1.HTTPRouteSpec{Rules: []gatewayv1.HTTPRouteRule{{
+			Matches: []gatewayv1.HTTPRouteMatch{
+				{Path: &gatewayv1.HTTPPathMatch{Type: ptr(gatewayv1.PathMatchPathPrefix), Value: ptr("/api/user")}, Method: ptr(gatewayv1.HTTPMethodGet)},
+				{Path: &gatewayv1.HTTPPathMatch{Type: ptr(gatewayv1.PathMatchRegularExpression), Value: ptr("/v[0-9]+")}},
+			},
+			Filters: []gatewayv1.HTTPRouteFilter{
+				{Type: gatewayv1.HTTPRouteFilterRequestHeaderModifier, RequestHeaderModifier: &gatewayv1.HTTPHeaderFilter{
+					Set:    []gatewayv1.HTTPHeader{{Name: "X-Env", Value: "prod"}},
+					Remove: []string{"X-Debug"},
+				}},
+				{Type: gatewayv1.HTTPRouteFilterURLRewrite, URLRewrite: &gatewayv1.HTTPURLRewriteFilter{
+					Path: &gatewayv1.HTTPPathModifier{Type: gatewayv1.PrefixMatchHTTPPathModifier, ReplacePrefixMatch: ptr("/user")},
+				}},
+			},
+			BackendRefs: []gatewayv1.HTTPBackendRef{
+				{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: "user", Port: ptr(gatewayv1.PortNumber(80))}, Weight: ptr(int32(9))}},
+				{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: "user-canary", Port: ptr(gatewayv1.PortNumber(80))}, Weight: ptr(int32(1))}},
+			},
+		}}},
+	}
+	excluded := route.DeepCopy()
+	excluded.Name, excluded.Labels = "other", map[string]string{"team": "other"}
+	gatewayClient := gatewayfake.NewSimpleClientset(route, excluded)
+
+	p := &Provider{LabelSelector: "team=user", GatewayAPI: true, client: client, gatewayClient: gatewayClient}
+	ch := provide(t, p)
+	msg := receive(t, ch)
+
+	routes := msg.Configuration.EasyServiceRoute.Services["httproute-default-user-0"]
+	if len(msg.Configuration.EasyServiceRoute.Services) != 1 || len(routes) != 1 {
+		t.Fatalf("regular expression match and unselected routes should be skipped, got %+v", msg.Configuration.EasyServiceRoute.Services)
+	}
+	r := routes["httproute-default-user-0-0"]
+	if paths := routerPaths(r); len(paths) != 2 || paths[0] != "/api/user" || r.Routers[0].Methods[0] != "GET" {
+		t.Errorf("unexpected routers %+v", r.Routers)
+	}
+	if len(r.Servers) != 2 || r.Servers[0].Weight != 9 || r.Servers[1].Weight != 1 {
+		t.Errorf("backend weights should apply to their pods, got %+v", r.Servers)
+	}
+	if len(r.Middlewares) != 2 {
+		t.Fatalf("unexpected middlewares %v", r.Middlewares)
+	}
+	headers := msg.Configuration.Middlewares[r.Middlewares[0]].RequestHeaderModifier
+	if headers == nil || headers.Set["X-Env"] != "prod" || headers.Remove[0] != "X-Debug" {
+		t.Errorf("unexpected header modifier %+v", headers)
+	}
+	rewrite := msg.Configuration.Middlewares[r.Middlewares[1]].URLRewrite
+	if rewrite == nil || *rewrite.Path != "/user" || *rewrite.PathPrefix != "/api/user" {
+		t.Errorf("unexpected url rewrite %+v", rewrite)
+	}
+}
+
+func provide(t *testing.T, p *Provider) chan dynamic.Message {
+	t.Helper()
+	if err := p.Init(); err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan dynamic.Message, 10)
+	pool := safe.NewPool(context.Background())
+	t.Cleanup(pool.Stop)
+	if err := p.Provide(ch, pool); err != nil {
+		t.Fatal(err)
+	}
+	return ch
+}
+
+func receive(t *testing.T, ch <-chan dynamic.Message) dynamic.Message {
+	t.Helper()
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no configuration published")
+	}
+	return dynamic.Message{}
+}
+
+func routerPaths(route *dynamic.ServiceRoute) []string {
+	if route == nil {
+		return nil
+	}
+	var paths []string
+	for _, r := range route.Routers {
+		paths = append(paths, r.Path)
+	}
+	return paths
+}
+
+func TestClaim(t *testing.T) {
+	b := &builder{paths: make(map[string]map[string]bool)}
+	get := []string{"GET"}
+	if got := b.claim("a", pathRouters("/api", false, nil)); len(got) != 2 {
+		t.Fatalf("unexpected routers %+v", got)
+	}
+	cases := []struct {
+		path    string
+		exact   bool
+		methods []string
+		want    int
+	}{
+		// /api/*filepath 下的路径都会冲突
+		{"/api/v2", true, get, 0},
+		{"/apis", true, nil, 1},
+		{"/", false, nil, 0},
+		{"/login", true, get, 1},
+		{"/login", true, []string{"POST"}, 1},
+		{"/login", true, nil, 0},
+		{"/:id", true, nil, 0},
+	}
+	for _, c := range cases {
+		if got := b.claim("b", pathRouters(c.path, c.exact, c.methods)); len(got) != c.want {
+			t.Errorf("claim %s %v: got %d routers, want %d", c.path, c.methods, len(got), c.want)
+		}
+	}
+}
+
+// staticFileProvider 文件provider, 只有一个static服务
+func staticFileProvider(t *testing.T) *file.Provider {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "dynamic.yml")
+	if err := os.WriteFile(filename, []byte("easyServiceRoute:\n  services:\n    static:\n      static_http:\n        handler: http\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &file.Provider{Filename: filename}
+}
+
+// mergeWithFile 和aggregator一样合并文件和kubernetes provider的配置
+func mergeWithFile(t *testing.T, providers ...provider.Provider) map[string]map[string]*dynamic.ServiceRoute {
+	t.Helper()
+	configs := make(dynamic.Configurations)
+	for _, prd := range providers {
+		msg, err := prd.GetConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		configs[msg.ProviderName] = msg.Configuration
+	}
+	conf, conflicts := dynamic.MergeConfigurations(configs)
+	if len(conflicts) > 0 {
+		t.Errorf("unexpected conflicts %v", conflicts)
+	}
+	return conf.EasyServiceRoute.Services
+}
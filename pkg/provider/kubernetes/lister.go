@@ -0,0 +1,155 @@
+package kubernetes
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+	gatewayclient "sigs.k8s.io/gateway-api/pkg/client/clientset/versioned"
+	gatewayinformers "sigs.k8s.io/gateway-api/pkg/client/informers/externalversions"
+	gatewaylisters "sigs.k8s.io/gateway-api/pkg/client/listers/apis/v1"
+)
+
+// resyncPeriod informer定期全量同步的间隔, 0表示只依赖watch事件
+const resyncPeriod = 0
+
+// namespaceListers 一个namespace(或者全部namespace)下资源的lister
+type namespaceListers struct {
+	ingresses      networkinglisters.IngressLister
+	services       corelisters.ServiceLister
+	endpointSlices discoverylisters.EndpointSliceLister
+	httpRoutes     gatewaylisters.HTTPRouteLister
+}
+
+// lister 按namespace分组的informer缓存, 只监听配置的namespace
+type lister struct {
+	all        bool
+	namespaces map[string]*namespaceListers
+}
+
+// newLister 启动informer并等待缓存同步完成, 资源变化时向changes发送通知
+func newLister(ctx context.Context, client kubernetes.Interface, gatewayClient gatewayclient.Interface, namespaces []string, changes chan<- struct{}) (*lister, error) {
+	notify := func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(interface{}) { notify() },
+		UpdateFunc: func(oldObj, newObj interface{}) { notify() },
+		DeleteFunc: func(interface{}) { notify() },
+	}
+
+	l := &lister{namespaces: make(map[string]*namespaceListers)}
+	if len(namespaces) == 0 {
+		l.all = true
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	for _, ns := range namespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(client, resyncPeriod, informers.WithNamespace(ns))
+		nl := &namespaceListers{
+			ingresses:      factory.Networking().V1().Ingresses().Lister(),
+			services:       factory.Core().V1().Services().Lister(),
+			endpointSlices: factory.Discovery().V1().EndpointSlices().Lister(),
+		}
+		for _, informer := range []cache.SharedIndexInformer{
+			factory.Networking().V1().Ingresses().Informer(),
+			factory.Core().V1().Services().Informer(),
+			factory.Discovery().V1().EndpointSlices().Informer(),
+		} {
+			if _, err := informer.AddEventHandler(handler); err != nil {
+				return nil, err
+			}
+		}
+		factory.Start(ctx.Done())
+		for t, ok := range factory.WaitForCacheSync(ctx.Done()) {
+			if !ok {
+				return nil, errors.New("timed out waiting for caches to sync: " + t.String())
+			}
+		}
+
+		if gatewayClient != nil {
+			gatewayFactory := gatewayinformers.NewSharedInformerFactoryWithOptions(gatewayClient, resyncPeriod, gatewayinformers.WithNamespace(ns))
+			informer := gatewayFactory.Gateway().V1().HTTPRoutes().Informer()
+			if _, err := informer.AddEventHandler(handler); err != nil {
+				return nil, err
+			}
+			nl.httpRoutes = gatewayFactory.Gateway().V1().HTTPRoutes().Lister()
+			gatewayFactory.Start(ctx.Done())
+			for t, ok := range gatewayFactory.WaitForCacheSync(ctx.Done()) {
+				if !ok {
+					return nil, errors.New("timed out waiting for caches to sync: " + t.String())
+				}
+			}
+		}
+		l.namespaces[ns] = nl
+	}
+	// 缓存同步后生成一次初始配置
+	notify()
+	return l, nil
+}
+
+func (l *lister) forNamespace(namespace string) *namespaceListers {
+	if l.all {
+		return l.namespaces[metav1.NamespaceAll]
+	}
+	return l.namespaces[namespace]
+}
+
+// ingresses 所有监听的Ingress, 按namespace和名称排序, 保证生成的配置稳定
+func (l *lister) ingresses() []*networkingv1.Ingress {
+	var list []*networkingv1.Ingress
+	for _, nl := range l.namespaces {
+		items, _ := nl.ingresses.List(labels.Everything())
+		list = append(list, items...)
+	}
+	sort.Slice(list, func(i, j int) bool { return objectKey(&list[i].ObjectMeta) < objectKey(&list[j].ObjectMeta) })
+	return list
+}
+
+func (l *lister) httpRoutes() []*gatewayv1.HTTPRoute {
+	var list []*gatewayv1.HTTPRoute
+	for _, nl := range l.namespaces {
+		if nl.httpRoutes == nil {
+			continue
+		}
+		items, _ := nl.httpRoutes.List(labels.Everything())
+		list = append(list, items...)
+	}
+	sort.Slice(list, func(i, j int) bool { return objectKey(&list[i].ObjectMeta) < objectKey(&list[j].ObjectMeta) })
+	return list
+}
+
+func (l *lister) service(namespace, name string) (*corev1.Service, error) {
+	nl := l.forNamespace(namespace)
+	if nl == nil {
+		return nil, errors.New("namespace " + namespace + " is not watched")
+	}
+	return nl.services.Services(namespace).Get(name)
+}
+
+func (l *lister) endpointSlices(namespace, service string) ([]*discoveryv1.EndpointSlice, error) {
+	nl := l.forNamespace(namespace)
+	if nl == nil {
+		return nil, errors.New("namespace " + namespace + " is not watched")
+	}
+	selector := labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: service})
+	return nl.endpointSlices.EndpointSlices(namespace).List(selector)
+}
+
+func objectKey(meta *metav1.ObjectMeta) string {
+	return meta.Namespace + "/" + meta.Name
+}
@@ -0,0 +1,137 @@
+package label
+
+import (
+	"fmt"
+	"go-faster-gateway/pkg/config/dynamic"
+	"strconv"
+	"strings"
+)
+
+// Prefix 服务发现类provider从服务的标签(consul tag, docker label, k8s annotation等)中读取路由配置时使用的前缀
+const Prefix = "gateway."
+
+const (
+	Enable      = Prefix + "enable"      // 是否暴露该服务, docker等默认不暴露的provider使用
+	Handler     = Prefix + "handler"     // 协议 http/https/websocket, 默认http
+	Path        = Prefix + "path"        // 路由路径, 默认 /<service>/*filepath
+	Methods     = Prefix + "methods"     // 请求方法, 逗号分隔, 默认*
+	Type        = Prefix + "type"        // 路由类型 static/param/wildcard/subrouter
+	RoutePrefix = Prefix + "prefix"      // 路由前缀
+	ProxyPath   = Prefix + "proxyPath"   // 转发到上游的路径
+	Middlewares = Prefix + "middlewares" // 中间件, 逗号分隔
+	BalanceMode = Prefix + "balanceMode" // 负载均衡策略
+	Weight      = Prefix + "weight"      // 结点权重
+	Port        = Prefix + "port"        // 结点端口, 容器暴露多个端口时使用
+	Service     = Prefix + "service"     // 服务名, 多个容器使用同一个服务名时合并成一个服务的多个结点
+)
+
+// aliases 标签的别名, 比如 gateway.route.path=/api/*filepath 等同于 gateway.path
+var aliases = map[string]string{
+	Prefix + "route.path":      Path,
+	Prefix + "route.methods":   Methods,
+	Prefix + "route.type":      Type,
+	Prefix + "route.prefix":    RoutePrefix,
+	Prefix + "route.proxyPath": ProxyPath,
+	Prefix + "balance":         BalanceMode,
+}
+
+// Normalize 把别名转换成标准的标签, 两者同时存在时以标准标签为准
+func Normalize(labels map[string]string) map[string]string {
+	normalized := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if alias, ok := aliases[k]; ok {
+			if _, exists := labels[alias]; !exists {
+				normalized[alias] = v
+			}
+			continue
+		}
+		normalized[k] = v
+	}
+	return normalized
+}
+
+// FromTags 把 key=value 形式的标签转换成map, 没有=的标签忽略
+func FromTags(tags []string) map[string]string {
+	labels := make(map[string]string)
+	for _, tag := range tags {
+		if k, v, ok := strings.Cut(tag, "="); ok && strings.HasPrefix(k, Prefix) {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+// RouteName 服务下路由的名称, 和文件配置中的约定一致, 比如order_http
+func RouteName(service string, labels map[string]string) string {
+	return service + "_" + handler(labels)
+}
+
+// ServiceRoute 根据标签生成服务的路由, 没有配置的项使用默认值
+func ServiceRoute(service string, labels map[string]string, servers []dynamic.Server) (*dynamic.ServiceRoute, error) {
+	labels = Normalize(labels)
+	router := dynamic.Router{
+		Path:      labels[Path],
+		Type:      labels[Type],
+		Prefix:    labels[RoutePrefix],
+		ProxyPath: labels[ProxyPath],
+		Methods:   splitList(strings.ToUpper(labels[Methods])),
+	}
+	if router.Path == "" {
+		router.Path = "/" + service + "/*filepath"
+	}
+	if router.Type == "" && strings.Contains(router.Path, "/*") {
+		router.Type = "wildcard"
+	}
+	if len(router.Methods) == 0 {
+		router.Methods = []string{"*"}
+	}
+	h := handler(labels)
+	switch h {
+	case "http", "https", "websocket":
+	default:
+		return nil, fmt.Errorf("service %s: unsupported handler %q", service, h)
+	}
+	return &dynamic.ServiceRoute{
+		ServiceName: service,
+		BalanceMode: labels[BalanceMode],
+		Handler:     h,
+		Routers:     []dynamic.Router{router},
+		Servers:     servers,
+		Middlewares: splitList(labels[Middlewares]),
+	}, nil
+}
+
+// ParseWeight 解析权重, 为空或者不合法时返回默认值
+func ParseWeight(value string, defaultWeight int) int {
+	if w, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && w > 0 {
+		return w
+	}
+	return defaultWeight
+}
+
+// IsEnabled 解析enable标签, 没有配置时返回defaultValue
+func IsEnabled(labels map[string]string, defaultValue bool) bool {
+	v, ok := labels[Enable]
+	if !ok {
+		return defaultValue
+	}
+	enabled, err := strconv.ParseBool(v)
+	return err == nil && enabled
+}
+
+func handler(labels map[string]string) string {
+	if h := strings.ToLower(strings.TrimSpace(labels[Handler])); h != "" {
+		return h
+	}
+	return "http"
+}
+
+func splitList(value string) []string {
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
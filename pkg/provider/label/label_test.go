@@ -0,0 +1,46 @@
+package label
+
+import (
+	"testing"
+
+	"go-faster-gateway/pkg/config/dynamic"
+)
+
+func TestServiceRoute(t *testing.T) {
+	labels := FromTags([]string{"gateway", "other=1", "gateway.handler=websocket", "gateway.middlewares=auth, ratelimit"})
+	if len(labels) != 2 {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+	if name := RouteName("chat", labels); name != "chat_websocket" {
+		t.Errorf("unexpected route name %s", name)
+	}
+	route, err := ServiceRoute("chat", labels, []dynamic.Server{{Host: "10.0.0.1", Port: 80}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := route.Routers[0]
+	if router.Path != "/chat/*filepath" || router.Type != "wildcard" || router.Methods[0] != "*" {
+		t.Errorf("unexpected default router %+v", router)
+	}
+	if len(route.Middlewares) != 2 || route.Middlewares[1] != "ratelimit" {
+		t.Errorf("unexpected middlewares %v", route.Middlewares)
+	}
+
+	if _, err = ServiceRoute("chat", map[string]string{Handler: "grpc"}, nil); err == nil {
+		t.Error("expected error for unsupported handler")
+	}
+	route, err = ServiceRoute("api", map[string]string{Prefix + "route.path": "/api/*filepath", Prefix + "balance": "roundRobin", Path: "/v1/*filepath"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route.Routers[0].Path != "/v1/*filepath" || route.Routers[0].Type != "wildcard" || route.BalanceMode != "roundRobin" {
+		t.Errorf("unexpected aliased route %+v", route)
+	}
+
+	if ParseWeight("x", 3) != 3 || ParseWeight(" 7 ", 1) != 7 || ParseWeight("-1", 2) != 2 {
+		t.Error("unexpected weight parsing")
+	}
+	if !IsEnabled(nil, true) || IsEnabled(map[string]string{Enable: "false"}, true) || !IsEnabled(map[string]string{Enable: "true"}, false) {
+		t.Error("unexpected enable parsing")
+	}
+}
@@ -0,0 +1,244 @@
+package nacos
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// listener请求中的分隔符, 见nacos open api的配置监听接口
+const (
+	wordSeparator = "\x02"
+	lineSeparator = "\x01"
+)
+
+// client nacos open api(v1)的最小实现, 只包含服务发现和配置相关接口.
+// 配置监听是长轮询, 需要随ctx取消, 所以这里使用net/http
+type client struct {
+	address   string
+	namespace string
+	username  string
+	password  string
+	http      *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expireAt    time.Time
+}
+
+type statusError struct {
+	path string
+	code int
+	body []byte
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("nacos request %s: unexpected status %d: %s", e.path, e.code, e.body)
+}
+
+type instance struct {
+	InstanceID string            `json:"instanceId"`
+	IP         string            `json:"ip"`
+	Port       uint64            `json:"port"`
+	Weight     float64           `json:"weight"`
+	Healthy    bool              `json:"healthy"`
+	Enabled    bool              `json:"enabled"`
+	Metadata   map[string]string `json:"metadata"`
+}
+
+// serviceNames 分组下的全部服务名
+func (c *client) serviceNames(ctx context.Context, group string) ([]string, error) {
+	var names []string
+	for page := 1; ; page++ {
+		q := url.Values{}
+		q.Set("pageNo", strconv.Itoa(page))
+		q.Set("pageSize", "100")
+		q.Set("groupName", group)
+		var resp struct {
+			Count int      `json:"count"`
+			Doms  []string `json:"doms"`
+		}
+		if err := c.do(ctx, http.MethodGet, "/nacos/v1/ns/service/list", q, nil, 0, &resp); err != nil {
+			return nil, err
+		}
+		names = append(names, resp.Doms...)
+		if len(resp.Doms) == 0 || len(names) >= resp.Count {
+			return names, nil
+		}
+	}
+}
+
+// instances 服务下的所有实例, 包括不健康的实例
+func (c *client) instances(ctx context.Context, service, group string, clusters []string) ([]instance, error) {
+	q := url.Values{}
+	q.Set("serviceName", service)
+	q.Set("groupName", group)
+	q.Set("healthyOnly", "false")
+	if len(clusters) > 0 {
+		q.Set("clusters", strings.Join(clusters, ","))
+	}
+	var resp struct {
+		Hosts []instance `json:"hosts"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/nacos/v1/ns/instance/list", q, nil, 0, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Hosts, nil
+}
+
+// config 读取配置内容, 配置不存在时返回空
+func (c *client) config(ctx context.Context, dataID, group string) (string, error) {
+	q := url.Values{}
+	q.Set("dataId", dataID)
+	q.Set("group", group)
+	var content string
+	err := c.do(ctx, http.MethodGet, "/nacos/v1/cs/configs", q, nil, 0, &content)
+	var se *statusError
+	if errors.As(err, &se) && se.code == http.StatusNotFound {
+		return "", nil
+	}
+	return content, err
+}
+
+// listen 长轮询配置变化, 超时前配置发生变化返回true
+func (c *client) listen(ctx context.Context, dataID, group, content string, timeout time.Duration) (bool, error) {
+	sum := md5.Sum([]byte(content))
+	md5Hex := ""
+	if content != "" {
+		md5Hex = hex.EncodeToString(sum[:])
+	}
+	listening := dataID + wordSeparator + group + wordSeparator + md5Hex
+	if c.namespace != "" {
+		listening += wordSeparator + c.namespace
+	}
+	form := url.Values{}
+	form.Set("Listening-Configs", listening+lineSeparator)
+	var changed string
+	if err := c.do(ctx, http.MethodPost, "/nacos/v1/cs/configs/listener", url.Values{}, form, timeout, &changed); err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(changed) != "", nil
+}
+
+func (c *client) do(ctx context.Context, method, path string, q, form url.Values, longPoll time.Duration, v interface{}) error {
+	token, err := c.token(ctx)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		q.Set("accessToken", token)
+	}
+	if c.namespace != "" {
+		if strings.HasPrefix(path, "/nacos/v1/cs/") {
+			q.Set("tenant", c.namespace)
+		} else {
+			q.Set("namespaceId", c.namespace)
+		}
+	}
+	timeout := 10 * time.Second
+	if longPoll > 0 {
+		timeout = longPoll + 10*time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.address, "/")+path+"?"+q.Encode(), body)
+	if err != nil {
+		return err
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if longPoll > 0 {
+		req.Header.Set("Long-Pulling-Timeout", strconv.FormatInt(longPoll.Milliseconds(), 10))
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("nacos request %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("nacos request %s: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusForbidden {
+			c.resetToken()
+		}
+		if len(data) > 1024 {
+			data = data[:1024]
+		}
+		return &statusError{path: path, code: resp.StatusCode, body: data}
+	}
+	if s, ok := v.(*string); ok {
+		*s = string(data)
+		return nil
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("nacos request %s: %w", path, err)
+	}
+	return nil
+}
+
+// token 开启鉴权时登录获取accessToken, 过期前复用
+func (c *client) token(ctx context.Context) (string, error) {
+	if c.username == "" {
+		return "", nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessToken != "" && time.Now().Before(c.expireAt) {
+		return c.accessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("username", c.username)
+	form.Set("password", c.password)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.address, "/")+"/nacos/v1/auth/login", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("nacos login: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("nacos login: unexpected status %d", resp.StatusCode)
+	}
+	var login struct {
+		AccessToken string `json:"accessToken"`
+		TokenTTL    int64  `json:"tokenTtl"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		return "", fmt.Errorf("nacos login: %w", err)
+	}
+	c.accessToken = login.AccessToken
+	// 提前十分之一的时间刷新token
+	ttl := time.Duration(login.TokenTTL) * time.Second
+	c.expireAt = time.Now().Add(ttl - ttl/10)
+	return c.accessToken, nil
+}
+
+func (c *client) resetToken() {
+	c.mu.Lock()
+	c.accessToken = ""
+	c.mu.Unlock()
+}
@@ -0,0 +1,337 @@
+package nacos
+
+import (
+	"context"
+	"fmt"
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/helper/parser"
+	"go-faster-gateway/pkg/helper/utils"
+	"go-faster-gateway/pkg/log"
+	"go-faster-gateway/pkg/provider"
+	"go-faster-gateway/pkg/provider/label"
+	"go-faster-gateway/pkg/safe"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const providerName = "nacos"
+
+var _ provider.Provider = (*Provider)(nil)
+
+// Provider holds configurations of the provider.
+type Provider struct {
+	ServerAddr      string             `description:"Nacos server address." json:"serverAddr,omitempty" toml:"serverAddr,omitempty" yaml:"serverAddr,omitempty" export:"true"`
+	Namespace       string             `description:"Nacos namespace id, the public namespace is used when empty." json:"namespace,omitempty" toml:"namespace,omitempty" yaml:"namespace,omitempty" export:"true"`
+	Group           string             `description:"Group of the services and of the configuration." json:"group,omitempty" toml:"group,omitempty" yaml:"group,omitempty" export:"true"`
+	Clusters        []string           `description:"Only the instances in these clusters are used." json:"clusters,omitempty" toml:"clusters,omitempty" yaml:"clusters,omitempty" export:"true"`
+	Services        []string           `description:"Services to expose, all the services of the group are exposed when empty." json:"services,omitempty" toml:"services,omitempty" yaml:"services,omitempty" export:"true"`
+	Username        string             `description:"Nacos username." json:"username,omitempty" toml:"username,omitempty" yaml:"username,omitempty" loggable:"false"`
+	Password        string             `description:"Nacos password." json:"password,omitempty" toml:"password,omitempty" yaml:"password,omitempty" loggable:"false"`
+	TLS             *dynamic.ClientTLS `description:"Enable TLS support." json:"tls,omitempty" toml:"tls,omitempty" yaml:"tls,omitempty" export:"true"`
+	RefreshInterval parser.Duration    `description:"Interval between two service instances refreshes." json:"refreshInterval,omitempty" toml:"refreshInterval,omitempty" yaml:"refreshInterval,omitempty" export:"true"`
+	Config          *Config            `description:"Load the dynamic configuration from a Nacos config." json:"config,omitempty" toml:"config,omitempty" yaml:"config,omitempty" export:"true"`
+
+	client *client
+	mu     sync.Mutex
+	// 服务和配置在两个goroutine中更新, 保证按生成顺序发布
+	publishMu sync.Mutex
+	// 最近一次读取的配置和发现的服务, 两者合并后发布
+	fileConfiguration *dynamic.Configuration
+	services          map[string]map[string]*dynamic.ServiceRoute
+	configuration     *dynamic.Configuration
+}
+
+// Config holds the Nacos config settings.
+type Config struct {
+	DataID          string          `description:"Data id of the configuration." json:"dataId,omitempty" toml:"dataId,omitempty" yaml:"dataId,omitempty" export:"true"`
+	Group           string          `description:"Group of the configuration, the provider group is used when empty." json:"group,omitempty" toml:"group,omitempty" yaml:"group,omitempty" export:"true"`
+	Type            string          `description:"Format of the configuration: yaml, json or toml." json:"type,omitempty" toml:"type,omitempty" yaml:"type,omitempty" export:"true"`
+	LongPollTimeout parser.Duration `description:"Timeout of the long polling request listening for changes." json:"longPollTimeout,omitempty" toml:"longPollTimeout,omitempty" yaml:"longPollTimeout,omitempty" export:"true"`
+}
+
+// SetDefaults sets the default values.
+func (p *Provider) SetDefaults() {
+	if p.ServerAddr == "" {
+		p.ServerAddr = "http://127.0.0.1:8848"
+	}
+	if p.Group == "" {
+		p.Group = "DEFAULT_GROUP"
+	}
+	if p.RefreshInterval <= 0 {
+		p.RefreshInterval = parser.Duration(5 * time.Second)
+	}
+	if p.Config != nil {
+		if p.Config.Group == "" {
+			p.Config.Group = p.Group
+		}
+		if p.Config.Type == "" {
+			p.Config.Type = "yaml"
+		}
+		if p.Config.LongPollTimeout <= 0 {
+			p.Config.LongPollTimeout = parser.Duration(30 * time.Second)
+		}
+	}
+}
+
+// Init the provider.
+func (p *Provider) Init() error {
+	p.SetDefaults()
+	if p.Config != nil && p.Config.DataID == "" {
+		return fmt.Errorf("error using nacos configuration provider, config dataId is not defined")
+	}
+	tlsConfig, err := p.TLS.CreateTLSConfig()
+	if err != nil {
+		return fmt.Errorf("unable to create client TLS configuration: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	p.client = &client{
+		address:   p.ServerAddr,
+		namespace: p.Namespace,
+		username:  p.Username,
+		password:  p.Password,
+		http:      &http.Client{Transport: transport},
+	}
+	return nil
+}
+
+// Provide 按refreshInterval刷新服务实例, 配置了config时同时长轮询监听配置变化
+func (p *Provider) Provide(configurationChan chan<- dynamic.Message, pool *safe.Pool) error {
+	pool.GoCtx(func(ctx context.Context) {
+		p.watchServices(ctx, configurationChan)
+	})
+	if p.Config != nil {
+		pool.GoCtx(func(ctx context.Context) {
+			p.watchConfig(ctx, configurationChan)
+		})
+	}
+	return nil
+}
+
+// GetConfig 返回最近一次生成的配置, 第一次生成之前返回nil, 不参与配置合并
+func (p *Provider) GetConfig() (dynamic.Message, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return dynamic.Message{
+		ProviderName:  providerName,
+		Configuration: p.configuration,
+	}, nil
+}
+
+func (p *Provider) watchServices(ctx context.Context, configurationChan chan<- dynamic.Message) {
+	slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+	ticker := time.NewTicker(time.Duration(p.RefreshInterval))
+	defer ticker.Stop()
+	for {
+		services, err := p.discover(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			slog.WithError(err).Error("Error while discovering services")
+		} else {
+			p.mu.Lock()
+			p.services = services
+			p.mu.Unlock()
+			p.publish(configurationChan)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchConfig 先读取一次配置, 之后长轮询, nacos返回变化时重新读取
+func (p *Provider) watchConfig(ctx context.Context, configurationChan chan<- dynamic.Message) {
+	slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+	var content string
+	changed := true
+	for {
+		var err error
+		if changed {
+			content, err = p.loadConfig(ctx, configurationChan)
+		}
+		if err == nil {
+			changed, err = p.client.listen(ctx, p.Config.DataID, p.Config.Group, content, time.Duration(p.Config.LongPollTimeout))
+		}
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		slog.WithError(err).Errorf("Error while loading config %s", p.Config.DataID)
+		changed = true
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(p.RefreshInterval)):
+		}
+	}
+}
+
+// loadConfig 读取并解析配置, 配置不合法时保留上一次的配置
+func (p *Provider) loadConfig(ctx context.Context, configurationChan chan<- dynamic.Message) (string, error) {
+	content, err := p.client.config(ctx, p.Config.DataID, p.Config.Group)
+	if err != nil {
+		return "", err
+	}
+	c, err := decodeConfiguration(p.Config.Type, content)
+	if err != nil {
+		log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName}).
+			WithError(err).Errorf("Invalid config %s", p.Config.DataID)
+		return content, nil
+	}
+	p.mu.Lock()
+	p.fileConfiguration = c
+	p.mu.Unlock()
+	p.publish(configurationChan)
+	return content, nil
+}
+
+// discover 生成服务的路由, 路由配置来自实例metadata中gateway.开头的项
+func (p *Provider) discover(ctx context.Context) (map[string]map[string]*dynamic.ServiceRoute, error) {
+	slog := log.Log.WithFields(map[string]interface{}{log.ProviderName: providerName})
+	names := p.Services
+	if len(names) == 0 {
+		var err error
+		if names, err = p.client.serviceNames(ctx, p.Group); err != nil {
+			return nil, err
+		}
+	}
+	services := make(map[string]map[string]*dynamic.ServiceRoute)
+	for _, name := range names {
+		instances, err := p.client.instances(ctx, name, p.Group, p.Clusters)
+		if err != nil {
+			return nil, err
+		}
+		if len(instances) == 0 {
+			continue
+		}
+		sort.Slice(instances, func(i, j int) bool { return instances[i].InstanceID < instances[j].InstanceID })
+		labels := make(map[string]string)
+		for key, value := range instances[0].Metadata {
+			if strings.HasPrefix(key, label.Prefix) {
+				labels[key] = value
+			}
+		}
+		if !label.IsEnabled(labels, true) {
+			continue
+		}
+		servers := make([]dynamic.Server, 0, len(instances))
+		for _, ins := range instances {
+			servers = append(servers, toServer(ins))
+		}
+		route, err := label.ServiceRoute(name, labels, servers)
+		if err != nil {
+			slog.WithError(err).Warn("Skip service")
+			continue
+		}
+		services[name] = map[string]*dynamic.ServiceRoute{label.RouteName(name, labels): route}
+	}
+	return services, nil
+}
+
+// toServer nacos的权重是浮点数, 四舍五入后大于0的权重至少为1
+func toServer(ins instance) dynamic.Server {
+	weight := int(math.Round(ins.Weight))
+	if weight == 0 && ins.Weight > 0 {
+		weight = 1
+	}
+	return dynamic.Server{
+		Host:    ins.IP,
+		Port:    ins.Port,
+		Weight:  weight,
+		Healthy: ins.Healthy && ins.Enabled,
+	}
+}
+
+// publish 合并配置和发现的服务后发布. 配置中已经定义的服务保留其路由,
+// 只有没有配置servers的路由使用发现的实例
+func (p *Provider) publish(configurationChan chan<- dynamic.Message) {
+	p.publishMu.Lock()
+	defer p.publishMu.Unlock()
+	p.mu.Lock()
+	configuration := emptyConfiguration()
+	if p.fileConfiguration != nil {
+		configuration.BalanceMode = p.fileConfiguration.BalanceMode
+		configuration.GlobalMiddleware = p.fileConfiguration.GlobalMiddleware
+		configuration.Middlewares = p.fileConfiguration.Middlewares
+		configuration.ServersTransports = p.fileConfiguration.ServersTransports
+		configuration.Databases = p.fileConfiguration.Databases
+		for name, routes := range p.fileConfiguration.EasyServiceRoute.Services {
+			configuration.EasyServiceRoute.Services[name] = routes
+		}
+	}
+	for name, discovered := range p.services {
+		routes, ok := configuration.EasyServiceRoute.Services[name]
+		if !ok {
+			configuration.EasyServiceRoute.Services[name] = discovered
+			continue
+		}
+		var servers []dynamic.Server
+		for _, route := range discovered {
+			servers = route.Servers
+		}
+		merged := make(map[string]*dynamic.ServiceRoute, len(routes))
+		for routeName, route := range routes {
+			if len(route.Servers) == 0 {
+				withServers := *route
+				withServers.Servers = servers
+				route = &withServers
+			}
+			merged[routeName] = route
+		}
+		configuration.EasyServiceRoute.Services[name] = merged
+	}
+	unchanged := reflect.DeepEqual(p.configuration, configuration)
+	if !unchanged {
+		p.configuration = configuration
+	}
+	p.mu.Unlock()
+	if unchanged {
+		return
+	}
+	configurationChan <- dynamic.Message{
+		ProviderName:  providerName,
+		Configuration: configuration,
+	}
+}
+
+func decodeConfiguration(configType, content string) (*dynamic.Configuration, error) {
+	c := emptyConfiguration()
+	if strings.TrimSpace(content) == "" {
+		return c, nil
+	}
+	v := viper.New()
+	v.SetConfigType(configType)
+	if err := v.ReadConfig(strings.NewReader(content)); err != nil {
+		return nil, err
+	}
+	if err := v.Unmarshal(c, utils.ViperDecodeHook); err != nil {
+		return nil, err
+	}
+	if c.EasyServiceRoute == nil || c.EasyServiceRoute.Services == nil {
+		c.EasyServiceRoute = &dynamic.ServiceRouteConfiguration{
+			Services: make(map[string]map[string]*dynamic.ServiceRoute),
+		}
+	}
+	return c, nil
+}
+
+func emptyConfiguration() *dynamic.Configuration {
+	return &dynamic.Configuration{
+		EasyServiceRoute: &dynamic.ServiceRouteConfiguration{
+			Services: make(map[string]map[string]*dynamic.ServiceRoute),
+		},
+	}
+}
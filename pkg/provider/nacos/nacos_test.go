@@ -0,0 +1,253 @@
+package nacos
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-faster-gateway/pkg/config/dynamic"
+	"go-faster-gateway/pkg/helper/parser"
+	"go-faster-gateway/pkg/provider"
+	"go-faster-gateway/pkg/provider/file"
+	"go-faster-gateway/pkg/safe"
+)
+
+// fakeNacos 模拟nacos的登录, 服务发现和配置接口, 配置未变化时监听请求一直阻塞
+type fakeNacos struct {
+	mu        sync.Mutex
+	changed   chan struct{}
+	content   string
+	instances map[string][]instance
+}
+
+func newFakeNacos() *fakeNacos {
+	return &fakeNacos{changed: make(chan struct{}), instances: make(map[string][]instance)}
+}
+
+func (f *fakeNacos) setConfig(content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.content = content
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeNacos) setInstances(service string, instances []instance) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.instances[service] = instances
+}
+
+func (f *fakeNacos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/nacos/v1/auth/login" {
+		if r.FormValue("username") != "nacos" || r.FormValue("password") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"accessToken": "token", "tokenTtl": 18000})
+		return
+	}
+	q := r.URL.Query()
+	if q.Get("accessToken") != "token" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	content, changed := f.content, f.changed
+	f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/nacos/v1/ns/service/list":
+		if q.Get("namespaceId") != "dev" || q.Get("groupName") != "GATEWAY" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		names := make([]string, 0, len(f.instances))
+		for name := range f.instances {
+			names = append(names, name)
+		}
+		f.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"count": len(names), "doms": names})
+	case "/nacos/v1/ns/instance/list":
+		if q.Get("clusters") != "bj" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		hosts := f.instances[q.Get("serviceName")]
+		f.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"hosts": hosts})
+	case "/nacos/v1/cs/configs":
+		if q.Get("tenant") != "dev" || q.Get("dataId") != "gateway.yml" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if content == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	case "/nacos/v1/cs/configs/listener":
+		parts := strings.Split(strings.TrimSuffix(r.FormValue("Listening-Configs"), lineSeparator), wordSeparator)
+		if len(parts) != 4 || parts[3] != "dev" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		sum := md5.Sum([]byte(content))
+		if (content == "" && parts[2] == "") || parts[2] == hex.EncodeToString(sum[:]) {
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+				return
+			case <-time.After(time.Second):
+				return
+			}
+		}
+		_, _ = w.Write([]byte(parts[0] + "%02" + parts[1] + "%02" + parts[3] + "%01\n"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestProvider(t *testing.T) {
+	nacos := newFakeNacos()
+	nacos.setInstances("user", []instance{
+		{InstanceID: "b", IP: "10.0.0.2", Port: 8080, Weight: 0.2, Healthy: false, Enabled: true},
+		{InstanceID: "a", IP: "10.0.0.1", Port: 8080, Weight: 2, Healthy: true, Enabled: true,
+			Metadata: map[string]string{"gateway.path": "/user/*filepath", "version": "1"}},
+	})
+	nacos.setInstances("order", []instance{{InstanceID: "c", IP: "10.0.0.3", Port: 9000, Weight: 1, Healthy: true, Enabled: false}})
+	nacos.setInstances("internal", []instance{{InstanceID: "d", IP: "10.0.0.4", Port: 9000, Weight: 1, Healthy: true, Enabled: true,
+		Metadata: map[string]string{"gateway.enable": "false"}}})
+	server := httptest.NewServer(nacos)
+	defer server.Close()
+
+	p := &Provider{
+		ServerAddr:      server.URL,
+		Namespace:       "dev",
+		Group:           "GATEWAY",
+		Clusters:        []string{"bj"},
+		Username:        "nacos",
+		Password:        "secret",
+		RefreshInterval: parser.Duration(50 * time.Millisecond),
+		Config:          &Config{DataID: "gateway.yml"},
+	}
+	if err := p.Init(); err != nil {
+		t.Fatal(err)
+	}
+	// 第一次生成配置之前不影响文件中的路由
+	fileProvider := staticFileProvider(t)
+	if services := mergeWithFile(t, fileProvider, p); len(services) != 1 || services["static"] == nil {
+		t.Fatalf("file routes lost before nacos is loaded: %+v", services)
+	}
+	ch := make(chan dynamic.Message, 100)
+	pool := safe.NewPool(context.Background())
+	defer pool.Stop()
+	if err := p.Provide(ch, pool); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := waitFor(t, ch, func(c *dynamic.Configuration) bool { return len(c.EasyServiceRoute.Services) == 2 })
+	user := msg.Configuration.EasyServiceRoute.Services["user"]["user_http"]
+	want := []dynamic.Server{
+		{Host: "10.0.0.1", Port: 8080, Weight: 2, Healthy: true},
+		{Host: "10.0.0.2", Port: 8080, Weight: 1, Healthy: false},
+	}
+	if user == nil || user.Routers[0].Path != "/user/*filepath" || len(user.Servers) != 2 || user.Servers[0] != want[0] || user.Servers[1] != want[1] {
+		t.Fatalf("unexpected user route %+v", user)
+	}
+	if order := msg.Configuration.EasyServiceRoute.Services["order"]["order_http"]; order.Servers[0].Healthy {
+		t.Error("disabled instance should not be healthy")
+	}
+	if services := mergeWithFile(t, fileProvider, p); len(services) != 3 || services["static"] == nil || services["user"] == nil {
+		t.Errorf("unexpected merged services %+v", services)
+	}
+
+	// 配置中定义的服务保留路由, 没有servers时使用发现的实例
+	nacos.setConfig(`
+globalMiddleware: [requestid]
+easyServiceRoute:
+  services:
+    user:
+      user_api:
+        handler: http
+        routers:
+          - path: /api/user
+    pay:
+      pay_http:
+        handler: http
+        servers:
+          - host: 10.0.1.1
+            port: 80
+`)
+	msg = waitFor(t, ch, func(c *dynamic.Configuration) bool { return len(c.GlobalMiddleware) == 1 })
+	services := msg.Configuration.EasyServiceRoute.Services
+	if services["user"]["user_http"] != nil || services["user"]["user_api"] == nil || len(services["user"]["user_api"].Servers) != 2 {
+		t.Errorf("configured routes should use discovered servers, got %+v", services["user"])
+	}
+	if services["pay"]["pay_http"] == nil || services["order"] == nil {
+		t.Errorf("unexpected services %+v", services)
+	}
+	if conf, _ := p.GetConfig(); !sameConfiguration(conf.Configuration, msg.Configuration) {
+		t.Error("GetConfig should return the last published configuration")
+	}
+}
+
+func waitFor(t *testing.T, ch <-chan dynamic.Message, ok func(*dynamic.Configuration) bool) dynamic.Message {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-ch:
+			if ok(msg.Configuration) {
+				return msg
+			}
+		case <-timeout:
+			t.Fatal("expected configuration was not published")
+		}
+	}
+}
+
+func sameConfiguration(a, b *dynamic.Configuration) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
+
+// staticFileProvider 文件provider, 只有一个static服务
+func staticFileProvider(t *testing.T) *file.Provider {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "dynamic.yml")
+	if err := os.WriteFile(filename, []byte("easyServiceRoute:\n  services:\n    static:\n      static_http:\n        handler: http\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &file.Provider{Filename: filename}
+}
+
+// mergeWithFile 和aggregator一样合并文件和nacos provider的配置
+func mergeWithFile(t *testing.T, providers ...provider.Provider) map[string]map[string]*dynamic.ServiceRoute {
+	t.Helper()
+	configs := make(dynamic.Configurations)
+	for _, prd := range providers {
+		msg, err := prd.GetConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		configs[msg.ProviderName] = msg.Configuration
+	}
+	conf, conflicts := dynamic.MergeConfigurations(configs)
+	if len(conflicts) > 0 {
+		t.Errorf("unexpected conflicts %v", conflicts)
+	}
+	return conf.EasyServiceRoute.Services
+}